@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aumahesh/policyparser/pkg/decoder"
+	"github.com/aumahesh/policyparser/pkg/parser"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// commands are the sub-commands of the binary; without one it parses the
+// policy file named in config.yaml.
+var commands = map[string]func(args []string) error{
+	"diff":      diffCommand,
+	"evaluate":  evaluateCommand,
+	"import":    importCommand,
+	"optimize":  optimizeCommand,
+	"schema":    schemaCommand,
+	"serve":     serveCommand,
+	"subsume":   subsumeCommand,
+	"summarize": summarizeCommand,
+	"watch":     watchCommand,
+	"who-can":   whoCanCommand,
+}
+
+// parseOptions are the flags shared by the sub-commands that parse files.
+type parseOptions struct {
+	cloud    string
+	decoders string
+	strict   bool
+	verbose  bool
+}
+
+func (o *parseOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.cloud, "cloud", parser.Aws, "cloud provider of the policy files")
+	fs.StringVar(&o.decoders, "decoders", decoder.Auto, "comma separated decoders applied to the files")
+	fs.BoolVar(&o.strict, "strict", false, "fail on values the parser would have to guess at")
+	fs.BoolVar(&o.verbose, "v", false, "log debug output")
+}
+
+func (o *parseOptions) apply() {
+	log.SetLevel(log.WarnLevel)
+	if o.verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+}
+
+// parseFile parses the policy file at path and logs its warnings.
+func (o *parseOptions) parseFile(path string) ([]*policy.Policy, error) {
+	p, err := o.parse(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range p.Warnings() {
+		log.Warnf("%s: %s", path, w.Error())
+	}
+	return p.GetPolicy()
+}
+
+// parse parses the policy file at path.
+func (o *parseOptions) parse(path string) (parser.Parser, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := decoder.NewPipeline(splitList(o.decoders)...)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parser.NewParser(o.cloud, string(text), dec, o.strict)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Parse(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return p, nil
+}
+
+// kinds can prefix a policy file given to parseFiles, e.g. scp:org.json.
+var kinds = []string{
+	policy.KindIdentity,
+	policy.KindResource,
+	policy.KindTrust,
+	policy.KindSCP,
+	policy.KindRCP,
+	policy.KindBoundary,
+	policy.KindSession,
+}
+
+// parseFiles parses the policy files of args, each optionally prefixed with
+// one of kinds, which then overrides the kind of its policies.
+func (o *parseOptions) parseFiles(args []string) ([]*policy.Policy, error) {
+	policies := []*policy.Policy{}
+	for _, arg := range args {
+		kind, path := "", arg
+		for _, k := range kinds {
+			if strings.HasPrefix(arg, k+":") {
+				kind, path = k, arg[len(k)+1:]
+			}
+		}
+		x, err := o.parseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range x {
+			if kind != "" {
+				p.Kind = kind
+			}
+			policies = append(policies, p)
+		}
+	}
+	return policies, nil
+}
+
+func splitList(s string) []string {
+	x := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			x = append(x, item)
+		}
+	}
+	return x
+}
+
+func usage(fs *flag.FlagSet, args string) func() {
+	return func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s [flags] %s\n", os.Args[0], fs.Name(), args)
+		fs.PrintDefaults()
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/diff"
+)
+
+// diffCommand compares two versions of a policy:
+//
+//	parser diff [flags] old.json new.json
+func diffCommand(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	format := fs.String("format", "text", "output format, text or json")
+	fs.Usage = usage(fs, "old.json new.json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("need the old and the new policy file")
+	}
+	opts.apply()
+
+	before, err := opts.parseFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := opts.parseFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d := diff.Compare(before, after)
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case "text":
+		printDiff(os.Stdout, d)
+		return nil
+	}
+	return fmt.Errorf("%s is not a supported format", *format)
+}
+
+func printDiff(w io.Writer, d *diff.Diff) {
+	for _, s := range d.Statements {
+		if s.Status == diff.Unchanged {
+			continue
+		}
+		name := s.Sid
+		if name == "" {
+			name = s.NewId
+		}
+		if name == "" {
+			name = s.OldId
+		}
+		fmt.Fprintf(w, "%s statement %s (%s)%s\n", s.Status, name, s.Effect, direction(s.Broadens, s.Narrows))
+		for _, field := range []struct {
+			name    string
+			changes diff.Changes
+		}{
+			{"actions", s.Actions},
+			{"not-actions", s.NotActions},
+			{"resources", s.Resources},
+			{"not-resources", s.NotResources},
+			{"principals", s.Principals},
+			{"not-principals", s.NotPrincipals},
+			{"conditions", s.Conditions},
+		} {
+			for _, a := range field.changes.Added {
+				fmt.Fprintf(w, "  + %s: %s\n", field.name, a)
+			}
+			for _, r := range field.changes.Removed {
+				fmt.Fprintf(w, "  - %s: %s\n", field.name, r)
+			}
+		}
+	}
+	fmt.Fprintf(w, "overall:%s\n", direction(d.Broadens, d.Narrows))
+}
+
+func direction(broadens, narrows bool) string {
+	x := []string{}
+	if broadens {
+		x = append(x, "broadens access")
+	}
+	if narrows {
+		x = append(x, "narrows access")
+	}
+	if len(x) == 0 {
+		return " no change in access"
+	}
+	return " " + strings.Join(x, ", ")
+}
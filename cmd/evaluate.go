@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/evaluator"
+	"github.com/aumahesh/policyparser/pkg/parser"
+)
+
+// contextFlag collects repeated -context key=value1,value2 flags.
+type contextFlag map[string][]string
+
+func (c contextFlag) String() string {
+	x := []string{}
+	for k, v := range c {
+		x = append(x, k+"="+strings.Join(v, ","))
+	}
+	return strings.Join(x, " ")
+}
+
+func (c contextFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("%s is not key=value", s)
+	}
+	c[s[:i]] = append(c[s[:i]], splitList(s[i+1:])...)
+	return nil
+}
+
+// evaluateCommand decides a request against policy files and explains the
+// decision:
+//
+//	parser evaluate [flags] [kind:]policy.json...
+func evaluateCommand(args []string) error {
+	fs := flag.NewFlagSet("evaluate", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	format := fs.String("format", "tree", "output format, tree or json")
+	request := evaluator.Request{Context: map[string][]string{}}
+	fs.StringVar(&request.Principal, "principal", "", "arn of the principal making the request")
+	fs.StringVar(&request.PrincipalAccount, "principal-account", "", "account of the principal, if not in its arn")
+	fs.StringVar(&request.Action, "action", "", "action requested, e.g. s3:GetObject")
+	fs.StringVar(&request.Resource, "resource", "", "arn of the resource")
+	fs.StringVar(&request.ResourceAccount, "resource-account", "", "account owning the resource, if not in its arn")
+	fs.Var(contextFlag(request.Context), "context", "condition key of the request as key=value[,value], repeatable")
+	fs.Usage = usage(fs, "[kind:]policy.json...\n\nkind is one of "+strings.Join(kinds, ", ")+", overriding the detected kind")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || request.Action == "" {
+		fs.Usage()
+		return fmt.Errorf("need an action and at least one policy file")
+	}
+	opts.apply()
+
+	policies, err := opts.parseFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var d *evaluator.Decision
+	if opts.cloud == parser.Aws {
+		d = evaluator.EvaluateAws(policies, request)
+	} else {
+		d = evaluator.Evaluate(policies, request)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case "tree":
+		printDecision(os.Stdout, d)
+		return nil
+	}
+	return fmt.Errorf("%s is not a supported format", *format)
+}
+
+// printDecision prints the decision as a tree of the rules checked, the
+// statements considered for each and their conditions.
+func printDecision(w io.Writer, d *evaluator.Decision) {
+	fmt.Fprintf(w, "%s: %s\n", d.Effect, d.Reason)
+	for i, step := range d.Steps {
+		last := i == len(d.Steps)-1
+		fmt.Fprintf(w, "%s%s: %s\n", branch(last), step.Rule, step.Result)
+		indent := trunk(last)
+		for j, st := range step.Considered {
+			lastStatement := j == len(step.Considered)-1
+			name := st.Id
+			if st.Sid != "" {
+				name += " (" + st.Sid + ")"
+			}
+			fmt.Fprintf(w, "%s%s%s %s: principal %s, action %s, resource %s -> %s\n",
+				indent, branch(lastStatement), st.Effect, name,
+				yesNo(st.Principal), yesNo(st.Action), yesNo(st.Resource), applies(st.Applies))
+			inner := indent + trunk(lastStatement)
+			lines := []string{}
+			if len(st.Unresolved) > 0 {
+				lines = append(lines, "unresolved variables: "+strings.Join(st.Unresolved, ", "))
+			}
+			for _, c := range st.Conditions {
+				result := "failed"
+				if c.Passed {
+					result = "passed"
+				}
+				lines = append(lines, fmt.Sprintf("%s %s [%s]: %s, %s",
+					c.Operator, c.Key, strings.Join(c.Values, ", "), result, c.Reason))
+			}
+			for k, line := range lines {
+				fmt.Fprintf(w, "%s%s%s\n", inner, branch(k == len(lines)-1), line)
+			}
+		}
+	}
+}
+
+func branch(last bool) string {
+	if last {
+		return "└── "
+	}
+	return "├── "
+}
+
+func trunk(last bool) string {
+	if last {
+		return "    "
+	}
+	return "│   "
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "matches"
+	}
+	return "does not match"
+}
+
+func applies(b bool) string {
+	if b {
+		return "applies"
+	}
+	return "does not apply"
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aumahesh/policyparser/pkg/store"
+)
+
+// importCommand parses policy files into a SQLite database:
+//
+//	parser import -db policies.db [kind:]policy.json...
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	db := fs.String("db", "policies.db", "SQLite database, created if missing")
+	fs.Usage = usage(fs, "[kind:]policy.json...\n\nkind is one of "+strings.Join(kinds, ", ")+", overriding the detected kind")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("need at least one policy file")
+	}
+	opts.apply()
+
+	s, err := store.Open(*db)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for _, arg := range fs.Args() {
+		policies, err := opts.parseFiles([]string{arg})
+		if err != nil {
+			return err
+		}
+		i := store.Import{Source: arg, Cloud: opts.cloud, ParsedAt: time.Now()}
+		if _, err = s.Save(i, policies); err != nil {
+			return fmt.Errorf("%s: %s", arg, err.Error())
+		}
+		fmt.Printf("%s: imported %d statements\n", arg, len(policies))
+	}
+	return nil
+}
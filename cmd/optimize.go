@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aumahesh/policyparser/pkg/optimize"
+)
+
+// optimizeCommand prints a policy rewritten into fewer, shorter statements
+// and reports the size saved on stderr:
+//
+//	parser optimize [flags] policy.json
+func optimizeCommand(args []string) error {
+	fs := flag.NewFlagSet("optimize", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	format := fs.String("format", "text", "output format, text for the rewritten documents or json for the full report")
+	catalogFile := fs.String("catalog", "", "action catalog to use instead of the embedded one")
+	collapse := fs.Bool("collapse", false, "collapse action lists into wildcards, for the services the catalog declares complete; needs a -catalog declaring some, the embedded one declares none")
+	quota := fs.String("quota", "", "quota of documents whose origin does not tell where they are used, e.g. scp")
+	fs.Usage = usage(fs, "policy.json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("need a policy file")
+	}
+	opts.apply()
+
+	c, err := loadCatalog(*catalogFile)
+	if err != nil {
+		return err
+	}
+	policies, err := opts.parseFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	r, err := optimize.Optimize(policies, optimize.Options{Catalog: c, CollapseActions: *collapse, Quota: *quota})
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "text":
+		for _, change := range r.Changes {
+			fmt.Fprintln(os.Stderr, change)
+		}
+		for _, d := range r.Documents {
+			fmt.Fprintf(os.Stderr, "%s: %d statements, %d characters -> %d statements, %d characters (%s limit %d)\n",
+				d.Name, d.StatementsBefore, d.SizeBefore, d.StatementsAfter, d.SizeAfter, d.Quota, d.Limit)
+			fmt.Println(d.Rendered)
+		}
+		return nil
+	}
+	return fmt.Errorf("%s is not a supported format", *format)
+}
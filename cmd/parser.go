@@ -8,10 +8,23 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"github.com/aumahesh/policyparser/pkg/decoder"
 	"github.com/aumahesh/policyparser/pkg/parser"
+	"github.com/aumahesh/policyparser/pkg/policy"
+	"github.com/aumahesh/policyparser/pkg/writer"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	log.SetLevel(log.DebugLevel)
 
 	log.Debugf("Hello, World!")
@@ -19,7 +32,13 @@ func main() {
 	viper.SetDefault("cloud", "aws")
 	viper.SetDefault("policyFile", "awspolicy.json")
 	viper.SetDefault("urlEscaped", true)
+	viper.SetDefault("decoders", []string{})
+	viper.SetDefault("strict", false)
+	viper.SetDefault("quota", "")
+	viper.SetDefault("limits", map[string]int{})
 	viper.SetDefault("outputFile", "parsed.json")
+	viper.SetDefault("overwrite", false)
+	viper.SetDefault("pretty", false)
 
 	viper.SetConfigName("config") // name of config file (without extension)
 	viper.SetConfigType("yaml")   // REQUIRED if the config file does not have the extension in the name
@@ -51,16 +70,48 @@ func main() {
 
 	log.Debugf("%s", policyText)
 
-	p, err := parser.NewParser(viper.GetString("cloud"), string(policyText), viper.GetBool("urlEscaped"))
+	dec := decoder.FromEscaped(viper.GetBool("urlEscaped"))
+	if names := viper.GetStringSlice("decoders"); len(names) > 0 {
+		dec, err = decoder.NewPipeline(names...)
+		if err != nil {
+			panic(fmt.Errorf("Error configuring decoders: %s", err.Error()))
+		}
+	}
+
+	p, err := parser.NewParser(viper.GetString("cloud"), string(policyText), dec, viper.GetBool("strict"))
 	if err != nil {
 		panic(fmt.Errorf("Error instantiating parser: %s", err.Error()))
 	}
 
+	if l, ok := p.(parser.Limited); ok {
+		limits := map[string]int{}
+		if err = viper.UnmarshalKey("limits", &limits); err != nil {
+			panic(fmt.Errorf("Error configuring limits: %s", err.Error()))
+		}
+		if err = l.SetLimits(limits); err != nil {
+			panic(fmt.Errorf("Error configuring limits: %s", err.Error()))
+		}
+		if quota := viper.GetString("quota"); quota != "" {
+			if err = l.SetQuota(quota); err != nil {
+				panic(fmt.Errorf("Error configuring quota: %s", err.Error()))
+			}
+		}
+	}
+
 	err = p.Parse()
 	if err != nil {
 		panic(fmt.Errorf("Error parsing the policy: %s", err.Error()))
 	}
 
+	for _, w := range p.Warnings() {
+		log.Warnf("%s", w.Error())
+	}
+
+	err = p.Validate()
+	if err != nil {
+		log.Warnf("Policy is not valid: %s", err.Error())
+	}
+
 	policies, err := p.GetPolicy()
 	if err != nil {
 		panic(fmt.Errorf("Error writing the output file: %s", err.Error()))
@@ -75,7 +126,8 @@ func main() {
 		panic(fmt.Errorf("Error marshaling to json: %s", err.Error()))
 	}
 	log.Debugf("Json: \n%s", string(j))
-	err = p.WriteJson(viper.GetString("outputFile"))
+	w := &writer.Writer{Overwrite: viper.GetBool("overwrite"), Pretty: viper.GetBool("pretty")}
+	err = w.WriteJson(viper.GetString("outputFile"), policy.NewEnvelope(viper.GetString("cloud"), policies))
 	if err != nil {
 		panic(fmt.Errorf("Error writing json to file: %s", err.Error()))
 	}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/aumahesh/policyparser/pkg/schema"
+)
+
+// schemaCommand prints the JSON Schema of the parser's output, or validates
+// outputs against it:
+//
+//	parser schema
+//	parser schema parsed.json...
+func schemaCommand(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	fs.Usage = usage(fs, "[parsed.json...]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(schema.Envelope())
+	}
+
+	invalid := 0
+	for _, path := range fs.Args() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		errs := schema.Validate(data)
+		for _, e := range errs {
+			fmt.Printf("%s: %s\n", path, e.Error())
+		}
+		if len(errs) > 0 {
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d files do not match the schema", invalid, fs.NArg())
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aumahesh/policyparser/pkg/pdp"
+	"github.com/aumahesh/policyparser/pkg/server"
+)
+
+// serveCommand serves parse, validate, convert and evaluate over HTTP
+// until interrupted, and with -policies decides requests as a policy
+// decision point:
+//
+//	parser serve -addr :8080 [-policies dir]
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	maxBytes := fs.Int64("max-bytes", server.DefaultMaxBytes, "largest request body, and decompressed document, accepted")
+	policies := fs.String("policies", "", "directory of policies to decide requests against on /decide, reloaded on change")
+	logic := fs.String("logic", pdp.LogicAws, "how /decide combines the policies, aws or single")
+	fs.Usage = usage(fs, "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts.apply()
+
+	serverOpts := server.Options{
+		MaxBytes: *maxBytes,
+		Provider: opts.cloud,
+		Decoders: splitList(opts.decoders),
+		Strict:   opts.strict,
+	}
+	watching := make(chan struct{})
+	defer close(watching)
+	if *policies != "" {
+		p, err := pdp.New(pdp.Options{
+			Dir:      *policies,
+			Provider: opts.cloud,
+			Decoders: splitList(opts.decoders),
+			Strict:   opts.strict,
+			Logic:    *logic,
+		})
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := p.Watch(watching, nil); err != nil {
+				log.Errorf("watching %s: %s", *policies, err.Error())
+			}
+		}()
+		serverOpts.PDP = p
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(serverOpts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	done := make(chan error, 1)
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		done <- srv.Shutdown(ctx)
+	}()
+
+	log.Warnf("listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-done
+}
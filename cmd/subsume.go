@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/aumahesh/policyparser/pkg/subsume"
+)
+
+// subsumeCommand fails unless the candidate policy grants nothing the
+// baseline does not, for use as a CI gate:
+//
+//	parser subsume [flags] baseline.json candidate.json
+func subsumeCommand(args []string) error {
+	fs := flag.NewFlagSet("subsume", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	format := fs.String("format", "text", "output format, text or json")
+	fs.Usage = usage(fs, "baseline.json candidate.json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("need the baseline and the candidate policy file")
+	}
+	opts.apply()
+
+	baseline, err := opts.parseFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	candidate, err := opts.parseFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	r := subsume.Check(baseline, candidate)
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	case "text":
+		for _, ce := range r.Counterexamples {
+			fmt.Printf("%s grants principal=%q action=%q resource=%q%s: %s\n",
+				ce.GrantedBy, ce.Principal, ce.Action, ce.Resource, variables(ce.Variables), ce.Reason)
+		}
+		for _, id := range r.Unproven {
+			fmt.Printf("%s may grant access the baseline does not\n", id)
+		}
+	default:
+		return fmt.Errorf("%s is not a supported format", *format)
+	}
+
+	if !r.Subsumed {
+		return fmt.Errorf("%s grants access %s does not", fs.Arg(1), fs.Arg(0))
+	}
+	return nil
+}
+
+// variables renders the policy variables of a counterexample, sorted by
+// name, e.g. ` with ${aws:username}="guest"`.
+func variables(vars map[string]string) string {
+	names := []string{}
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := ""
+	for i, name := range names {
+		if i == 0 {
+			s += " with"
+		}
+		s += fmt.Sprintf(" ${%s}=%q", name, vars[name])
+	}
+	return s
+}
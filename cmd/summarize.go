@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/catalog"
+	"github.com/aumahesh/policyparser/pkg/summary"
+)
+
+// summarizeCommand prints the access levels a policy grants per service:
+//
+//	parser summarize [flags] policy.json
+func summarizeCommand(args []string) error {
+	fs := flag.NewFlagSet("summarize", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	format := fs.String("format", "text", "output format, text or json")
+	catalogFile := fs.String("catalog", "", "action catalog to use instead of the embedded one")
+	fs.Usage = usage(fs, "policy.json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("need a policy file")
+	}
+	opts.apply()
+
+	c, err := loadCatalog(*catalogFile)
+	if err != nil {
+		return err
+	}
+	policies, err := opts.parseFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s := summary.Summarize(policies, c)
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case "text":
+		printSummary(os.Stdout, s)
+		return nil
+	}
+	return fmt.Errorf("%s is not a supported format", *format)
+}
+
+// loadCatalog returns the catalog in filename, or the embedded one.
+func loadCatalog(filename string) (*catalog.Catalog, error) {
+	if filename == "" {
+		return catalog.Default(), nil
+	}
+	return catalog.Load(filename)
+}
+
+func printSummary(w io.Writer, s *summary.Summary) {
+	for _, svc := range s.Services {
+		name := svc.Name
+		if name == "" {
+			name = svc.Prefix
+		}
+		fmt.Fprintf(w, "%s %s (%s)\n", svc.Effect, name, svc.Prefix)
+		for _, l := range svc.Levels {
+			fmt.Fprintf(w, "  %s: %s (%d/%d)", l.Level, l.Access, l.Granted, l.Total)
+			if len(l.Actions) > 0 {
+				fmt.Fprintf(w, " %s", strings.Join(l.Actions, ", "))
+			}
+			fmt.Fprintln(w)
+		}
+		for _, r := range svc.Resources {
+			fmt.Fprintf(w, "  resource: %s\n", r)
+		}
+		for _, r := range svc.NotResources {
+			fmt.Fprintf(w, "  not-resource: %s\n", r)
+		}
+		for _, c := range svc.Conditions {
+			fmt.Fprintf(w, "  condition: %s\n", c)
+		}
+	}
+	for _, u := range s.Unrecognized {
+		fmt.Fprintf(w, "%s %s: not in the action catalog (%s)\n", u.Effect, u.Action, u.Statement)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/aumahesh/policyparser/pkg/catalog"
+	"github.com/aumahesh/policyparser/pkg/parser"
+	"github.com/aumahesh/policyparser/pkg/summary"
+)
+
+// watchCommand re-parses and re-validates a policy file every time it is
+// saved, until interrupted:
+//
+//	parser watch policy.json
+func watchCommand(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	catalogFile := fs.String("catalog", "", "action catalog to use instead of the embedded one")
+	fs.Usage = usage(fs, "policy.json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("need a policy file")
+	}
+	opts.apply()
+	if !opts.verbose {
+		// check prints the errors the parser would log
+		log.SetLevel(log.FatalLevel)
+	}
+	path := fs.Arg(0)
+
+	c, err := loadCatalog(*catalogFile)
+	if err != nil {
+		return err
+	}
+
+	// editors often save by replacing the file, so watch its directory
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err = w.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	check(os.Stdout, opts, path, c)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event := <-w.Events:
+			if filepath.Clean(event.Name) == filepath.Clean(path) && event.Op&fsnotify.Chmod == 0 {
+				settled = time.After(100 * time.Millisecond)
+			}
+		case err := <-w.Errors:
+			fmt.Fprintf(os.Stderr, "watching %s: %s\n", path, err.Error())
+		case <-settled:
+			settled = nil
+			check(os.Stdout, opts, path, c)
+		}
+	}
+}
+
+// check parses and validates the policy file at path and prints its
+// errors, warnings and a summary of the access it grants.
+func check(w io.Writer, opts *parseOptions, path string, c *catalog.Catalog) {
+	fmt.Fprintf(w, "\n── %s %s\n", time.Now().Format("15:04:05"), path)
+
+	p, err := opts.parse(path)
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err.Error())
+		return
+	}
+	errs := parser.Errors(p.Validate())
+	for _, e := range errs {
+		fmt.Fprintf(w, "error: %s\n", e.Error())
+	}
+	warnings := p.Warnings()
+	for _, e := range warnings {
+		fmt.Fprintf(w, "warning: %s\n", e.Error())
+	}
+
+	policies, err := p.GetPolicy()
+	if err != nil {
+		fmt.Fprintf(w, "error: %s\n", err.Error())
+		return
+	}
+	fmt.Fprintf(w, "%d statements, %d errors, %d warnings\n", len(policies), len(errs), len(warnings))
+	printSummary(w, summary.Summarize(policies, c))
+}
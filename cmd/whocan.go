@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/access"
+)
+
+// whoCanCommand lists the principals allowed to perform an action on a
+// resource:
+//
+//	parser who-can -action s3:GetObject -resource arn:aws:s3:::data/* [kind:]policy.json...
+func whoCanCommand(args []string) error {
+	fs := flag.NewFlagSet("who-can", flag.ContinueOnError)
+	opts := &parseOptions{}
+	opts.register(fs)
+	format := fs.String("format", "text", "output format, text or json")
+	action := fs.String("action", "", "action, e.g. s3:GetObject")
+	resource := fs.String("resource", "*", "resource arn or glob")
+	fs.Usage = usage(fs, "[kind:]policy.json...\n\nkind is one of "+strings.Join(kinds, ", ")+", overriding the detected kind")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *action == "" {
+		fs.Usage()
+		return fmt.Errorf("need an action and at least one policy file")
+	}
+	opts.apply()
+
+	policies, err := opts.parseFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	r := access.WhoCan(policies, *action, *resource)
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "text":
+		printAccess(os.Stdout, r)
+		return nil
+	}
+	return fmt.Errorf("%s is not a supported format", *format)
+}
+
+func printAccess(w io.Writer, r *access.Result) {
+	if len(r.Grants) == 0 {
+		fmt.Fprintf(w, "no principal can perform %s on %s\n", r.Action, r.Resource)
+	}
+	for _, g := range r.Grants {
+		notes := []string{}
+		if len(g.Except) > 0 {
+			notes = append(notes, "except "+strings.Join(g.Except, ", "))
+		}
+		if g.Partial {
+			notes = append(notes, "on part of the resource")
+		}
+		if g.Conditional {
+			notes = append(notes, "under conditions")
+		}
+		if len(g.LimitedBy) > 0 {
+			notes = append(notes, "limited by "+strings.Join(g.LimitedBy, ", "))
+		}
+		line := fmt.Sprintf("%s: %s", g.Principal, strings.Join(g.Statements, ", "))
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, "; ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, d := range r.Denied {
+		fmt.Fprintf(w, "%s: granted by %s, denied by %s\n",
+			d.Principal, strings.Join(d.GrantedBy, ", "), strings.Join(d.DeniedBy, ", "))
+	}
+}
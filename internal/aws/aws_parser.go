@@ -3,58 +3,100 @@ package aws
 import (
 	"encoding/json"
 	"fmt"
-	"net/url"
-	"os"
+	"regexp"
 	"strings"
 
 	"github.com/alecthomas/participle/v2"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/aumahesh/policyparser/pkg/decoder"
 	"github.com/aumahesh/policyparser/pkg/policy"
 )
 
+var accountIdPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// Provider is the cloud provider this package parses the policies of.
+const Provider = "aws"
+
 type AwsParser struct {
 	policyText string
-	awsPolicy  *AwsPolicy
+	documents  []*document
 	policies   []*policy.Policy
+	warnings   ValidationErrors
+	strict     bool
+	limits     map[string]int
+	quota      string
 	parsed     bool
 	error      error
 }
 
-func NewAwsPolicyParser(policyText string, escaped bool) (*AwsParser, error) {
+// NewAwsPolicyParser returns a parser for policyText, decoded with dec. In
+// strict mode a condition whose values do not fit its operator fails the
+// parse; otherwise it is kept as written and reported by Warnings.
+func NewAwsPolicyParser(policyText string, dec decoder.Decoder, strict bool) (*AwsParser, error) {
 	var err error
 	pt := policyText
-	if escaped {
-		pt, err = url.QueryUnescape(policyText)
+	if dec != nil {
+		pt, err = dec.Decode(policyText)
 		if err != nil {
 			return nil, err
 		}
 	}
 	// log.Debugf("/n%s", pt)
+	docs, err := extractDocuments(pt, decoder.MaxBytes(dec))
+	if err != nil {
+		return nil, err
+	}
 	return &AwsParser{
 		policyText: pt,
-		awsPolicy:  &AwsPolicy{},
+		documents:  docs,
+		strict:     strict,
+		limits:     DefaultLimits(),
 		parsed:     false,
 		error:      nil,
 	}, nil
 }
 
 func (a *AwsParser) Parse() error {
-	parser := participle.MustBuild(a.awsPolicy,
+	parser := participle.MustBuild(&AwsPolicy{},
 		participle.UseLookahead(2),
 	)
-	err := parser.ParseString("", a.policyText, a.awsPolicy, participle.AllowTrailing(true))
-	// repr.Println(a.awsPolicy, repr.Hide(&lexer.Position{}))
-
-	if err == nil {
-		a.parsed = true
-		a.constructPolicy()
-	} else {
-		perr := err.(participle.UnexpectedTokenError)
-		log.Errorf("Error parsing policy: %s : %s", perr.Error(), perr.Unexpected.Pos.String())
-		a.error = err
+	for _, doc := range a.documents {
+		doc.awsPolicy = &AwsPolicy{}
+		err := parser.ParseString("", doc.text, doc.awsPolicy, participle.AllowTrailing(true))
+		// repr.Println(doc.awsPolicy, repr.Hide(&lexer.Position{}))
+
+		if err != nil {
+			if perr, ok := err.(participle.UnexpectedTokenError); ok {
+				log.Errorf("Error parsing policy: %s : %s", perr.Error(), perr.Unexpected.Pos.String())
+			} else {
+				log.Errorf("Error parsing policy: %s", err.Error())
+			}
+			if doc.origin != nil && doc.origin.Name != "" {
+				err = fmt.Errorf("%s policy %s: %w", doc.origin.Type, doc.origin.Name, err)
+			}
+			a.error = err
+			return err
+		}
+		doc.kind = detectKind(doc)
+	}
+
+	a.constructPolicy()
+	if a.strict && len(a.warnings) > 0 {
+		a.error = a.warnings
+		return a.error
+	}
+	a.parsed = true
+	return nil
+}
+
+// Warnings returns the problems found while parsing in lenient mode.
+func (a *AwsParser) Warnings() []error {
+	x := []error{}
+	for _, w := range a.warnings {
+		x = append(x, w)
 	}
-	return err
+	return x
 }
 
 func (a *AwsParser) GetPolicy() ([]*policy.Policy, error) {
@@ -67,47 +109,56 @@ func (a *AwsParser) GetPolicy() ([]*policy.Policy, error) {
 	return nil, fmt.Errorf("did not parse")
 }
 
+// Json returns the parsed policies in their Envelope.
 func (a *AwsParser) Json() ([]byte, error) {
 	if a.parsed && a.policies != nil {
-		return json.Marshal(a.policies)
+		return json.Marshal(policy.NewEnvelope(Provider, a.policies))
 	}
 	return nil, fmt.Errorf("no policies parsed yet")
 }
 
-func (a *AwsParser) WriteJson(filename string) error {
-	if a.parsed && a.policies != nil {
-		if _, err := os.Stat(filename); err == nil {
-			return fmt.Errorf("File exists: %s", filename)
-		}
-		f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0666)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		enc := json.NewEncoder(f)
-		enc.SetEscapeHTML(false)
-		return enc.Encode(a.policies)
+func (a *AwsParser) constructPolicy() {
+	a.policies = []*policy.Policy{}
+	a.warnings = ValidationErrors{}
+
+	for _, doc := range a.documents {
+		policies, warnings := a.constructDocument(doc)
+		a.policies = append(a.policies, policies...)
+		a.warnings = append(a.warnings, warnings...)
 	}
-	return fmt.Errorf("no policies parsed yet")
 }
 
-func (a *AwsParser) constructPolicy() {
-	if a.awsPolicy == nil {
-		return
+func (a *AwsParser) constructDocument(doc *document) ([]*policy.Policy, ValidationErrors) {
+	if doc.awsPolicy == nil || doc.awsPolicy.Block == nil {
+		return nil, nil
 	}
 
-	a.policies = []*policy.Policy{}
+	policies := []*policy.Policy{}
+	warnings := ValidationErrors{}
 
-	id := StringValue(a.awsPolicy.Block.Id)
-	version := StringValue(a.awsPolicy.Block.Version)
+	id := StringValue(doc.awsPolicy.Block.Id)
+	if id == "" && doc.origin != nil {
+		id = doc.origin.Name
+	}
+	if o := doc.origin; o != nil && o.Type == policy.OriginInline && len(o.AttachedTo) == 1 {
+		// inline policies are named per identity: same-named ones of
+		// different identities need distinct ids
+		id = o.AttachedTo[0].Type + "/" + o.AttachedTo[0].Name + "/" + id
+	}
+	version := StringValue(doc.awsPolicy.Block.Version)
 
-	for index, statement := range a.awsPolicy.Block.Statement {
+	for index, statement := range doc.awsPolicy.Block.Statement {
 		pol := &policy.Policy{
 			Id:      fmt.Sprintf("%s:%d", id, index),
 			Version: version,
+			Kind:    doc.kind,
+			Origin:  doc.origin,
 		}
 
 		for _, element := range statement.Elements {
+			if element.Sid != nil {
+				pol.Sid = StringValue(element.Sid)
+			}
 			if element.Effect != nil {
 				effect := StringValue(element.Effect)
 				switch strings.ToLower(effect) {
@@ -118,59 +169,48 @@ func (a *AwsParser) constructPolicy() {
 				}
 			}
 			if element.Action != nil {
-				pol.Actions = a.getAnyOrList(element.Action)
+				pol.Actions, pol.Patterns.Actions = policy.NewVersionPatterns(version, a.getAnyOrList(element.Action), true)
 			}
 			if element.NotAction != nil {
-				pol.NotActions = a.getAnyOrList(element.NotAction)
+				pol.NotActions, pol.Patterns.NotActions = policy.NewVersionPatterns(version, a.getAnyOrList(element.NotAction), true)
 			}
 			if element.Resource != nil {
-				pol.Resources = a.getAnyOrList(element.Resource)
+				pol.Resources, pol.Patterns.Resources = policy.NewVersionPatterns(version, a.getAnyOrList(element.Resource), false)
 			}
 			if element.NotResource != nil {
-				pol.NotResources = a.getAnyOrList(element.NotResource)
+				pol.NotResources, pol.Patterns.NotResources = policy.NewVersionPatterns(version, a.getAnyOrList(element.NotResource), false)
 			}
 			if element.Principal != nil {
-				pol.Subjects = a.getSubjects(element.Principal)
+				pol.Subjects, pol.Patterns.Subjects = policy.NewVersionPatterns(version, a.getSubjects(element.Principal), false)
 			}
 			if element.NotPrincipal != nil {
-				pol.NotSubjects = a.getSubjects(element.NotPrincipal)
+				pol.NotSubjects, pol.Patterns.NotSubjects = policy.NewVersionPatterns(version, a.getSubjects(element.NotPrincipal), false)
 			}
 			if element.Condition != nil {
-				pol.Condition = a.getCondition(element.Condition)
+				var errs ValidationErrors
+				pol.Condition, errs = a.getCondition(element.Condition)
+				for _, e := range errs {
+					e.Policy = documentName(doc)
+					e.Statement = index
+					warnings = append(warnings, e)
+				}
 			}
 		}
 
-		a.policies = append(a.policies, pol)
+		pol.Variables = policy.CollectVariables(pol)
+
+		policies = append(policies, pol)
 	}
+
+	return policies, warnings
 }
 
+// getAnyOrList returns the globs in l, "*" standing for the wildcard.
 func (a *AwsParser) getAnyOrList(l *AnyOrList) []string {
 	if l == nil {
 		return []string{}
 	}
-	if l.Item != nil {
-		if l.Item.Any {
-			return []string{"<.*>"}
-		}
-		if l.Item.One != nil {
-			vs := StringValue(l.Item.One)
-			return []string{strings.ReplaceAll(vs, "*", "<.*>")}
-		}
-	}
-	if l.List != nil {
-		x := []string{}
-		for _, item := range l.List {
-			if item.Any {
-				x = append(x, "<.*>")
-			}
-			if item.One != nil {
-				vs := StringValue(item.One)
-				x = append(x, strings.ReplaceAll(vs, "*", "<.*>"))
-			}
-		}
-		return x
-	}
-	return []string{}
+	return anyOrListValues(l)
 }
 
 func (a *AwsParser) getSubjects(p *Principal) []string {
@@ -178,13 +218,19 @@ func (a *AwsParser) getSubjects(p *Principal) []string {
 		return []string{}
 	}
 	if p.Any {
-		return []string{"<.*>"}
+		return []string{"*"}
 	}
 	x := []string{}
 	if p.List != nil {
 		for _, item := range p.List {
 			if item.Aws != nil {
-				x = append(x, a.getAnyOrList(item.Aws)...)
+				for _, s := range a.getAnyOrList(item.Aws) {
+					// {"AWS": "*"} is the same as "Principal": "*"
+					if s == "*" {
+						return []string{"*"}
+					}
+					x = append(x, canonicalAwsPrincipal(s))
+				}
 			}
 			if item.Federated != nil {
 				x = append(x, a.getAnyOrList(item.Federated)...)
@@ -201,105 +247,67 @@ func (a *AwsParser) getSubjects(p *Principal) []string {
 	return x
 }
 
-func (a *AwsParser) getCondition(c *Condition) []policy.Condition {
+// canonicalAwsPrincipal rewrites a bare account id into the root arn of the
+// account, the same way AWS does when it stores the policy.
+func canonicalAwsPrincipal(p string) string {
+	if accountIdPattern.MatchString(p) {
+		return fmt.Sprintf("arn:aws:iam::%s:root", p)
+	}
+	return p
+}
+
+// getCondition returns the conditions in c. Values that cannot be
+// interpreted for their operator make the parse fail in strict mode; in
+// lenient mode the condition is kept with the values as written and a
+// warning is returned. Such a condition may never hold: it narrows an Allow
+// statement, but a Deny statement may then fail to apply, so the warnings
+// of a lenient parse need checking before relying on its denies.
+func (a *AwsParser) getCondition(c *Condition) ([]policy.Condition, ValidationErrors) {
 	if c == nil {
-		return nil
+		return nil, nil
 	}
 
 	cm := []policy.Condition{}
+	errs := ValidationErrors{}
 
 	for _, cc := range c.ConditionList {
 		op := StringValue(cc.Operation)
-		if op == "" {
-			continue
-		}
-		if cc.KeyValueList == nil {
-			continue
-		}
-		ck := StringValue(cc.KeyValueList.Key)
-		if ck == "" {
-			continue
-		}
-		valType := ""
-		var val interface{}
-		if cc.KeyValueList.Value == nil {
-			continue
-		}
-		if cc.KeyValueList.Value.One != nil {
-			if cc.KeyValueList.Value.One.OneString != nil {
-				x := []string{StringValue(cc.KeyValueList.Value.One.OneString)}
-				val = x
-				valType = "string"
-			}
-			if cc.KeyValueList.Value.One.OneNumber != nil {
-				x := []int64{Int64Value(cc.KeyValueList.Value.One.OneNumber)}
-				val = x
-				valType = "int64"
-			}
-			if cc.KeyValueList.Value.One.BoolTrue != nil {
-				x := []bool{true}
-				val = x
-				valType = "bool"
+		for _, kv := range cc.KeyValueList {
+			ck := StringValue(kv.Key)
+			if op == "" || ck == "" {
+				errs = append(errs, &ValidationError{
+					Pos:     kv.Pos,
+					Message: fmt.Sprintf("condition %q on %q needs an operator and a key", op, ck),
+				})
+				continue
 			}
-			if cc.KeyValueList.Value.One.BoolFalse != nil {
-				x := []bool{false}
-				val = x
-				valType = "bool"
+
+			raw := []rawValue{}
+			if kv.Value.One != nil {
+				raw = append(raw, newRawValue(kv.Value.One))
 			}
-		}
-		if cc.KeyValueList.Value.List != nil {
-			valType = ""
-			mixedTypes := false
-			sl := []string{}
-			il := []int64{}
-			bl := []bool{}
-			for _, v := range cc.KeyValueList.Value.List {
-				ctype := ""
-				if v.OneString != nil {
-					sl = append(sl, StringValue(v.OneString))
-					ctype = "string"
-				}
-				if v.OneNumber != nil {
-					il = append(il, Int64Value(v.OneNumber))
-					ctype = "int64"
-				}
-				if v.BoolTrue != nil {
-					bl = append(bl, true)
-					ctype = "bool"
-				}
-				if v.BoolFalse != nil {
-					bl = append(bl, false)
-					ctype = "bool"
-				}
-				if valType == "" {
-					valType = ctype
-				}
-				if valType != ctype {
-					mixedTypes = true
-					break
-				}
+			for _, v := range kv.Value.List {
+				raw = append(raw, newRawValue(v))
 			}
-			if mixedTypes {
-				continue
+
+			val, err := interpretValues(operatorFamily(op), raw)
+			if err != nil {
+				errs = append(errs, &ValidationError{
+					Pos:     kv.Pos,
+					Message: fmt.Sprintf("condition %s on %s: %s", op, ck, err.Error()),
+				})
+				val = policy.StringValues(rawStrings(raw)...)
 			}
-			switch valType {
-			case "string":
-				val = sl
-			case "int64":
-				val = il
-			case "bool":
-				val = bl
+
+			cp := policy.Condition{
+				Operation: op,
+				Key:       ck,
+				Value:     val,
 			}
-		}
-		cp := policy.Condition{
-			Operation: op,
-			Key:       ck,
-			Value:     val,
-			Type:      valType,
-		}
 
-		cm = append(cm, cp)
+			cm = append(cm, cp)
+		}
 	}
 
-	return cm
+	return cm, errs
 }
@@ -1,10 +1,17 @@
 package aws
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/decoder"
+	"github.com/aumahesh/policyparser/pkg/policy"
 )
 
 func TestAwsParser_Parse(t *testing.T) {
@@ -25,7 +32,7 @@ func TestAwsParser_Parse(t *testing.T) {
     }
   ]
 }`
-	a, err := NewAwsPolicyParser(policyText, false)
+	a, err := NewAwsPolicyParser(policyText, nil, false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -79,7 +86,7 @@ func TestAwsParser_Parse2(t *testing.T) {
     }
   ]
 }`
-	a, err := NewAwsPolicyParser(policyText, false)
+	a, err := NewAwsPolicyParser(policyText, nil, false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -141,7 +148,7 @@ func TestAwsParser_Parse3(t *testing.T) {
   ]
 }`
 
-	a, err := NewAwsPolicyParser(policyText, false)
+	a, err := NewAwsPolicyParser(policyText, nil, false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -183,9 +190,9 @@ func TestAwsParser_Parse3(t *testing.T) {
 
 	assert.EqualValues(t, "StringNotEquals", policies[0].Condition[0].Operation)
 	assert.EqualValues(t, "aws:PrincipalOrgMasterAccountId", policies[0].Condition[0].Key)
-	assert.Len(t, policies[0].Condition[0].Value, 1)
-	assert.EqualValues(t, "string", policies[0].Condition[0].Type)
-	vs := policies[0].Condition[0].Value.([]string)
+	assert.EqualValues(t, 1, policies[0].Condition[0].Value.Len())
+	assert.EqualValues(t, "string", policies[0].Condition[0].Value.Type())
+	vs := policies[0].Condition[0].Value.Strings()
 	assert.EqualValues(t, "${aws:PrincipalAccount}", vs[0])
 }
 
@@ -214,7 +221,7 @@ func TestAwsParser_Parse4(t *testing.T) {
   ]
 }`
 
-	a, err := NewAwsPolicyParser(policyText, false)
+	a, err := NewAwsPolicyParser(policyText, nil, false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -251,16 +258,16 @@ func TestAwsParser_Parse4(t *testing.T) {
 
 	assert.EqualValues(t, "StringEquals", policies[0].Condition[0].Operation)
 	assert.EqualValues(t, "cognito-identity.amazonaws.com:aud", policies[0].Condition[0].Key)
-	assert.Len(t, policies[0].Condition[0].Value, 1)
-	assert.EqualValues(t, "string", policies[0].Condition[0].Type)
-	vs := policies[0].Condition[0].Value.([]string)
+	assert.EqualValues(t, 1, policies[0].Condition[0].Value.Len())
+	assert.EqualValues(t, "string", policies[0].Condition[0].Value.Type())
+	vs := policies[0].Condition[0].Value.Strings()
 	assert.EqualValues(t, "us-west-2:7e9abc23-035e-49e7-a54a-2f850581930c", vs[0])
 
 	assert.EqualValues(t, "ForAnyValue:StringLike", policies[0].Condition[1].Operation)
 	assert.EqualValues(t, "cognito-identity.amazonaws.com:amr", policies[0].Condition[1].Key)
-	assert.Len(t, policies[0].Condition[1].Value, 1)
-	assert.EqualValues(t, "string", policies[0].Condition[1].Type)
-	vs = policies[0].Condition[1].Value.([]string)
+	assert.EqualValues(t, 1, policies[0].Condition[1].Value.Len())
+	assert.EqualValues(t, "string", policies[0].Condition[1].Value.Type())
+	vs = policies[0].Condition[1].Value.Strings()
 	assert.EqualValues(t, "authenticated", vs[0])
 }
 
@@ -308,7 +315,7 @@ func TestAwsParser_Parse5(t *testing.T) {
 		}
 	*/
 
-	a, err := NewAwsPolicyParser(encodedText, true)
+	a, err := NewAwsPolicyParser(encodedText, decoder.FromEscaped(true), false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -345,7 +352,7 @@ func TestAwsParser_Parse6(t *testing.T) {
 
 	encodedText := `%7B%0A%20%20%20%20%22Version%22%3A%20%222012-10-17%22%2C%0A%20%20%20%20%22Statement%22%3A%20%5B%0A%20%20%20%20%20%20%20%20%7B%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Effect%22%3A%20%22Allow%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Action%22%3A%20%5B%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22ec2%3ADescribeSpotFleetRequests%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22ec2%3AModifySpotFleetRequest%22%0A%20%20%20%20%20%20%20%20%20%20%20%20%5D%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Resource%22%3A%20%5B%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22%2A%22%0A%20%20%20%20%20%20%20%20%20%20%20%20%5D%0A%20%20%20%20%20%20%20%20%7D%2C%0A%20%20%20%20%20%20%20%20%7B%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Effect%22%3A%20%22Allow%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Action%22%3A%20%5B%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22cloudwatch%3ADescribeAlarms%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22cloudwatch%3APutMetricAlarm%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22cloudwatch%3ADeleteAlarms%22%0A%20%20%20%20%20%20%20%20%20%20%20%20%5D%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Resource%22%3A%20%5B%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22%2A%22%0A%20%20%20%20%20%20%20%20%20%20%20%20%5D%0A%20%20%20%20%20%20%20%20%7D%2C%0A%20%20%20%20%20%20%20%20%7B%20%0A%20%20%20%20%20%20%20%20%20%20%22Action%22%3A%20%22iam%3ACreateServiceLinkedRole%22%2C%20%0A%20%20%20%20%20%20%20%20%20%20%22Effect%22%3A%20%22Allow%22%2C%20%0A%20%20%20%20%20%20%20%20%20%20%22Resource%22%3A%20%22arn%3Aaws%3Aiam%3A%3A%2A%3Arole%2Faws-service-role%2Fec2.application-autoscaling.amazonaws.com%2FAWSServiceRoleForApplicationAutoScaling_EC2SpotFleetRequest%22%2C%20%0A%20%20%20%20%20%20%20%20%20%20%22Condition%22%3A%20%7B%20%0A%20%20%20%20%20%20%20%20%20%20%20%20%22StringLike%22%3A%20%7B%20%0A%20%20%20%20%20%20%20%20%20%20%20%20%20%20%22iam%3AAWSServiceName%22%3A%20%22ec2.application-autoscaling.amazonaws.com%22%20%0A%20%20%20%20%20%20%20%20%20%20%20%20%7D%0A%20%20%20%20%20%20%20%20%20%20%7D%0A%20%20%20%20%20%20%20%20%7D%20%0A%20%20%20%20%5D%0A%7D`
 
-	a, err := NewAwsPolicyParser(encodedText, true)
+	a, err := NewAwsPolicyParser(encodedText, decoder.FromEscaped(true), false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -382,7 +389,7 @@ func TestAwsParser_Parse7(t *testing.T) {
 
 	encodedText := `%7B%0A%20%20%20%20%22Version%22%3A%20%222012-10-17%22%2C%0A%20%20%20%20%22Statement%22%3A%20%5B%0A%20%20%20%20%20%20%20%20%7B%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Sid%22%3A%20%22VisualEditor0%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Effect%22%3A%20%22Allow%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Action%22%3A%20%22s3%3A%2A%22%2C%0A%20%20%20%20%20%20%20%20%20%20%20%20%22Resource%22%3A%20%22arn%3Aaws%3As3%3A%3A%3Abcone-us-west-2-employee%22%0A%20%20%20%20%20%20%20%20%7D%0A%20%20%20%20%5D%0A%7D`
 
-	a, err := NewAwsPolicyParser(encodedText, true)
+	a, err := NewAwsPolicyParser(encodedText, decoder.FromEscaped(true), false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -422,7 +429,7 @@ func TestAwsParser_Parse8(t *testing.T) {
     }
   ]
 }`
-	a, err := NewAwsPolicyParser(policyText, false)
+	a, err := NewAwsPolicyParser(policyText, nil, false)
 	assert.Nil(t, err)
 	if err != nil {
 		t.FailNow()
@@ -459,9 +466,901 @@ func TestAwsParser_Parse8(t *testing.T) {
 
 	assert.EqualValues(t, "True", policies[0].Condition[0].Operation)
 	assert.EqualValues(t, "mfaAuthenticated", policies[0].Condition[0].Key)
-	assert.Len(t, policies[0].Condition[0].Value, 2)
-	assert.EqualValues(t, "bool", policies[0].Condition[0].Type)
-	vs := policies[0].Condition[0].Value.([]bool)
+	assert.EqualValues(t, 2, policies[0].Condition[0].Value.Len())
+	assert.EqualValues(t, "bool", policies[0].Condition[0].Value.Type())
+	vs := policies[0].Condition[0].Value.Bools()
 	assert.EqualValues(t, false, vs[0])
 	assert.EqualValues(t, true, vs[1])
 }
+
+func TestAwsParser_ParsePolicyVersion(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+    "PolicyVersion": {
+        "Document": "%7B%22Version%22%3A%222012-10-17%22%2C%22Statement%22%3A%5B%7B%22Effect%22%3A%22Allow%22%2C%22Action%22%3A%22s3%3AGetObject%22%2C%22Resource%22%3A%22%2A%22%7D%5D%7D",
+        "VersionId": "v2",
+        "IsDefaultVersion": true,
+        "CreateDate": "2020-11-24T18:07:46Z"
+    }
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+	assert.True(t, policies[0].Allowed)
+	assert.EqualValues(t, []string{"s3:GetObject"}, policies[0].Actions)
+	assert.NotNil(t, policies[0].Origin)
+	assert.EqualValues(t, "managed", policies[0].Origin.Type)
+	assert.EqualValues(t, "v2", policies[0].Origin.VersionId)
+}
+
+func TestAwsParser_ParseRolePolicy(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+    "RoleName": "deployer",
+    "PolicyName": "deploy-bucket",
+    "PolicyDocument": {
+        "Version": "2012-10-17",
+        "Statement": [
+            {
+                "Effect": "Allow",
+                "Action": ["s3:PutObject"],
+                "Resource": "arn:aws:s3:::deploy/*"
+            }
+        ]
+    }
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+	assert.EqualValues(t, "role/deployer/deploy-bucket:0", policies[0].Id)
+	assert.EqualValues(t, "inline", policies[0].Origin.Type)
+	assert.EqualValues(t, "deploy-bucket", policies[0].Origin.Name)
+	assert.Len(t, policies[0].Origin.AttachedTo, 1)
+	assert.EqualValues(t, "role", policies[0].Origin.AttachedTo[0].Type)
+	assert.EqualValues(t, "deployer", policies[0].Origin.AttachedTo[0].Name)
+}
+
+func TestAwsParser_ParseBucketPolicy(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+    "Policy": "{\"Version\":\"2012-10-17\",\"Statement\":[{\"Effect\":\"Deny\",\"Principal\":\"*\",\"Action\":\"s3:*\",\"Resource\":\"arn:aws:s3:::logs/*\"}]}"
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+	assert.False(t, policies[0].Allowed)
+	assert.EqualValues(t, "bucket", policies[0].Origin.Type)
+	assert.EqualValues(t, []string{"<.*>"}, policies[0].Subjects)
+}
+
+func TestAwsParser_ParseGetPolicy(t *testing.T) {
+	// get-policy returns the metadata of a managed policy, not its document
+	policyText := `{
+    "Policy": {
+        "PolicyName": "ReadOnly",
+        "Arn": "arn:aws:iam::111122223333:policy/ReadOnly",
+        "DefaultVersionId": "v2",
+        "AttachmentCount": 1
+    }
+}`
+	_, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Contains(t, err.Error(), "get-policy-version")
+	}
+
+	policyText = `{"Policy": {"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::logs/*"}]}}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Nil(t, a.Parse())
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+}
+
+func TestAwsParser_ParseInlinePolicyIds(t *testing.T) {
+	document := `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`
+	policyText := `{
+    "UserDetailList": [{"UserName": "alice", "Arn": "arn:aws:iam::111122223333:user/alice",
+        "UserPolicyList": [{"PolicyName": "s3", "PolicyDocument": ` + document + `}]}],
+    "RoleDetailList": [{"RoleName": "alice", "Arn": "arn:aws:iam::111122223333:role/alice",
+        "RolePolicyList": [{"PolicyName": "s3", "PolicyDocument": ` + document + `}]}]
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Nil(t, a.Parse())
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 2)
+	if len(policies) != 2 {
+		t.FailNow()
+	}
+	assert.EqualValues(t, "user/alice/s3:0", policies[0].Id)
+	assert.EqualValues(t, "role/alice/s3:0", policies[1].Id)
+	assert.EqualValues(t, "s3", policies[1].DocumentName())
+}
+
+func TestAwsParser_ParseAuthorizationDetails(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+    "UserDetailList": [
+        {
+            "UserName": "alice",
+            "Arn": "arn:aws:iam::111122223333:user/alice",
+            "UserPolicyList": [
+                {
+                    "PolicyName": "alice-inline",
+                    "PolicyDocument": {
+                        "Version": "2012-10-17",
+                        "Statement": [{"Effect": "Allow", "Action": "sqs:SendMessage", "Resource": "*"}]
+                    }
+                }
+            ],
+            "GroupList": ["admins"],
+            "AttachedManagedPolicies": [
+                {"PolicyName": "ReadOnly", "PolicyArn": "arn:aws:iam::111122223333:policy/ReadOnly"}
+            ]
+        }
+    ],
+    "GroupDetailList": [
+        {
+            "GroupName": "admins",
+            "Arn": "arn:aws:iam::111122223333:group/admins",
+            "GroupPolicyList": [],
+            "AttachedManagedPolicies": []
+        }
+    ],
+    "RoleDetailList": [
+        {
+            "RoleName": "ci",
+            "Arn": "arn:aws:iam::111122223333:role/ci",
+            "AssumeRolePolicyDocument": {
+                "Version": "2012-10-17",
+                "Statement": [
+                    {"Effect": "Allow", "Principal": {"Service": "ec2.amazonaws.com"}, "Action": "sts:AssumeRole"}
+                ]
+            },
+            "RolePolicyList": [],
+            "AttachedManagedPolicies": [
+                {"PolicyName": "ReadOnly", "PolicyArn": "arn:aws:iam::111122223333:policy/ReadOnly"}
+            ]
+        }
+    ],
+    "Policies": [
+        {
+            "PolicyName": "ReadOnly",
+            "Arn": "arn:aws:iam::111122223333:policy/ReadOnly",
+            "DefaultVersionId": "v2",
+            "PolicyVersionList": [
+                {
+                    "Document": {
+                        "Version": "2012-10-17",
+                        "Statement": [{"Effect": "Allow", "Action": ["s3:Get*", "s3:List*"], "Resource": "*"}]
+                    },
+                    "VersionId": "v2",
+                    "IsDefaultVersion": true
+                },
+                {
+                    "Document": {
+                        "Version": "2012-10-17",
+                        "Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]
+                    },
+                    "VersionId": "v1",
+                    "IsDefaultVersion": false
+                }
+            ]
+        }
+    ]
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+
+	for index, pol := range policies {
+		log.Infof("pol #%d: %+v", index, pol)
+	}
+
+	assert.Len(t, policies, 3)
+	if len(policies) != 3 {
+		t.FailNow()
+	}
+
+	assert.EqualValues(t, "managed", policies[0].Origin.Type)
+	assert.EqualValues(t, "arn:aws:iam::111122223333:policy/ReadOnly", policies[0].Origin.Arn)
+	assert.EqualValues(t, "111122223333", policies[0].Origin.Account)
+	assert.EqualValues(t, "v2", policies[0].Origin.VersionId)
+	assert.Len(t, policies[0].Origin.AttachedTo, 2)
+	assert.EqualValues(t, "alice", policies[0].Origin.AttachedTo[0].Name)
+	assert.EqualValues(t, "ci", policies[0].Origin.AttachedTo[1].Name)
+
+	assert.EqualValues(t, "inline", policies[1].Origin.Type)
+	assert.EqualValues(t, []string{"sqs:SendMessage"}, policies[1].Actions)
+	assert.EqualValues(t, "user", policies[1].Origin.AttachedTo[0].Type)
+
+	assert.EqualValues(t, "trust", policies[2].Origin.Type)
+	assert.EqualValues(t, []string{"ec2.amazonaws.com"}, policies[2].Subjects)
+	assert.EqualValues(t, "ci", policies[2].Origin.AttachedTo[0].Name)
+}
+
+func TestAwsParser_ParseTrustPolicy(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::111122223333:role/ci"},
+      "Action": ["sts:AssumeRole", "sts:TagSession"]
+    },
+    {
+      "Effect": "Allow",
+      "Principal": {"Federated": "arn:aws:iam::111122223333:saml-provider/okta"},
+      "Action": "sts:AssumeRoleWith*"
+    }
+  ]
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+	assert.Nil(t, a.Validate())
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+
+	assert.Len(t, policies, 2)
+	for _, p := range policies {
+		assert.EqualValues(t, policy.KindTrust, p.Kind)
+		assert.Len(t, p.Resources, 0)
+	}
+}
+
+func TestAwsParser_Validate(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+    "RoleName": "ci",
+    "Role": {
+        "RoleName": "ci",
+        "Arn": "arn:aws:iam::111122223333:role/ci",
+        "AssumeRolePolicyDocument": {
+            "Version": "2012-10-17",
+            "Statement": [
+                {
+                    "Effect": "Allow",
+                    "Action": "s3:GetObject",
+                    "Resource": "*"
+                }
+            ]
+        }
+    }
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+	assert.EqualValues(t, policy.KindTrust, policies[0].Kind)
+
+	err = a.Validate()
+	assert.NotNil(t, err)
+	verrs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, verrs, 3)
+	for _, v := range verrs {
+		log.Infof("%s", v.Error())
+		assert.EqualValues(t, 0, v.Statement)
+		assert.EqualValues(t, 5, v.Pos.Line)
+	}
+
+	policyText = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "s3:GetObject"
+    }
+  ]
+}`
+	a, err = NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	assert.Nil(t, a.Parse())
+	err = a.Validate()
+	assert.NotNil(t, err)
+	assert.Len(t, err.(ValidationErrors), 1)
+}
+
+func TestAwsParser_Principals(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	tests := []struct {
+		principal string
+		subjects  []string
+	}{
+		{`"*"`, []string{"<.*>"}},
+		{`{"AWS": "*"}`, []string{"<.*>"}},
+		{`{"AWS": ["*"]}`, []string{"<.*>"}},
+		{`{"AWS": ["arn:aws:iam::123456789012:root", "*"], "Service": "ec2.amazonaws.com"}`, []string{"<.*>"}},
+		{`{"AWS": "123456789012"}`, []string{"arn:aws:iam::123456789012:root"}},
+		{`{"AWS": "arn:aws:iam::123456789012:root"}`, []string{"arn:aws:iam::123456789012:root"}},
+		{
+			`{"AWS": ["arn:aws:iam::123456789012:user/alice", "210987654321"]}`,
+			[]string{"arn:aws:iam::123456789012:user/alice", "arn:aws:iam::210987654321:root"},
+		},
+		{
+			`{"AWS": "arn:aws:sts::123456789012:assumed-role/deployer/session"}`,
+			[]string{"arn:aws:sts::123456789012:assumed-role/deployer/session"},
+		},
+		{`{"Service": ["ec2.amazonaws.com", "lambda.amazonaws.com"]}`, []string{"ec2.amazonaws.com", "lambda.amazonaws.com"}},
+		{`{"Federated": "cognito-identity.amazonaws.com"}`, []string{"cognito-identity.amazonaws.com"}},
+		{
+			`{"Federated": "arn:aws:iam::123456789012:saml-provider/okta"}`,
+			[]string{"arn:aws:iam::123456789012:saml-provider/okta"},
+		},
+		{
+			`{"CanonicalUser": "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be"}`,
+			[]string{"79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be"},
+		},
+		{
+			`{"AWS": "123456789012", "Service": ["ecs-tasks.amazonaws.com"], "Federated": "accounts.google.com"}`,
+			[]string{"arn:aws:iam::123456789012:root", "ecs-tasks.amazonaws.com", "accounts.google.com"},
+		},
+		{`{"AWS": []}`, []string{}},
+	}
+
+	for _, tt := range tests {
+		for _, element := range []string{"Principal", "NotPrincipal"} {
+			policyText := fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "%s": %s,
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::bucket/key"
+    }
+  ]
+}`, element, tt.principal)
+
+			a, err := NewAwsPolicyParser(policyText, nil, false)
+			assert.Nil(t, err)
+			if err != nil {
+				t.FailNow()
+			}
+
+			err = a.Parse()
+			assert.Nil(t, err, tt.principal)
+			if err != nil {
+				continue
+			}
+
+			policies, err := a.GetPolicy()
+			assert.Nil(t, err)
+			assert.Len(t, policies, 1)
+
+			subjects := policies[0].Subjects
+			if element == "NotPrincipal" {
+				subjects = policies[0].NotSubjects
+			}
+			assert.EqualValues(t, tt.subjects, subjects, tt.principal)
+
+			j, err := a.Json()
+			assert.Nil(t, err)
+			roundTrip := &policy.Envelope{}
+			assert.Nil(t, json.Unmarshal(j, roundTrip))
+			assert.EqualValues(t, policy.NewEnvelope(Provider, policies), roundTrip, tt.principal)
+		}
+	}
+}
+
+func TestAwsParser_ParseWildcards(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["s3:Get*", "s3:List?ucket*"],
+      "Resource": ["arn:aws:s3:::my.bucket/logs-20??/*"]
+    }
+  ]
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+
+	assert.EqualValues(t, []string{"s3:Get<.*>", "s3:List<.>ucket<.*>"}, policies[0].Actions)
+	assert.EqualValues(t, []string{"arn:aws:s3:::my.bucket/logs-20<.><.>/<.*>"}, policies[0].Resources)
+
+	assert.Len(t, policies[0].Patterns.Actions, 2)
+	assert.EqualValues(t, "s3:List?ucket*", policies[0].Patterns.Actions[1].Glob)
+	assert.EqualValues(t, `(?i)^s3:List.ucket.*$`, policies[0].Patterns.Actions[1].Regex)
+	assert.Len(t, policies[0].Patterns.Resources, 1)
+	assert.EqualValues(t, "arn:aws:s3:::my.bucket/logs-20??/*", policies[0].Patterns.Resources[0].Glob)
+	assert.EqualValues(t, `^arn:aws:s3:::my\.bucket/logs-20../.*$`, policies[0].Patterns.Resources[0].Regex)
+}
+
+func TestAwsParser_ParseVariables(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::home/${aws:username}/*", "arn:aws:s3:::literal/${*}"],
+      "Condition": {
+        "StringEquals": {
+          "s3:prefix": "${aws:PrincipalTag/team}"
+        }
+      }
+    }
+  ]
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+
+	assert.EqualValues(t, []string{"arn:aws:s3:::home/${aws:username}/<.*>", "arn:aws:s3:::literal/*"}, policies[0].Resources)
+	assert.EqualValues(t, []policy.Variable{
+		{Name: "aws:username", Field: policy.FieldResources},
+		{Name: "aws:PrincipalTag/team", Field: policy.FieldConditions},
+	}, policies[0].Variables)
+
+	r, err := policies[0].Resolve(map[string]string{"aws:username": "alice", "aws:PrincipalTag/team": "blue"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, "arn:aws:s3:::home/alice/<.*>", r.Resources[0])
+	assert.EqualValues(t, []string{"blue"}, r.Condition[0].Value.Strings())
+}
+
+func TestAwsParser_ParseVariables2008(t *testing.T) {
+	policyText := `{
+  "Version": "2008-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "s3:*",
+      "Resource": ["arn:aws:s3:::home/${aws:username}/*", "arn:aws:s3:::literal/${*}"]
+    }
+  ]
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+
+	assert.EqualValues(t, []string{"arn:aws:s3:::home/${aws:username}/<.*>", "arn:aws:s3:::literal/${<.*>}"}, policies[0].Resources)
+	assert.Nil(t, policies[0].Variables)
+	assert.True(t, policies[0].MatchesResource("arn:aws:s3:::home/${aws:username}/notes.txt"))
+	assert.False(t, policies[0].MatchesResource("arn:aws:s3:::home/alice/notes.txt"))
+	assert.True(t, policies[0].MatchesResource("arn:aws:s3:::literal/${anything}"))
+	assert.EqualValues(t, "arn:aws:s3:::home/${aws:username}/*", policies[0].Patterns.Resources[0].Glob)
+
+	// rendering writes the globs back as they were, however many times
+	rendered, err := Render(policies)
+	assert.Nil(t, err)
+	assert.Contains(t, string(rendered), `"Resource":["arn:aws:s3:::home/${aws:username}/*","arn:aws:s3:::literal/${*}"]`)
+
+	a, err = NewAwsPolicyParser(string(rendered), nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Nil(t, a.Parse())
+	reparsed, err := a.GetPolicy()
+	assert.Nil(t, err)
+	before, _ := json.Marshal(policies)
+	after, _ := json.Marshal(reparsed)
+	assert.JSONEq(t, string(before), string(after))
+
+	again, err := Render(reparsed)
+	assert.Nil(t, err)
+	assert.EqualValues(t, string(rendered), string(again))
+}
+
+func TestAwsParser_ParseConditionValues(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": "s3:ListBucket",
+      "Resource": "*",
+      "Condition": {
+        "NumericLessThan": {"s3:max-keys": 10.5},
+        "NumericGreaterThanEquals": {"s3:min-keys": ["10", -2]},
+        "DateGreaterThan": {"aws:CurrentTime": "2020-01-01T00:00:00Z", "aws:EpochTime": 1577836800},
+        "Null": {"aws:TokenIssueTime": null, "aws:MultiFactorAuthAge": "true"},
+        "Bool": {"aws:SecureTransport": "false"},
+        "StringEquals": {"aws:PrincipalTag/team": ["blue", "green"]}
+      }
+    }
+  ]
+}`
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+
+	conditions := policies[0].Condition
+	assert.Len(t, conditions, 8)
+	if len(conditions) != 8 {
+		t.FailNow()
+	}
+
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	expected := []policy.Condition{
+		{Operation: "NumericLessThan", Key: "s3:max-keys", Value: policy.Float64Values(10.5)},
+		{Operation: "NumericGreaterThanEquals", Key: "s3:min-keys", Value: policy.Int64Values(10, -2)},
+		{Operation: "DateGreaterThan", Key: "aws:CurrentTime", Value: policy.DateValues(date)},
+		{Operation: "DateGreaterThan", Key: "aws:EpochTime", Value: policy.DateValues(date)},
+		{Operation: "Null", Key: "aws:TokenIssueTime", Value: policy.NullValues(1)},
+		{Operation: "Null", Key: "aws:MultiFactorAuthAge", Value: policy.BoolValues(true)},
+		{Operation: "Bool", Key: "aws:SecureTransport", Value: policy.BoolValues(false)},
+		{Operation: "StringEquals", Key: "aws:PrincipalTag/team", Value: policy.StringValues("blue", "green")},
+	}
+	for index, c := range expected {
+		assert.EqualValues(t, c, conditions[index])
+	}
+}
+
+func TestAwsParser_ParseMixedConditionValues(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Deny",
+      "Action": "ec2:RunInstances",
+      "Resource": "*",
+      "Condition": {
+        "StringEquals": {"ec2:InstanceType": ["t3.micro", 5, true]},
+        "NumericLessThanEquals": {"ec2:CpuCount": ["4", 8.5]},
+        "NumericGreaterThan": {"ec2:VolumeSize": ["large", 10]}
+      }
+    }
+  ]
+}`
+
+	// lenient: coerce what can be coerced, keep the rest as written
+	a, err := NewAwsPolicyParser(policyText, nil, false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = a.Parse()
+	assert.Nil(t, err)
+
+	policies, err := a.GetPolicy()
+	assert.Nil(t, err)
+	assert.Len(t, policies, 1)
+	if len(policies) != 1 {
+		t.FailNow()
+	}
+
+	conditions := policies[0].Condition
+	assert.Len(t, conditions, 3)
+	if len(conditions) != 3 {
+		t.FailNow()
+	}
+	assert.EqualValues(t, []string{"t3.micro", "5", "true"}, conditions[0].Value.Strings())
+	assert.EqualValues(t, "string", conditions[0].Value.Type())
+	assert.EqualValues(t, []float64{4, 8.5}, conditions[1].Value.Float64s())
+	assert.EqualValues(t, "float64", conditions[1].Value.Type())
+	assert.EqualValues(t, []string{"large", "10"}, conditions[2].Value.Strings())
+	assert.EqualValues(t, "string", conditions[2].Value.Type())
+
+	warnings := a.Warnings()
+	assert.Len(t, warnings, 1)
+	if len(warnings) != 1 {
+		t.FailNow()
+	}
+	w := warnings[0].(*ValidationError)
+	assert.EqualValues(t, 0, w.Statement)
+	assert.EqualValues(t, 11, w.Pos.Line)
+	assert.Contains(t, w.Message, "NumericGreaterThan on ec2:VolumeSize")
+
+	// strict: fail and say why
+	a, err = NewAwsPolicyParser(policyText, nil, true)
+	assert.Nil(t, err)
+
+	err = a.Parse()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `"large" is not a number`)
+
+	policies, err = a.GetPolicy()
+	assert.NotNil(t, err)
+	assert.Nil(t, policies)
+}
+
+func TestAwsParser_Render(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	policyText := `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "Assume",
+      "Effect": "Allow",
+      "Principal": {
+        "AWS": ["123456789012", "arn:aws:iam::210987654321:role/deployer"],
+        "Service": "ec2.amazonaws.com",
+        "Federated": "arn:aws:iam::123456789012:saml-provider/okta"
+      },
+      "Action": "sts:AssumeRole",
+      "Condition": {
+        "StringEquals": {"sts:ExternalId": "a b c"},
+        "NumericLessThan": {"s3:max-keys": [10, 20]},
+        "Null": {"aws:TokenIssueTime": null}
+      }
+    },
+    {
+      "Effect": "Deny",
+      "NotAction": ["s3:Get*", "s3:List?ucket"],
+      "NotResource": "arn:aws:s3:::data/${aws:username}/*"
+    }
+  ]
+}`
+
+	parse := func(text string) []*policy.Policy {
+		p, err := NewAwsPolicyParser(text, nil, true)
+		assert.Nil(t, err)
+		if err != nil {
+			t.FailNow()
+		}
+		err = p.Parse()
+		assert.Nil(t, err)
+		if err != nil {
+			t.FailNow()
+		}
+		policies, err := p.GetPolicy()
+		assert.Nil(t, err)
+		return policies
+	}
+
+	policies := parse(policyText)
+	rendered, err := Render(policies)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	log.Debugf("%s", rendered)
+	assert.NotContains(t, string(rendered), "\n")
+
+	before, _ := json.Marshal(policies)
+	after, _ := json.Marshal(parse(string(rendered)))
+	assert.JSONEq(t, string(before), string(after))
+
+	assert.EqualValues(t, len(rendered), Size(rendered))
+	assert.EqualValues(t, Size(rendered), Size([]byte(strings.ReplaceAll(string(rendered), ",", ",\n  "))))
+	assert.EqualValues(t, 13, Size([]byte(`{ "a b" : [ 1, 2 ] }`)))
+}
+
+func TestAwsParser_ValidateSize(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	statements := []string{}
+	for i := 0; i < 80; i++ {
+		statements = append(statements, fmt.Sprintf(
+			`{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket-%03d/reports/*"}`, i))
+	}
+	large := fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    %s
+  ]
+}`, strings.Join(statements, ",\n    "))
+	assert.True(t, Size([]byte(large)) > 6144)
+	assert.True(t, Size([]byte(large)) < 10240)
+
+	validate := func(text string, configure func(p *AwsParser)) error {
+		p, err := NewAwsPolicyParser(text, nil, false)
+		assert.Nil(t, err)
+		if err != nil {
+			t.FailNow()
+		}
+		configure(p)
+		err = p.Parse()
+		assert.Nil(t, err)
+		if err != nil {
+			t.FailNow()
+		}
+		return p.Validate()
+	}
+
+	err := validate(large, func(p *AwsParser) {})
+	assert.NotNil(t, err)
+	if err == nil {
+		t.FailNow()
+	}
+	log.Debugf("%s", err.Error())
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 1)
+	assert.EqualValues(t, -1, errs[0].Statement)
+	assert.Contains(t, errs[0].Error(), "over the 6144 allowed for managed policies")
+
+	assert.Nil(t, validate(large, func(p *AwsParser) { assert.Nil(t, p.SetLimits(map[string]int{QuotaManaged: 10240})) }))
+	assert.Nil(t, validate(large, func(p *AwsParser) { assert.Nil(t, p.SetLimits(map[string]int{QuotaManaged: 0})) }))
+
+	// a misspelt quota is an error, and the others are left alone
+	err = validate(large, func(p *AwsParser) {
+		err := p.SetLimits(map[string]int{"managd": 10240, QuotaManaged: 0})
+		assert.NotNil(t, err)
+		if err != nil {
+			assert.Contains(t, err.Error(), "managd is not a known quota")
+		}
+	})
+	assert.NotNil(t, err)
+
+	err = validate(large, func(p *AwsParser) { assert.Nil(t, p.SetQuota(QuotaSCP)) })
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Contains(t, err.Error(), "over the 5120 allowed for scp policies")
+	}
+
+	// an unknown quota would have no limit, disabling the check
+	err = validate(large, func(p *AwsParser) { assert.NotNil(t, p.SetQuota("sccp")) })
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.Contains(t, err.Error(), "over the 6144 allowed for managed policies")
+	}
+
+	// the inline policies of an identity share their quota
+	inline := func(name string) string {
+		return fmt.Sprintf(`{
+                    "PolicyName": "%s",
+                    "PolicyDocument": {
+                        "Version": "2012-10-17",
+                        "Statement": [{"Effect": "Allow", "Action": "sqs:SendMessage", "Resource": "*"}]
+                    }
+                }`, name)
+	}
+	details := fmt.Sprintf(`{
+    "UserDetailList": [
+        {
+            "UserName": "alice",
+            "Arn": "arn:aws:iam::111122223333:user/alice",
+            "UserPolicyList": [%s, %s]
+        },
+        {
+            "UserName": "bob",
+            "Arn": "arn:aws:iam::111122223333:user/bob",
+            "UserPolicyList": [%s]
+        }
+    ]
+}`, inline("a1"), inline("a2"), inline("b1"))
+	one := Size([]byte(inline("")))
+	assert.Nil(t, validate(details, func(p *AwsParser) {}))
+
+	err = validate(details, func(p *AwsParser) { assert.Nil(t, p.SetLimits(map[string]int{QuotaInlineUser: one + 10})) })
+	assert.NotNil(t, err)
+	if err == nil {
+		t.FailNow()
+	}
+	errs = err.(ValidationErrors)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "inline policies of user alice")
+}
@@ -0,0 +1,249 @@
+package aws
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+const (
+	familyString  = "String"
+	familyNumeric = "Numeric"
+	familyDate    = "Date"
+	familyBool    = "Bool"
+	familyBinary  = "Binary"
+	familyIp      = "IpAddress"
+	familyArn     = "Arn"
+	familyNull    = "Null"
+)
+
+const (
+	typeString  = "string"
+	typeInt64   = "int64"
+	typeFloat64 = "float64"
+	typeBool    = "bool"
+	typeDate    = "date"
+	typeNull    = "null"
+)
+
+// rawValue is a condition value as written in the policy.
+type rawValue struct {
+	typ  string
+	text string
+}
+
+func newRawValue(v *Value) rawValue {
+	switch {
+	case v.OneString != nil:
+		return rawValue{typ: typeString, text: StringValue(v.OneString)}
+	case v.OneFloat != nil:
+		return rawValue{typ: typeFloat64, text: strconv.FormatFloat(*v.OneFloat, 'f', -1, 64)}
+	case v.OneNumber != nil:
+		return rawValue{typ: typeInt64, text: strconv.FormatInt(*v.OneNumber, 10)}
+	case v.BoolTrue != nil:
+		return rawValue{typ: typeBool, text: "true"}
+	case v.BoolFalse != nil:
+		return rawValue{typ: typeBool, text: "false"}
+	}
+	return rawValue{typ: typeNull}
+}
+
+// operatorFamily strips the set operator prefix and IfExists suffix from op
+// and returns the family it belongs to, e.g. Numeric for
+// ForAnyValue:NumericLessThanIfExists.
+func operatorFamily(op string) string {
+	if i := strings.Index(op, ":"); i >= 0 {
+		op = op[i+1:]
+	}
+	op = strings.TrimSuffix(op, "IfExists")
+	for _, family := range []string{
+		familyString,
+		familyNumeric,
+		familyDate,
+		familyBool,
+		familyBinary,
+		familyIp,
+		"NotIpAddress",
+		familyArn,
+		familyNull,
+	} {
+		if strings.HasPrefix(op, family) {
+			if family == "NotIpAddress" {
+				return familyIp
+			}
+			return family
+		}
+	}
+	return ""
+}
+
+// interpretValues converts raw to the type the operator family works on and
+// returns the typed slice together with its type name. Values of other JSON
+// types are coerced the way AWS compares them, e.g. numbers become strings
+// for String* operators; an error is returned when a value cannot be.
+func interpretValues(family string, raw []rawValue) (policy.Values, error) {
+	if allOfType(raw, typeNull) {
+		return policy.NullValues(len(raw)), nil
+	}
+
+	switch family {
+	case familyNumeric:
+		return interpretNumbers(raw)
+	case familyDate:
+		return interpretDates(raw)
+	case familyBool, familyNull:
+		return interpretBools(raw)
+	case familyString, familyArn, familyIp, familyBinary:
+		return coerceStrings(raw)
+	}
+
+	// unknown operators keep the JSON types, as long as they agree
+	if val, err := naturalValues(raw); err == nil {
+		return val, nil
+	}
+	return coerceStrings(raw)
+}
+
+// coerceStrings returns the text of every value. Nulls have no text and
+// cannot be coerced.
+func coerceStrings(raw []rawValue) (policy.Values, error) {
+	sl := []string{}
+	for _, r := range raw {
+		if r.typ == typeNull {
+			return policy.Values{}, fmt.Errorf("null cannot be compared as a string")
+		}
+		sl = append(sl, r.text)
+	}
+	return policy.StringValues(sl...), nil
+}
+
+// rawStrings returns the text of every value, as written in the policy.
+func rawStrings(raw []rawValue) []string {
+	sl := []string{}
+	for _, r := range raw {
+		sl = append(sl, r.text)
+	}
+	return sl
+}
+
+func interpretNumbers(raw []rawValue) (policy.Values, error) {
+	il := []int64{}
+	fl := []float64{}
+	integral := true
+	for _, r := range raw {
+		if r.typ != typeString && r.typ != typeInt64 && r.typ != typeFloat64 {
+			return policy.Values{}, fmt.Errorf("%s value %q is not a number", r.typ, r.text)
+		}
+		f, err := strconv.ParseFloat(r.text, 64)
+		if err != nil {
+			return policy.Values{}, fmt.Errorf("%q is not a number", r.text)
+		}
+		fl = append(fl, f)
+		if i, err := strconv.ParseInt(r.text, 10, 64); err == nil {
+			il = append(il, i)
+		} else {
+			integral = false
+		}
+	}
+	if integral {
+		return policy.Int64Values(il...), nil
+	}
+	return policy.Float64Values(fl...), nil
+}
+
+func interpretDates(raw []rawValue) (policy.Values, error) {
+	tl := []time.Time{}
+	for _, r := range raw {
+		t, err := parseDate(r)
+		if err != nil {
+			return policy.Values{}, err
+		}
+		tl = append(tl, t)
+	}
+	return policy.DateValues(tl...), nil
+}
+
+// parseDate reads epoch seconds written as a number or a string, and the
+// other forms of policy.ParseDate written as a string.
+func parseDate(r rawValue) (time.Time, error) {
+	if r.typ != typeInt64 && r.typ != typeString {
+		return time.Time{}, fmt.Errorf("%q is not a date", r.text)
+	}
+	return policy.ParseDate(r.text)
+}
+
+func interpretBools(raw []rawValue) (policy.Values, error) {
+	bl := []bool{}
+	for _, r := range raw {
+		if r.typ != typeString && r.typ != typeBool {
+			return policy.Values{}, fmt.Errorf("%s value %q is not a boolean", r.typ, r.text)
+		}
+		var b bool
+		switch strings.ToLower(r.text) {
+		case "true":
+			b = true
+		case "false":
+			b = false
+		default:
+			return policy.Values{}, fmt.Errorf("%q is not a boolean", r.text)
+		}
+		bl = append(bl, b)
+	}
+	return policy.BoolValues(bl...), nil
+}
+
+// naturalValues types raw by the JSON types the values were written as. It
+// fails if the values do not share a type.
+func naturalValues(raw []rawValue) (policy.Values, error) {
+	if len(raw) == 0 {
+		return policy.StringValues(), nil
+	}
+	typ := raw[0].typ
+	if allNumbers(raw) {
+		return interpretNumbers(raw)
+	}
+	if !allOfType(raw, typ) {
+		return policy.Values{}, fmt.Errorf("values mix %s and %s", typ, firstOtherType(raw, typ))
+	}
+	switch typ {
+	case typeString:
+		sl := []string{}
+		for _, r := range raw {
+			sl = append(sl, r.text)
+		}
+		return policy.StringValues(sl...), nil
+	case typeBool:
+		return interpretBools(raw)
+	}
+	return policy.NullValues(len(raw)), nil
+}
+
+func allOfType(raw []rawValue, typ string) bool {
+	for _, r := range raw {
+		if r.typ != typ {
+			return false
+		}
+	}
+	return len(raw) > 0
+}
+
+func allNumbers(raw []rawValue) bool {
+	for _, r := range raw {
+		if r.typ != typeInt64 && r.typ != typeFloat64 {
+			return false
+		}
+	}
+	return len(raw) > 0
+}
+
+func firstOtherType(raw []rawValue, typ string) string {
+	for _, r := range raw {
+		if r.typ != typ {
+			return r.typ
+		}
+	}
+	return typ
+}
@@ -0,0 +1,289 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/decoder"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+/*
+	Envelopes returned by the AWS CLI / API that wrap policy documents:
+
+	get-policy-version:      {"PolicyVersion": {"Document": ..., "VersionId": ...}}
+	get-role-policy:         {"RoleName": ..., "PolicyName": ..., "PolicyDocument": ...}
+	get-user-policy:         {"UserName": ..., "PolicyName": ..., "PolicyDocument": ...}
+	get-group-policy:        {"GroupName": ..., "PolicyName": ..., "PolicyDocument": ...}
+	get-role:                {"Role": {"RoleName": ..., "Arn": ..., "AssumeRolePolicyDocument": ...}}
+	get-bucket-policy:       {"Policy": "<json string>"}
+	get-policy:              {"Policy": {"PolicyName": ..., "Arn": ...}}, rejected:
+	                         it holds no document
+	get-account-authorization-details:
+	                         {"UserDetailList": [...], "GroupDetailList": [...],
+	                          "RoleDetailList": [...], "Policies": [...]}
+
+	Embedded documents are either JSON objects (the CLI decodes them) or
+	strings (url-encoded by the IAM API, or JSON strings for bucket policies).
+*/
+
+const (
+	attachmentRole  = "role"
+	attachmentUser  = "user"
+	attachmentGroup = "group"
+)
+
+// document is a single policy document together with where it was found.
+type document struct {
+	text      string
+	kind      string
+	origin    *policy.Origin
+	awsPolicy *AwsPolicy
+}
+
+type envelope struct {
+	Statement json.RawMessage `json:"Statement"`
+
+	PolicyVersion  *policyVersion  `json:"PolicyVersion"`
+	Role           *roleDetail     `json:"Role"`
+	Policy         json.RawMessage `json:"Policy"`
+	PolicyName     string          `json:"PolicyName"`
+	PolicyDocument json.RawMessage `json:"PolicyDocument"`
+	RoleName       string          `json:"RoleName"`
+	UserName       string          `json:"UserName"`
+	GroupName      string          `json:"GroupName"`
+
+	UserDetailList  []*userDetail    `json:"UserDetailList"`
+	GroupDetailList []*groupDetail   `json:"GroupDetailList"`
+	RoleDetailList  []*roleDetail    `json:"RoleDetailList"`
+	Policies        []*managedPolicy `json:"Policies"`
+}
+
+type policyVersion struct {
+	Document         json.RawMessage `json:"Document"`
+	VersionId        string          `json:"VersionId"`
+	IsDefaultVersion bool            `json:"IsDefaultVersion"`
+}
+
+type inlinePolicy struct {
+	PolicyName     string          `json:"PolicyName"`
+	PolicyDocument json.RawMessage `json:"PolicyDocument"`
+}
+
+type attachedPolicy struct {
+	PolicyName string `json:"PolicyName"`
+	PolicyArn  string `json:"PolicyArn"`
+}
+
+type userDetail struct {
+	UserName                string            `json:"UserName"`
+	Arn                     string            `json:"Arn"`
+	UserPolicyList          []*inlinePolicy   `json:"UserPolicyList"`
+	AttachedManagedPolicies []*attachedPolicy `json:"AttachedManagedPolicies"`
+}
+
+type groupDetail struct {
+	GroupName               string            `json:"GroupName"`
+	Arn                     string            `json:"Arn"`
+	GroupPolicyList         []*inlinePolicy   `json:"GroupPolicyList"`
+	AttachedManagedPolicies []*attachedPolicy `json:"AttachedManagedPolicies"`
+}
+
+type roleDetail struct {
+	RoleName                 string            `json:"RoleName"`
+	Arn                      string            `json:"Arn"`
+	AssumeRolePolicyDocument json.RawMessage   `json:"AssumeRolePolicyDocument"`
+	RolePolicyList           []*inlinePolicy   `json:"RolePolicyList"`
+	AttachedManagedPolicies  []*attachedPolicy `json:"AttachedManagedPolicies"`
+}
+
+type managedPolicy struct {
+	PolicyName        string           `json:"PolicyName"`
+	Arn               string           `json:"Arn"`
+	DefaultVersionId  string           `json:"DefaultVersionId"`
+	PolicyVersionList []*policyVersion `json:"PolicyVersionList"`
+}
+
+// extractDocuments returns the policy documents contained in text. Text that
+// is not a recognized envelope is returned as a single document. Documents
+// embedded as encoded strings are decoded to at most max bytes, if set.
+func extractDocuments(text string, max int64) ([]*document, error) {
+	e := &envelope{}
+	if err := json.Unmarshal([]byte(text), e); err != nil || e.Statement != nil {
+		return []*document{{text: text}}, nil
+	}
+
+	docs := []*document{}
+	var err error
+
+	switch {
+	case e.PolicyVersion != nil:
+		docs, err = appendDocument(docs, e.PolicyVersion.Document, &policy.Origin{
+			Type:      policy.OriginManaged,
+			VersionId: e.PolicyVersion.VersionId,
+		}, max)
+	case e.Role != nil:
+		docs, err = appendDocument(docs, e.Role.AssumeRolePolicyDocument, trustOrigin(e.Role), max)
+	case e.PolicyDocument != nil:
+		origin := &policy.Origin{
+			Type: policy.OriginInline,
+			Name: e.PolicyName,
+		}
+		switch {
+		case e.RoleName != "":
+			origin.AttachedTo = []policy.Attachment{{Type: attachmentRole, Name: e.RoleName}}
+		case e.UserName != "":
+			origin.AttachedTo = []policy.Attachment{{Type: attachmentUser, Name: e.UserName}}
+		case e.GroupName != "":
+			origin.AttachedTo = []policy.Attachment{{Type: attachmentGroup, Name: e.GroupName}}
+		}
+		docs, err = appendDocument(docs, e.PolicyDocument, origin, max)
+	case e.Policy != nil:
+		if !isDocument(e.Policy) {
+			// e.g. get-policy, whose Policy is the metadata of a managed
+			// policy, not its document
+			return nil, fmt.Errorf("the Policy field holds no policy document; fetch managed policies with get-policy-version")
+		}
+		docs, err = appendDocument(docs, e.Policy, &policy.Origin{Type: policy.OriginBucket}, max)
+	case e.UserDetailList != nil || e.GroupDetailList != nil || e.RoleDetailList != nil || e.Policies != nil:
+		docs, err = authorizationDetails(e, max)
+	default:
+		return []*document{{text: text}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func authorizationDetails(e *envelope, max int64) ([]*document, error) {
+	var err error
+	docs := []*document{}
+
+	attachments := map[string][]policy.Attachment{}
+	attach := func(policies []*attachedPolicy, a policy.Attachment) {
+		for _, p := range policies {
+			attachments[p.PolicyArn] = append(attachments[p.PolicyArn], a)
+		}
+	}
+	for _, u := range e.UserDetailList {
+		attach(u.AttachedManagedPolicies, policy.Attachment{Type: attachmentUser, Name: u.UserName, Arn: u.Arn})
+	}
+	for _, g := range e.GroupDetailList {
+		attach(g.AttachedManagedPolicies, policy.Attachment{Type: attachmentGroup, Name: g.GroupName, Arn: g.Arn})
+	}
+	for _, r := range e.RoleDetailList {
+		attach(r.AttachedManagedPolicies, policy.Attachment{Type: attachmentRole, Name: r.RoleName, Arn: r.Arn})
+	}
+
+	for _, p := range e.Policies {
+		for _, v := range p.PolicyVersionList {
+			if !v.IsDefaultVersion && v.VersionId != p.DefaultVersionId {
+				continue
+			}
+			docs, err = appendDocument(docs, v.Document, &policy.Origin{
+				Type:       policy.OriginManaged,
+				Name:       p.PolicyName,
+				Arn:        p.Arn,
+				Account:    accountFromArn(p.Arn),
+				VersionId:  v.VersionId,
+				AttachedTo: attachments[p.Arn],
+			}, max)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, u := range e.UserDetailList {
+		a := policy.Attachment{Type: attachmentUser, Name: u.UserName, Arn: u.Arn}
+		if docs, err = appendInline(docs, u.UserPolicyList, a, max); err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range e.GroupDetailList {
+		a := policy.Attachment{Type: attachmentGroup, Name: g.GroupName, Arn: g.Arn}
+		if docs, err = appendInline(docs, g.GroupPolicyList, a, max); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range e.RoleDetailList {
+		if docs, err = appendDocument(docs, r.AssumeRolePolicyDocument, trustOrigin(r), max); err != nil {
+			return nil, err
+		}
+		a := policy.Attachment{Type: attachmentRole, Name: r.RoleName, Arn: r.Arn}
+		if docs, err = appendInline(docs, r.RolePolicyList, a, max); err != nil {
+			return nil, err
+		}
+	}
+
+	return docs, nil
+}
+
+func appendInline(docs []*document, policies []*inlinePolicy, a policy.Attachment, max int64) ([]*document, error) {
+	var err error
+	for _, p := range policies {
+		docs, err = appendDocument(docs, p.PolicyDocument, &policy.Origin{
+			Type:       policy.OriginInline,
+			Name:       p.PolicyName,
+			Account:    accountFromArn(a.Arn),
+			AttachedTo: []policy.Attachment{a},
+		}, max)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+func trustOrigin(r *roleDetail) *policy.Origin {
+	return &policy.Origin{
+		Type:       policy.OriginTrust,
+		Name:       r.RoleName,
+		Account:    accountFromArn(r.Arn),
+		AttachedTo: []policy.Attachment{{Type: attachmentRole, Name: r.RoleName, Arn: r.Arn}},
+	}
+}
+
+// appendDocument adds raw to docs. Raw is either the document itself or a
+// string holding an encoded document.
+func appendDocument(docs []*document, raw json.RawMessage, origin *policy.Origin, max int64) ([]*document, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return docs, nil
+	}
+	text := string(raw)
+	if strings.HasPrefix(strings.TrimSpace(text), "\"") {
+		d, err := decoder.NewPipeline(decoder.Auto)
+		if err != nil {
+			return nil, err
+		}
+		text, err = d.Limit(max).Decode(text)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s policy %s: %w", origin.Type, origin.Name, err)
+		}
+	}
+	return append(docs, &document{text: text, origin: origin}), nil
+}
+
+// isDocument reports whether raw is a policy document: a string holding an
+// encoded one, or an object with a Statement.
+func isDocument(raw json.RawMessage) bool {
+	if strings.HasPrefix(strings.TrimSpace(string(raw)), "\"") {
+		return true
+	}
+	d := struct {
+		Statement json.RawMessage `json:"Statement"`
+	}{}
+	return json.Unmarshal(raw, &d) == nil && d.Statement != nil
+}
+
+// accountFromArn returns the account field of arn, e.g. 123456789012 for
+// arn:aws:iam::123456789012:role/admin.
+func accountFromArn(arn string) string {
+	fields := strings.SplitN(arn, ":", 6)
+	if len(fields) < 6 {
+		return ""
+	}
+	return fields[4]
+}
@@ -0,0 +1,136 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// trustActions are the actions allowed in a role trust policy.
+var trustActions = []string{
+	"sts:assumerole",
+	"sts:assumerolewithsaml",
+	"sts:assumerolewithwebidentity",
+	"sts:tagsession",
+	"sts:setsourceidentity",
+	"sts:setcontext",
+}
+
+// detectKind returns the kind of doc. The envelope a document came from is
+// authoritative; otherwise a document where every statement names a
+// principal, has no resource and only grants sts:AssumeRole* actions is a
+// trust policy, one that names principals is a resource policy and anything
+// else is an identity policy.
+func detectKind(doc *document) string {
+	if doc.origin != nil {
+		switch doc.origin.Type {
+		case policy.OriginTrust:
+			return policy.KindTrust
+		case policy.OriginBucket:
+			return policy.KindResource
+		case policy.OriginManaged, policy.OriginInline:
+			return policy.KindIdentity
+		}
+	}
+	if doc.awsPolicy == nil || doc.awsPolicy.Block == nil || len(doc.awsPolicy.Block.Statement) == 0 {
+		return policy.KindIdentity
+	}
+
+	trust := true
+	principals := true
+	for _, statement := range doc.awsPolicy.Block.Statement {
+		e := collectElements(statement)
+		if e.Principal == nil && e.NotPrincipal == nil {
+			principals = false
+		}
+		if e.Resource != nil || e.NotResource != nil || e.Action == nil {
+			trust = false
+			continue
+		}
+		for _, action := range anyOrListValues(e.Action) {
+			if !isTrustAction(action) {
+				trust = false
+			}
+		}
+	}
+
+	switch {
+	case principals && trust:
+		return policy.KindTrust
+	case principals:
+		return policy.KindResource
+	}
+	return policy.KindIdentity
+}
+
+func isTrustAction(action string) bool {
+	action = strings.ToLower(action)
+	if !strings.HasPrefix(action, "sts:") {
+		return false
+	}
+	for _, t := range trustActions {
+		if action == t || (strings.HasSuffix(action, "*") && strings.HasPrefix(t, strings.TrimSuffix(action, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectElements folds the elements of a statement into a single Elements.
+func collectElements(s *Statement) *Elements {
+	e := &Elements{}
+	for _, element := range s.Elements {
+		if element.Sid != nil {
+			e.Sid = element.Sid
+		}
+		if element.Effect != nil {
+			e.Effect = element.Effect
+		}
+		if element.Principal != nil {
+			e.Principal = element.Principal
+		}
+		if element.NotPrincipal != nil {
+			e.NotPrincipal = element.NotPrincipal
+		}
+		if element.Action != nil {
+			e.Action = element.Action
+		}
+		if element.NotAction != nil {
+			e.NotAction = element.NotAction
+		}
+		if element.Resource != nil {
+			e.Resource = element.Resource
+		}
+		if element.NotResource != nil {
+			e.NotResource = element.NotResource
+		}
+		if element.Condition != nil {
+			e.Condition = element.Condition
+		}
+	}
+	return e
+}
+
+// anyOrListValues returns the raw values of l, with "*" for the wildcard.
+func anyOrListValues(l *AnyOrList) []string {
+	if l == nil {
+		return nil
+	}
+	items := l.List
+	if l.Item != nil {
+		items = []*Item{l.Item}
+	}
+	x := []string{}
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		if item.Any {
+			x = append(x, "*")
+		}
+		if item.One != nil {
+			x = append(x, StringValue(item.One))
+		}
+	}
+	return x
+}
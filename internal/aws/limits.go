@@ -0,0 +1,185 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Quotas AWS sets on the size of policy documents, named after where the
+// document is used.
+const (
+	QuotaManaged     = "managed"
+	QuotaInlineRole  = "inline-role"
+	QuotaInlineUser  = "inline-user"
+	QuotaInlineGroup = "inline-group"
+	QuotaTrust       = "trust"
+	QuotaSCP         = "scp"
+	QuotaBucket      = "bucket"
+)
+
+// aggregateQuotas are shared by all the inline policies of an identity
+// instead of applying to each document.
+var aggregateQuotas = map[string]bool{
+	QuotaInlineRole:  true,
+	QuotaInlineUser:  true,
+	QuotaInlineGroup: true,
+}
+
+// DefaultLimits returns the default size of each quota, in characters not
+// counting whitespace.
+func DefaultLimits() map[string]int {
+	return map[string]int{
+		QuotaManaged:     6144,
+		QuotaInlineRole:  10240,
+		QuotaInlineUser:  2048,
+		QuotaInlineGroup: 5120,
+		QuotaTrust:       2048,
+		QuotaSCP:         5120,
+		QuotaBucket:      20480,
+	}
+}
+
+// SetLimits overrides the size of the given quotas, e.g. after AWS raised
+// the trust policy quota of an account. A size of 0 disables the check. Like
+// SetQuota it fails, changing nothing, for a name that is not one of the
+// quotas of DefaultLimits.
+func (a *AwsParser) SetLimits(limits map[string]int) error {
+	if err := CheckLimits(limits); err != nil {
+		return err
+	}
+	for quota, size := range limits {
+		a.limits[quota] = size
+	}
+	return nil
+}
+
+// CheckLimits fails for the names of limits that are not quotas of
+// DefaultLimits.
+func CheckLimits(limits map[string]int) error {
+	unknown := []string{}
+	for quota := range limits {
+		if _, ok := DefaultLimits()[quota]; !ok {
+			unknown = append(unknown, quota)
+		}
+	}
+	switch len(unknown) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s is not a known quota", unknown[0])
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("%s are not known quotas", strings.Join(unknown, ", "))
+}
+
+// SetQuota sets the quota of documents that do not come from an envelope
+// telling where they are used, e.g. QuotaSCP for a service control policy.
+// It fails for a name that is not one of the quotas of DefaultLimits.
+func (a *AwsParser) SetQuota(quota string) error {
+	if _, ok := DefaultLimits()[quota]; !ok {
+		return fmt.Errorf("%s is not a known quota", quota)
+	}
+	a.quota = quota
+	return nil
+}
+
+// quotaOf returns the quota doc counts against.
+func (a *AwsParser) quotaOf(doc *document) string {
+	return QuotaOf(doc.origin, doc.kind, a.quota)
+}
+
+// QuotaOf returns the quota a document counts against, told by its origin
+// or else quota, if set, or its kind.
+func QuotaOf(origin *policy.Origin, kind, quota string) string {
+	if origin != nil {
+		switch origin.Type {
+		case policy.OriginManaged:
+			return QuotaManaged
+		case policy.OriginTrust:
+			return QuotaTrust
+		case policy.OriginBucket:
+			return QuotaBucket
+		case policy.OriginInline:
+			if len(origin.AttachedTo) > 0 {
+				switch origin.AttachedTo[0].Type {
+				case attachmentUser:
+					return QuotaInlineUser
+				case attachmentGroup:
+					return QuotaInlineGroup
+				}
+			}
+			return QuotaInlineRole
+		}
+	}
+	if quota != "" {
+		return quota
+	}
+	switch kind {
+	case policy.KindTrust:
+		return QuotaTrust
+	case policy.KindResource:
+		return QuotaBucket
+	case policy.KindSCP, policy.KindRCP:
+		return QuotaSCP
+	}
+	return QuotaManaged
+}
+
+// checkQuotas reports the documents, and the inline policies of an
+// identity taken together, larger than their quota allows.
+func (a *AwsParser) checkQuotas() ValidationErrors {
+	errs := ValidationErrors{}
+
+	type identity struct{ quota, name string }
+	totals := map[identity]int{}
+	positions := map[identity]*document{}
+
+	for _, doc := range a.documents {
+		quota := a.quotaOf(doc)
+		size := Size([]byte(doc.text))
+		if aggregateQuotas[quota] {
+			id := identity{quota, ""}
+			if doc.origin != nil && len(doc.origin.AttachedTo) > 0 {
+				id.name = doc.origin.AttachedTo[0].Type + " " + doc.origin.AttachedTo[0].Name
+			}
+			totals[id] += size
+			if _, ok := positions[id]; !ok {
+				positions[id] = doc
+			}
+			continue
+		}
+		if limit := a.limits[quota]; limit > 0 && size > limit {
+			errs = append(errs, &ValidationError{
+				Pos:       doc.awsPolicy.Pos,
+				Policy:    documentName(doc),
+				Statement: -1,
+				Message:   fmt.Sprintf("document is %d characters, over the %d allowed for %s policies", size, limit, quota),
+			})
+		}
+	}
+
+	ids := []identity{}
+	for id := range totals {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].quota != ids[j].quota {
+			return ids[i].quota < ids[j].quota
+		}
+		return ids[i].name < ids[j].name
+	})
+	for _, id := range ids {
+		if limit := a.limits[id.quota]; limit > 0 && totals[id] > limit {
+			errs = append(errs, &ValidationError{
+				Pos:       positions[id].awsPolicy.Pos,
+				Policy:    fmt.Sprintf("inline policies of %s", id.name),
+				Statement: -1,
+				Message:   fmt.Sprintf("documents total %d characters, over the %d allowed for %s policies", totals[id], limit, id.quota),
+			})
+		}
+	}
+	return errs
+}
@@ -37,7 +37,12 @@ awsPolicy  = {
 <principal_map> = { <principal_map_entry>, <principal_map_entry>, ... }
 
 <principal_map_entry> = ("AWS" | "Federated" | "Service" | "CanonicalUser") :
-    [<principal_id_string>, <principal_id_string>, ...]
+    (<principal_id_string> | [<principal_id_string>, <principal_id_string>, ...])
+
+<principal_id_string> = "*" | <account_id> | <arn> | <service> | <canonical_user_id>
+
+    An "AWS" entry of "*" is the same as a Principal of "*", and a bare
+    12-digit account id is rewritten to arn:aws:iam::<account_id>:root.
 
 <action_block> = ("Action" | "NotAction") :
     ("*" | [<action_string>, <action_string>, ...])
@@ -47,11 +52,15 @@ awsPolicy  = {
 
 <condition_block> = "Condition" : { <condition_map> }
 <condition_map> = {
-  <condition_type_string> : { <condition_key_string> : <condition_value_list> },
-  <condition_type_string> : { <condition_key_string> : <condition_value_list> }, ...
+  <condition_type_string> : { <condition_key_string> : <condition_value_list>, ... },
+  <condition_type_string> : { <condition_key_string> : <condition_value_list>, ... }, ...
 }
-<condition_value_list> = [<condition_value>, <condition_value>, ...]
-<condition_value> = ("string" | "number" | "Boolean")
+<condition_value_list> = (<condition_value> | [<condition_value>, <condition_value>, ...])
+<condition_value> = ("string" | "number" | "Boolean" | null)
+
+    String values are interpreted according to the operator family, e.g.
+    "10" is a number for Numeric* operators and "2020-01-01T00:00:00Z" a
+    date for Date* operators.
 
 */
 
@@ -93,7 +102,7 @@ type AnyOrList struct {
 	Pos lexer.Position
 
 	Item *Item   `@@`
-	List []*Item `| "[" @@ ( ( "," @@ )* )? "]"`
+	List []*Item `| "[" ( @@ ( "," @@ )* )? "]"`
 }
 
 type Item struct {
@@ -128,8 +137,8 @@ type Condition struct {
 type ConditionList struct {
 	Pos lexer.Position
 
-	Operation    *string       `@String ":"`
-	KeyValueList *KeyValueList `"{" @@ "}"`
+	Operation    *string         `@String ":"`
+	KeyValueList []*KeyValueList `"{" @@ ( "," @@ )* "}"`
 }
 
 type KeyValueList struct {
@@ -143,14 +152,16 @@ type ValueList struct {
 	Pos lexer.Position
 
 	One  *Value   `@@`
-	List []*Value `| "[" @@ ( ("," @@ )* )? "]"`
+	List []*Value `| "[" ( @@ ( "," @@ )* )? "]"`
 }
 
 type Value struct {
 	Pos lexer.Position
 
-	OneString *string `@String`
-	OneNumber *int64  `| @Int`
-	BoolTrue  *bool   `| @"true"`
-	BoolFalse *bool   `| @"false"`
+	OneString *string  `@String`
+	OneFloat  *float64 `| @("-"? Float)`
+	OneNumber *int64   `| @("-"? Int)`
+	BoolTrue  *bool    `| @"true"`
+	BoolFalse *bool    `| @"false"`
+	Null      bool     `| @"null"`
 }
@@ -0,0 +1,255 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// DefaultVersion is the policy language version of rendered documents
+// whose statements do not carry one.
+const DefaultVersion = "2012-10-17"
+
+var canonicalUserPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// federatedProviders are the identity providers allowed in a Federated
+// principal besides the SAML and OIDC providers of an account.
+var federatedProviders = []string{
+	"cognito-identity.amazonaws.com",
+	"www.amazon.com",
+	"graph.facebook.com",
+	"accounts.google.com",
+}
+
+type renderedPolicy struct {
+	Version   string              `json:"Version"`
+	Statement []renderedStatement `json:"Statement"`
+}
+
+type renderedStatement struct {
+	Sid          string      `json:"Sid,omitempty"`
+	Effect       string      `json:"Effect"`
+	Principal    interface{} `json:"Principal,omitempty"`
+	NotPrincipal interface{} `json:"NotPrincipal,omitempty"`
+	Action       interface{} `json:"Action,omitempty"`
+	NotAction    interface{} `json:"NotAction,omitempty"`
+	Resource     interface{} `json:"Resource,omitempty"`
+	NotResource  interface{} `json:"NotResource,omitempty"`
+	Condition    orderedMap  `json:"Condition,omitempty"`
+}
+
+// Render writes policies back as a single AWS policy document, one
+// statement per policy, in the compact form AWS stores.
+func Render(policies []*policy.Policy) ([]byte, error) {
+	doc := renderedPolicy{Version: DefaultVersion, Statement: []renderedStatement{}}
+	for i, p := range policies {
+		if i == 0 && p.Version != "" {
+			doc.Version = p.Version
+		}
+		doc.Statement = append(doc.Statement, renderStatement(p))
+	}
+
+	return marshal(doc)
+}
+
+func renderStatement(p *policy.Policy) renderedStatement {
+	s := renderedStatement{
+		Sid:          p.Sid,
+		Effect:       "Deny",
+		Principal:    renderPrincipal(p.WrittenGlobs(policy.FieldSubjects)),
+		NotPrincipal: renderPrincipal(p.WrittenGlobs(policy.FieldNotSubjects)),
+		Action:       oneOrList(p.WrittenGlobs(policy.FieldActions)),
+		NotAction:    oneOrList(p.WrittenGlobs(policy.FieldNotActions)),
+		Resource:     oneOrList(p.WrittenGlobs(policy.FieldResources)),
+		NotResource:  oneOrList(p.WrittenGlobs(policy.FieldNotResources)),
+	}
+	if p.Allowed {
+		s.Effect = "Allow"
+	}
+	for _, c := range p.Condition {
+		keys, _ := s.Condition.get(c.Operation).(orderedMap)
+		s.Condition = s.Condition.set(c.Operation, keys.set(c.Key, renderValues(c.Value)))
+	}
+	return s
+}
+
+// renderPrincipal sorts principals back into the AWS, Service, Federated
+// and CanonicalUser keys they were parsed from.
+func renderPrincipal(subjects []string) interface{} {
+	if len(subjects) == 0 {
+		return nil
+	}
+	if len(subjects) == 1 && subjects[0] == "*" {
+		return "*"
+	}
+	keys := []string{}
+	values := map[string][]string{}
+	for _, s := range subjects {
+		key := principalKey(s)
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], s)
+	}
+	x := orderedMap{}
+	for _, key := range keys {
+		x = x.set(key, oneOrList(values[key]))
+	}
+	return x
+}
+
+// orderedMap is a JSON object keeping its keys in insertion order, so that
+// a rendered document parses back to the same policies.
+type orderedMap []orderedEntry
+
+type orderedEntry struct {
+	key   string
+	value interface{}
+}
+
+func (m orderedMap) get(key string) interface{} {
+	for _, e := range m {
+		if e.key == key {
+			return e.value
+		}
+	}
+	return nil
+}
+
+func (m orderedMap) set(key string, value interface{}) orderedMap {
+	for i := range m {
+		if m[i].key == key {
+			m[i].value = value
+			return m
+		}
+	}
+	return append(m, orderedEntry{key, value})
+}
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	for i, e := range m {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, err := marshal(e.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshal(e.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// marshal encodes v without escaping HTML characters, which are common in
+// condition values.
+func marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func principalKey(s string) string {
+	if strings.HasPrefix(s, "arn:") {
+		if strings.Contains(s, ":saml-provider/") || strings.Contains(s, ":oidc-provider/") {
+			return "Federated"
+		}
+		return "AWS"
+	}
+	for _, p := range federatedProviders {
+		if s == p {
+			return "Federated"
+		}
+	}
+	if strings.HasSuffix(s, ".amazonaws.com") || strings.HasSuffix(s, ".amazonaws.com.cn") {
+		return "Service"
+	}
+	if canonicalUserPattern.MatchString(s) {
+		return "CanonicalUser"
+	}
+	return "AWS"
+}
+
+func renderValues(v policy.Values) interface{} {
+	var x []interface{}
+	switch v.Type() {
+	case policy.TypeInt64:
+		for _, i := range v.Int64s() {
+			x = append(x, i)
+		}
+	case policy.TypeFloat64:
+		for _, f := range v.Float64s() {
+			x = append(x, f)
+		}
+	case policy.TypeBool:
+		for _, b := range v.Bools() {
+			x = append(x, b)
+		}
+	case policy.TypeNull:
+		x = make([]interface{}, v.Len())
+	default:
+		for _, s := range v.Text() {
+			x = append(x, s)
+		}
+	}
+	if len(x) == 1 {
+		return x[0]
+	}
+	if x == nil {
+		return []interface{}{}
+	}
+	return x
+}
+
+func oneOrList(x []string) interface{} {
+	switch len(x) {
+	case 0:
+		return nil
+	case 1:
+		return x[0]
+	}
+	return x
+}
+
+// Size returns the size of a policy document the way AWS counts it
+// against its quotas: every character except the whitespace between
+// tokens. Whitespace inside strings is part of the value and counts.
+func Size(text []byte) int {
+	n := 0
+	inString := false
+	escaped := false
+	for _, r := range string(text) {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			inString = true
+		case unicode.IsSpace(r):
+			continue
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,145 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// ValidationError describes a statement that is not valid for the kind of
+// policy it belongs to. Problems with a whole document, e.g. its size, have
+// a negative Statement.
+type ValidationError struct {
+	Pos       lexer.Position
+	Policy    string
+	Statement int
+	Message   string
+}
+
+func (v *ValidationError) Error() string {
+	if v.Statement < 0 {
+		return fmt.Sprintf("%s (%s): %s", v.Policy, v.Pos.String(), v.Message)
+	}
+	return fmt.Sprintf("%s statement #%d (%s): %s", v.Policy, v.Statement, v.Pos.String(), v.Message)
+}
+
+type ValidationErrors []*ValidationError
+
+func (v ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, e := range v {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (a *AwsParser) Validate() error {
+	if !a.parsed {
+		if a.error != nil {
+			return a.error
+		}
+		return fmt.Errorf("did not parse")
+	}
+
+	errs := ValidationErrors{}
+	for _, doc := range a.documents {
+		errs = append(errs, validateDocument(doc)...)
+	}
+	errs = append(errs, a.checkQuotas()...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// documentName names doc in error messages.
+func documentName(doc *document) string {
+	name := StringValue(doc.awsPolicy.Block.Id)
+	if name == "" && doc.origin != nil {
+		name = doc.origin.Name
+	}
+	if name == "" {
+		name = doc.kind + " policy"
+	}
+	return name
+}
+
+func validateDocument(doc *document) ValidationErrors {
+	errs := ValidationErrors{}
+
+	name := documentName(doc)
+
+	for index, statement := range doc.awsPolicy.Block.Statement {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, &ValidationError{
+				Pos:       statement.Pos,
+				Policy:    name,
+				Statement: index,
+				Message:   fmt.Sprintf(format, args...),
+			})
+		}
+
+		e := collectElements(statement)
+
+		switch strings.ToLower(StringValue(e.Effect)) {
+		case "allow", "deny":
+		case "":
+			fail("Effect is required")
+		default:
+			fail("Effect must be Allow or Deny, not %s", StringValue(e.Effect))
+		}
+
+		if e.Action == nil && e.NotAction == nil {
+			fail("one of Action or NotAction is required")
+		}
+		if e.Action != nil && e.NotAction != nil {
+			fail("Action and NotAction are mutually exclusive")
+		}
+		if e.Resource != nil && e.NotResource != nil {
+			fail("Resource and NotResource are mutually exclusive")
+		}
+		if e.Principal != nil && e.NotPrincipal != nil {
+			fail("Principal and NotPrincipal are mutually exclusive")
+		}
+
+		switch doc.kind {
+		case policy.KindIdentity:
+			if e.Principal != nil || e.NotPrincipal != nil {
+				fail("Principal is not allowed in an identity policy")
+			}
+			if e.Resource == nil && e.NotResource == nil {
+				fail("one of Resource or NotResource is required in an identity policy")
+			}
+		case policy.KindResource:
+			if e.Principal == nil && e.NotPrincipal == nil {
+				fail("one of Principal or NotPrincipal is required in a resource policy")
+			}
+			if e.Resource == nil && e.NotResource == nil {
+				fail("one of Resource or NotResource is required in a resource policy")
+			}
+		case policy.KindTrust:
+			if e.Principal == nil {
+				fail("Principal is required in a trust policy")
+			}
+			if e.NotPrincipal != nil {
+				fail("NotPrincipal is not allowed in a trust policy")
+			}
+			if e.Resource != nil || e.NotResource != nil {
+				fail("Resource is not allowed in a trust policy")
+			}
+			if e.NotAction != nil {
+				fail("NotAction is not allowed in a trust policy")
+			}
+			for _, action := range anyOrListValues(e.Action) {
+				if !isTrustAction(action) {
+					fail("%s is not a role assumption action", action)
+				}
+			}
+		}
+	}
+
+	return errs
+}
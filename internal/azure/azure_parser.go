@@ -1,18 +1,21 @@
 package azure
 
 import (
+	"github.com/aumahesh/policyparser/pkg/decoder"
 	"github.com/aumahesh/policyparser/pkg/policy"
 )
 
 type AzureParser struct {
 	policyText string
-	urlEscaped bool
+	decoder    decoder.Decoder
+	strict     bool
 }
 
-func NewAzurePolicyParser(policyText string, escaped bool) (*AzureParser, error) {
+func NewAzurePolicyParser(policyText string, dec decoder.Decoder, strict bool) (*AzureParser, error) {
 	return &AzureParser{
 		policyText: policyText,
-		urlEscaped: escaped,
+		decoder:    dec,
+		strict:     strict,
 	}, nil
 }
 
@@ -20,6 +23,14 @@ func (a *AzureParser) Parse() error {
 	return nil
 }
 
+func (a *AzureParser) Validate() error {
+	return nil
+}
+
+func (a *AzureParser) Warnings() []error {
+	return nil
+}
+
 func (a *AzureParser) GetPolicy() ([]*policy.Policy, error) {
 	return nil, nil
 }
@@ -27,7 +38,3 @@ func (a *AzureParser) GetPolicy() ([]*policy.Policy, error) {
 func (a *AzureParser) Json() ([]byte, error) {
 	return nil, nil
 }
-
-func (a *AzureParser) WriteJson(filename string) error {
-	return nil
-}
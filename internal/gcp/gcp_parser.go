@@ -1,18 +1,21 @@
 package gcp
 
 import (
+	"github.com/aumahesh/policyparser/pkg/decoder"
 	"github.com/aumahesh/policyparser/pkg/policy"
 )
 
 type GcpParser struct {
 	policyText string
-	urlEscaped bool
+	decoder    decoder.Decoder
+	strict     bool
 }
 
-func NewGcpPolicyParser(policyText string, escaped bool) (*GcpParser, error) {
+func NewGcpPolicyParser(policyText string, dec decoder.Decoder, strict bool) (*GcpParser, error) {
 	return &GcpParser{
 		policyText: policyText,
-		urlEscaped: escaped,
+		decoder:    dec,
+		strict:     strict,
 	}, nil
 }
 
@@ -20,6 +23,14 @@ func (a *GcpParser) Parse() error {
 	return nil
 }
 
+func (a *GcpParser) Validate() error {
+	return nil
+}
+
+func (a *GcpParser) Warnings() []error {
+	return nil
+}
+
 func (a *GcpParser) GetPolicy() ([]*policy.Policy, error) {
 	return nil, nil
 }
@@ -27,7 +38,3 @@ func (a *GcpParser) GetPolicy() ([]*policy.Policy, error) {
 func (a *GcpParser) Json() ([]byte, error) {
 	return nil, nil
 }
-
-func (a *GcpParser) WriteJson(filename string) error {
-	return nil
-}
@@ -0,0 +1,252 @@
+package access
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/index"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Grant is a principal allowed to perform the action on the resource.
+type Grant struct {
+	// Principal is the principal glob named by the granting statements,
+	// e.g. arn:aws:iam::111111111111:root, the arn of the identity an
+	// identity policy is attached to, or "attached:<policy>" when the
+	// identity is unknown.
+	Principal string `json:"principal" yaml:"principal"`
+	// Except are the principals excluded by the NotPrincipal of the
+	// granting statements.
+	Except     []string `json:"except,omitempty" yaml:"except,omitempty"`
+	Statements []string `json:"statements" yaml:"statements"`
+	// Conditional is set when every granting statement has conditions.
+	Conditional bool `json:"conditional" yaml:"conditional"`
+	// Partial is set when access is granted on part of the resource only.
+	Partial bool `json:"partial" yaml:"partial"`
+	// LimitedBy are the deny statements taking away part of the access,
+	// or all of it under conditions.
+	LimitedBy []string `json:"limited-by,omitempty" yaml:"limited-by,omitempty"`
+}
+
+// Denial is a principal granted access that a deny statement takes away
+// entirely.
+type Denial struct {
+	Principal string   `json:"principal" yaml:"principal"`
+	GrantedBy []string `json:"granted-by" yaml:"granted-by"`
+	DeniedBy  []string `json:"denied-by" yaml:"denied-by"`
+}
+
+// Result lists who can perform an action on a resource.
+type Result struct {
+	Action   string    `json:"action" yaml:"action"`
+	Resource string    `json:"resource" yaml:"resource"`
+	Grants   []*Grant  `json:"grants" yaml:"grants"`
+	Denied   []*Denial `json:"denied,omitempty" yaml:"denied,omitempty"`
+}
+
+// scope is how much of a queried value a statement field covers.
+type scope int
+
+const (
+	none scope = iota
+	partial
+	full
+)
+
+// Query answers who-can-access questions over indexed policies.
+type Query struct {
+	index *index.Index
+}
+
+// NewQuery indexes policies for who-can-access lookups.
+func NewQuery(policies []*policy.Policy) *Query {
+	return &Query{index: index.New(policies)}
+}
+
+// WhoCan returns the principals allowed to perform action on resource, a
+// resource arn or glob, e.g. arn:aws:s3:::prod-data/*, with the statements
+// granting them access. Statements granting access to part of the
+// resource make a partial grant. A deny statement that names the principal,
+// covers the whole resource and has no conditions takes the grant away;
+// any other deny that may apply limits it.
+//
+// Only identity, resource and trust policies grant access; deny statements
+// of any kind are taken into account, those naming no principal applying
+// to the identities of their policy, or to everyone for organization
+// policies. Accounts are not checked: a principal of another account named
+// by a resource policy is reported as granted.
+func (q *Query) WhoCan(action, resource string) *Result {
+	if resource == "" {
+		resource = "*"
+	}
+	r := &Result{Action: action, Resource: resource, Grants: []*Grant{}}
+
+	candidates := []*policy.Policy{}
+	for _, p := range q.index.Lookup(index.Query{Action: action, Resource: resource}) {
+		if p.MatchesAction(action) && resourceScope(p, resource) != none {
+			candidates = append(candidates, p)
+		}
+	}
+
+	grants := map[string]*Grant{}
+	unconditional := map[string]bool{}
+	whole := map[string]bool{}
+	for _, p := range candidates {
+		if !p.Allowed || !granting(p) {
+			continue
+		}
+		except := p.Globs(policy.FieldNotSubjects)
+		for _, principal := range principalsOf(p) {
+			key := principal + "|" + strings.Join(except, ",")
+			g, ok := grants[key]
+			if !ok {
+				g = &Grant{Principal: principal, Statements: []string{}}
+				if len(p.Globs(policy.FieldSubjects)) == 0 {
+					g.Except = except
+				}
+				grants[key] = g
+			}
+			g.Statements = append(g.Statements, p.Id)
+			unconditional[key] = unconditional[key] || len(p.Condition) == 0
+			whole[key] = whole[key] || resourceScope(p, resource) == full
+		}
+	}
+
+	keys := []string{}
+	for k := range grants {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		g := grants[k]
+		g.Conditional = !unconditional[k]
+		g.Partial = !whole[k]
+
+		deniedBy := []string{}
+		for _, p := range candidates {
+			if p.Allowed {
+				continue
+			}
+			s := principalScope(p, g.Principal)
+			if s == none {
+				continue
+			}
+			if s == full && resourceScope(p, resource) == full && len(p.Condition) == 0 {
+				deniedBy = append(deniedBy, p.Id)
+			} else {
+				g.LimitedBy = append(g.LimitedBy, p.Id)
+			}
+		}
+		if len(deniedBy) > 0 {
+			r.Denied = append(r.Denied, &Denial{Principal: g.Principal, GrantedBy: g.Statements, DeniedBy: deniedBy})
+			continue
+		}
+		r.Grants = append(r.Grants, g)
+	}
+	return r
+}
+
+// WhoCan is a one-off Query.WhoCan.
+func WhoCan(policies []*policy.Policy, action, resource string) *Result {
+	return NewQuery(policies).WhoCan(action, resource)
+}
+
+// granting reports whether an Allow statement of p grants access, rather
+// than limiting what other policies grant.
+func granting(p *policy.Policy) bool {
+	switch p.Kind {
+	case "", policy.KindIdentity, policy.KindResource, policy.KindTrust:
+		return true
+	}
+	return false
+}
+
+// principalsOf returns the principals a statement applies to: its
+// Principal, everyone for a NotPrincipal, or else the identities its policy
+// is attached to.
+func principalsOf(p *policy.Policy) []string {
+	if subjects := p.Globs(policy.FieldSubjects); len(subjects) > 0 {
+		return subjects
+	}
+	if len(p.Globs(policy.FieldNotSubjects)) > 0 {
+		return []string{"*"}
+	}
+	return attachedTo(p)
+}
+
+func attachedTo(p *policy.Policy) []string {
+	x := []string{}
+	if p.Origin != nil {
+		for _, a := range p.Origin.AttachedTo {
+			if a.Arn != "" {
+				x = append(x, a.Arn)
+			} else {
+				x = append(x, a.Type+"/"+a.Name)
+			}
+		}
+	}
+	if len(x) == 0 {
+		x = append(x, "attached:"+p.DocumentName())
+	}
+	return x
+}
+
+// principalScope is how much of the principals matched by glob the deny
+// statement p applies to.
+func principalScope(p *policy.Policy, glob string) scope {
+	if subjects := p.Globs(policy.FieldSubjects); len(subjects) > 0 {
+		return globScope(subjects, glob)
+	}
+	if notSubjects := p.Globs(policy.FieldNotSubjects); len(notSubjects) > 0 {
+		return notGlobScope(notSubjects, glob)
+	}
+	switch p.Kind {
+	case "", policy.KindIdentity, policy.KindBoundary, policy.KindSession:
+		for _, principal := range attachedTo(p) {
+			if principal == glob {
+				return full
+			}
+		}
+		return none
+	}
+	return full
+}
+
+// resourceScope is how much of the resources matched by glob statement p
+// applies to.
+func resourceScope(p *policy.Policy, glob string) scope {
+	if resources := p.Globs(policy.FieldResources); len(resources) > 0 {
+		return globScope(resources, glob)
+	}
+	if notResources := p.Globs(policy.FieldNotResources); len(notResources) > 0 {
+		return notGlobScope(notResources, glob)
+	}
+	return full
+}
+
+func globScope(globs []string, glob string) scope {
+	s := none
+	for _, g := range globs {
+		if policy.GlobContains(g, glob) {
+			return full
+		}
+		if policy.GlobsOverlap(g, glob) {
+			s = partial
+		}
+	}
+	return s
+}
+
+func notGlobScope(globs []string, glob string) scope {
+	s := full
+	for _, g := range globs {
+		if policy.GlobContains(g, glob) {
+			return none
+		}
+		if policy.GlobsOverlap(g, glob) {
+			s = partial
+		}
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func attached(p *policy.Policy, arns ...string) *policy.Policy {
+	p.Origin = &policy.Origin{Type: policy.OriginManaged, Name: p.Id}
+	for _, arn := range arns {
+		p.Origin.AttachedTo = append(p.Origin.AttachedTo, policy.Attachment{Type: "user", Arn: arn})
+	}
+	return p
+}
+
+const (
+	alice = "arn:aws:iam::111111111111:user/alice"
+	bob   = "arn:aws:iam::111111111111:user/bob"
+	other = "arn:aws:iam::222222222222:root"
+)
+
+func principals(r *Result) []string {
+	x := []string{}
+	for _, g := range r.Grants {
+		x = append(x, g.Principal)
+	}
+	return x
+}
+
+func TestWhoCan(t *testing.T) {
+	policies := []*policy.Policy{
+		attached(policy.NewStatement("analysts:0", policy.KindIdentity, true, nil, []string{"s3:Get*", "s3:List*"}, []string{"arn:aws:s3:::prod-data/*"}), alice, bob),
+		attached(policy.NewStatement("admins:0", policy.KindIdentity, true, nil, []string{"*"}, []string{"*"}), "arn:aws:iam::111111111111:role/admin"),
+		policy.NewStatement("bucket:0", policy.KindResource, true, []string{other}, []string{"s3:GetObject"}, []string{"arn:aws:s3:::prod-data/shared/*"}),
+		policy.NewStatement("bucket:1", policy.KindResource, false, []string{"*"}, []string{"s3:*"}, []string{"arn:aws:s3:::prod-data/secret/*"}),
+		attached(policy.NewStatement("bob-deny:0", policy.KindIdentity, false, nil, []string{"s3:GetObject"}, []string{"*"}), bob),
+		attached(policy.NewStatement("ec2:0", policy.KindIdentity, true, nil, []string{"ec2:*"}, []string{"*"}), alice),
+	}
+
+	r := WhoCan(policies, "s3:GetObject", "arn:aws:s3:::prod-data/*")
+	assert.EqualValues(t, []string{
+		"arn:aws:iam::111111111111:role/admin",
+		alice,
+		other,
+	}, principals(r))
+
+	admin, analyst, shared := r.Grants[0], r.Grants[1], r.Grants[2]
+	assert.EqualValues(t, []string{"admins:0"}, admin.Statements)
+	assert.False(t, admin.Partial)
+	assert.EqualValues(t, []string{"bucket:1"}, admin.LimitedBy)
+	assert.EqualValues(t, []string{"analysts:0"}, analyst.Statements)
+	assert.True(t, shared.Partial)
+
+	assert.Len(t, r.Denied, 1)
+	assert.EqualValues(t, bob, r.Denied[0].Principal)
+	assert.EqualValues(t, []string{"bob-deny:0"}, r.Denied[0].DeniedBy)
+
+	// a narrower resource is outside the shared prefix and the deny
+	r = WhoCan(policies, "s3:GetObject", "arn:aws:s3:::prod-data/reports/q1.csv")
+	assert.EqualValues(t, []string{"arn:aws:iam::111111111111:role/admin", alice}, principals(r))
+	assert.Empty(t, r.Grants[0].LimitedBy)
+
+	r = WhoCan(policies, "ec2:RunInstances", "")
+	assert.EqualValues(t, []string{"arn:aws:iam::111111111111:role/admin", alice}, principals(r))
+}
+
+func TestWhoCan_NotFields(t *testing.T) {
+	everyoneBut := policy.NewStatement("bucket:0", policy.KindResource, true, nil, nil, []string{"arn:aws:s3:::public/*"})
+	everyoneBut.NotSubjects, everyoneBut.Patterns.NotSubjects = policy.NewPatterns([]string{other}, false)
+	everyoneBut.NotActions, everyoneBut.Patterns.NotActions = policy.NewPatterns([]string{"s3:Delete*"}, true)
+
+	scp := policy.NewStatement("scp:0", policy.KindSCP, false, nil, nil, nil)
+	scp.NotActions, scp.Patterns.NotActions = policy.NewPatterns([]string{"s3:Get*", "s3:Delete*"}, true)
+	scp.NotResources, scp.Patterns.NotResources = policy.NewPatterns([]string{"arn:aws:s3:::public/*"}, false)
+
+	policies := []*policy.Policy{everyoneBut, scp}
+
+	r := WhoCan(policies, "s3:GetObject", "arn:aws:s3:::public/index.html")
+	assert.EqualValues(t, []string{"*"}, principals(r))
+	assert.EqualValues(t, []string{other}, r.Grants[0].Except)
+	assert.Empty(t, r.Grants[0].LimitedBy)
+
+	r = WhoCan(policies, "s3:DeleteObject", "arn:aws:s3:::public/index.html")
+	assert.Empty(t, r.Grants)
+
+	// the scp denies everything but Get and Delete outside public
+	r = WhoCan(policies, "s3:PutObject", "arn:aws:s3:::public/*")
+	assert.EqualValues(t, []string{"*"}, principals(r))
+	assert.Empty(t, r.Grants[0].LimitedBy)
+	r = WhoCan(policies, "s3:PutObject", "arn:aws:s3:::*")
+	assert.EqualValues(t, []string{"*"}, principals(r))
+	assert.True(t, r.Grants[0].Partial)
+	assert.EqualValues(t, []string{"scp:0"}, r.Grants[0].LimitedBy)
+}
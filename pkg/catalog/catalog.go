@@ -0,0 +1,279 @@
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Access levels, as used by the IAM service authorization reference.
+const (
+	List        = "List"
+	Read        = "Read"
+	Write       = "Write"
+	Permissions = "Permissions management"
+	Tagging     = "Tagging"
+)
+
+// AccessLevels lists the access levels in the order summaries display
+// them, the one AWS uses in its policy summaries. It is not an order of
+// privilege: Tagging comes last but grants less than Permissions
+// management.
+var AccessLevels = []string{List, Read, Write, Permissions, Tagging}
+
+//go:embed catalog.json
+var embedded []byte
+
+var (
+	defaultOnce    sync.Once
+	defaultCatalog *Catalog
+)
+
+// Action is a single API call of a service.
+type Action struct {
+	Service       string   `json:"-" yaml:"-"`
+	Name          string   `json:"name" yaml:"name"`
+	AccessLevel   string   `json:"access-level" yaml:"access-level"`
+	ResourceTypes []string `json:"resource-types,omitempty" yaml:"resource-types,omitempty"`
+	ConditionKeys []string `json:"condition-keys,omitempty" yaml:"condition-keys,omitempty"`
+}
+
+// FullName returns the action as written in a policy, e.g. s3:GetObject.
+func (a *Action) FullName() string {
+	return a.Service + ":" + a.Name
+}
+
+// Service is the set of actions sharing a service prefix.
+type Service struct {
+	Prefix string `json:"prefix" yaml:"prefix"`
+	Name   string `json:"name" yaml:"name"`
+	// ConditionKeys apply to every action of the service, in addition to
+	// the keys of the action itself.
+	ConditionKeys []string  `json:"condition-keys,omitempty" yaml:"condition-keys,omitempty"`
+	Actions       []*Action `json:"actions" yaml:"actions"`
+	// Complete declares that Actions are all the actions of the service, so
+	// a wildcard matches none besides them. The embedded catalog declares
+	// no service complete.
+	Complete bool `json:"complete,omitempty" yaml:"complete,omitempty"`
+}
+
+// Catalog of the actions of the services known offline.
+type Catalog struct {
+	services map[string]*Service
+	actions  map[string]*Action
+}
+
+type catalogFile struct {
+	Services []*Service `json:"services"`
+}
+
+// Default returns the catalog embedded in the binary. It covers the most
+// common services only; use Load for a complete one.
+func Default() *Catalog {
+	defaultOnce.Do(func() {
+		c, err := Parse(embedded)
+		if err != nil {
+			panic(fmt.Sprintf("embedded action catalog: %s", err.Error()))
+		}
+		defaultCatalog = c
+	})
+	return defaultCatalog
+}
+
+// Load reads a catalog from a local JSON file with the same layout as the
+// embedded one.
+func Load(filename string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return c, nil
+}
+
+// Parse reads a catalog from its JSON representation.
+func Parse(data []byte) (*Catalog, error) {
+	f := catalogFile{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{
+		services: map[string]*Service{},
+		actions:  map[string]*Action{},
+	}
+	for _, s := range f.Services {
+		if s.Prefix == "" {
+			return nil, fmt.Errorf("service %q has no prefix", s.Name)
+		}
+		prefix := strings.ToLower(s.Prefix)
+		if _, ok := c.services[prefix]; ok {
+			return nil, fmt.Errorf("service %s is defined twice", s.Prefix)
+		}
+		c.services[prefix] = s
+		for _, a := range s.Actions {
+			a.Service = s.Prefix
+			if !knownLevel(a.AccessLevel) {
+				return nil, fmt.Errorf("action %s: unknown access level %q", a.FullName(), a.AccessLevel)
+			}
+			c.actions[strings.ToLower(a.FullName())] = a
+		}
+		sort.Slice(s.Actions, func(i, j int) bool { return s.Actions[i].Name < s.Actions[j].Name })
+	}
+	return c, nil
+}
+
+func knownLevel(level string) bool {
+	for _, l := range AccessLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Services returns the services of the catalog sorted by prefix.
+func (c *Catalog) Services() []*Service {
+	x := make([]*Service, 0, len(c.services))
+	for _, s := range c.services {
+		x = append(x, s)
+	}
+	sort.Slice(x, func(i, j int) bool { return x[i].Prefix < x[j].Prefix })
+	return x
+}
+
+// Service returns the service with the given prefix, or nil.
+func (c *Catalog) Service(prefix string) *Service {
+	return c.services[strings.ToLower(prefix)]
+}
+
+// Complete reports whether the catalog lists every action of the service
+// with the given prefix.
+func (c *Catalog) Complete(prefix string) bool {
+	s := c.Service(prefix)
+	return s != nil && s.Complete
+}
+
+// Lookup returns the action named like s3:GetObject, or nil. Names are
+// case-insensitive.
+func (c *Catalog) Lookup(name string) *Action {
+	return c.actions[strings.ToLower(name)]
+}
+
+// ConditionKeys returns the condition keys usable with a, its own and
+// those of its service.
+func (c *Catalog) ConditionKeys(a *Action) []string {
+	x := append([]string{}, a.ConditionKeys...)
+	if s := c.Service(a.Service); s != nil {
+		x = append(x, s.ConditionKeys...)
+	}
+	return x
+}
+
+// Expand returns the actions matching the action glob, e.g. s3:Get*,
+// sorted by name.
+func (c *Catalog) Expand(glob string) []*Action {
+	glob = strings.ToLower(glob)
+	x := []*Action{}
+	for _, s := range c.candidates(glob) {
+		for _, a := range s.Actions {
+			if policy.MatchGlob(glob, strings.ToLower(a.FullName())) {
+				x = append(x, a)
+			}
+		}
+	}
+	sortActions(x)
+	return x
+}
+
+// candidates returns the services that can hold actions matching glob,
+// i.e. all of them unless the service prefix is literal.
+func (c *Catalog) candidates(glob string) []*Service {
+	i := strings.Index(glob, ":")
+	if i < 0 || strings.ContainsAny(glob[:i], "*?$") {
+		return c.Services()
+	}
+	if s, ok := c.services[glob[:i]]; ok {
+		return []*Service{s}
+	}
+	return nil
+}
+
+// Expansion of the action patterns of a statement.
+type Expansion struct {
+	Actions []*Action `json:"actions" yaml:"actions"`
+	// Unknown lists the patterns matching nothing in the catalog, most often
+	// because their service is not in it. Their actions are missing from
+	// Actions.
+	Unknown []string `json:"unknown,omitempty" yaml:"unknown,omitempty"`
+}
+
+// Levels returns the access levels of the expanded actions, in the order
+// of AccessLevels.
+func (e *Expansion) Levels() []string {
+	seen := map[string]bool{}
+	for _, a := range e.Actions {
+		seen[a.AccessLevel] = true
+	}
+	x := []string{}
+	for _, l := range AccessLevels {
+		if seen[l] {
+			x = append(x, l)
+		}
+	}
+	return x
+}
+
+// ExpandPolicy returns the concrete actions in the scope of p: those
+// matching one of its Actions or, for NotAction, all the actions of the
+// catalog matching none of its NotActions.
+func (c *Catalog) ExpandPolicy(p *policy.Policy) *Expansion {
+	e := &Expansion{Actions: []*Action{}}
+
+	if actions := p.Globs(policy.FieldActions); len(actions) > 0 {
+		seen := map[*Action]bool{}
+		for _, glob := range actions {
+			matched := c.Expand(glob)
+			if len(matched) == 0 {
+				e.Unknown = append(e.Unknown, glob)
+			}
+			for _, a := range matched {
+				if !seen[a] {
+					seen[a] = true
+					e.Actions = append(e.Actions, a)
+				}
+			}
+		}
+		sortActions(e.Actions)
+		return e
+	}
+
+	if len(p.Globs(policy.FieldNotActions)) > 0 {
+		for _, s := range c.Services() {
+			for _, a := range s.Actions {
+				if p.MatchesAction(a.FullName()) {
+					e.Actions = append(e.Actions, a)
+				}
+			}
+		}
+	}
+	return e
+}
+
+func sortActions(x []*Action) {
+	sort.Slice(x, func(i, j int) bool {
+		if x[i].Service != x[j].Service {
+			return x[i].Service < x[j].Service
+		}
+		return x[i].Name < x[j].Name
+	})
+}
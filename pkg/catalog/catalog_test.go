@@ -0,0 +1,102 @@
+package catalog
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func names(actions []*Action) []string {
+	x := []string{}
+	for _, a := range actions {
+		x = append(x, a.FullName())
+	}
+	return x
+}
+
+func TestCatalog_Default(t *testing.T) {
+	c := Default()
+	a := c.Lookup("S3:getobject")
+	assert.NotNil(t, a)
+	if a == nil {
+		t.FailNow()
+	}
+	assert.EqualValues(t, "s3:GetObject", a.FullName())
+	assert.EqualValues(t, Read, a.AccessLevel)
+	assert.EqualValues(t, []string{"object"}, a.ResourceTypes)
+	assert.Contains(t, c.ConditionKeys(a), "s3:ExistingObjectTag/${TagKey}")
+
+	assert.Nil(t, c.Lookup("s3:NoSuchAction"))
+	assert.NotNil(t, c.Service("iam"))
+	assert.False(t, c.Complete("s3"))
+}
+
+func TestCatalog_Expand(t *testing.T) {
+	c := Default()
+
+	x := c.Expand("s3:GetObject*")
+	assert.EqualValues(t, []string{"s3:GetObject", "s3:GetObjectAcl", "s3:GetObjectTagging", "s3:GetObjectVersion"}, names(x))
+
+	x = c.Expand("sts:AssumeRole????????")
+	assert.EqualValues(t, []string{"sts:AssumeRoleWithSAML"}, names(x))
+
+	x = c.Expand("*:TagResource")
+	assert.Contains(t, names(x), "kms:TagResource")
+	assert.Contains(t, names(x), "lambda:TagResource")
+
+	assert.Len(t, c.Expand("unknown:*"), 0)
+	assert.Equal(t, len(c.actions), len(c.Expand("*")))
+}
+
+func TestCatalog_ExpandPolicy(t *testing.T) {
+	c := Default()
+
+	p := &policy.Policy{Allowed: true}
+	p.Actions, p.Patterns.Actions = policy.NewPatterns([]string{"iam:List*", "iam:Get*", "iam:ListRoles", "athena:*"}, true)
+	e := c.ExpandPolicy(p)
+	assert.EqualValues(t, []string{"athena:*"}, e.Unknown)
+	assert.Contains(t, names(e.Actions), "iam:ListRoles")
+	assert.Contains(t, names(e.Actions), "iam:GetRole")
+	assert.EqualValues(t, []string{List, Read}, e.Levels())
+	seen := map[string]bool{}
+	for _, n := range names(e.Actions) {
+		assert.False(t, seen[n], n)
+		seen[n] = true
+	}
+
+	p = &policy.Policy{Allowed: true}
+	p.NotActions, p.Patterns.NotActions = policy.NewPatterns([]string{"iam:*", "sts:*"}, true)
+	e = c.ExpandPolicy(p)
+	assert.NotContains(t, names(e.Actions), "iam:PassRole")
+	assert.Contains(t, names(e.Actions), "s3:PutObject")
+	assert.Len(t, e.Unknown, 0)
+}
+
+func TestCatalog_Load(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "catalog.json")
+	text := `{"services": [{"prefix": "acme", "name": "Acme", "actions": [
+		{"name": "ReadThing", "access-level": "Read", "resource-types": ["thing"]},
+		{"name": "WriteThing", "access-level": "Write"}
+	]}]}`
+	err := ioutil.WriteFile(filename, []byte(text), 0644)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	c, err := Load(filename)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.EqualValues(t, []string{"acme:ReadThing", "acme:WriteThing"}, names(c.Expand("acme:*")))
+	assert.Nil(t, c.Lookup("s3:GetObject"))
+
+	_, err = Parse([]byte(`{"services": [{"prefix": "acme", "actions": [{"name": "X", "access-level": "Admin"}]}]}`))
+	assert.NotNil(t, err)
+}
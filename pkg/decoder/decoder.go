@@ -0,0 +1,307 @@
+package decoder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+const (
+	None       = "none"
+	Url        = "url"
+	Base64     = "base64"
+	Gzip       = "gzip"
+	JsonString = "json"
+	Auto       = "auto"
+)
+
+// maxAutoRounds bounds the number of decoders auto-detection will stack,
+// so that a pathological input cannot keep the detector spinning.
+const maxAutoRounds = 8
+
+// ErrTooLarge is returned by decoders whose output would exceed the limit
+// set with Pipeline.Limit.
+var ErrTooLarge = errors.New("decoded input is too large")
+
+// Decoder turns the raw text handed to a parser into the policy document.
+type Decoder interface {
+	Name() string
+	Decode(string) (string, error)
+}
+
+// Pipeline applies its decoders in order, feeding the output of one into the
+// next.
+type Pipeline []Decoder
+
+func (p Pipeline) Name() string {
+	names := []string{}
+	for _, d := range p {
+		names = append(names, d.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (p Pipeline) Decode(text string) (string, error) {
+	var err error
+	for _, d := range p {
+		text, err = d.Decode(text)
+		if err != nil {
+			return "", fmt.Errorf("%s decoder: %w", d.Name(), err)
+		}
+	}
+	return text, nil
+}
+
+// limiter is implemented by the decoders whose output can be larger than
+// their input, i.e. the decompressing ones.
+type limiter interface {
+	withLimit(max int64) Decoder
+	limit() int64
+}
+
+// Limit returns p with the output of each of its decoders bounded by max
+// bytes, past which they fail with ErrTooLarge instead of decompressing
+// the rest of their input. The bound is kept whatever the decoders, for
+// MaxBytes to pass it on to the decoding of documents embedded in the
+// output.
+func (p Pipeline) Limit(max int64) Decoder {
+	x := Pipeline{}
+	for _, d := range p {
+		if l, ok := d.(limiter); ok {
+			d = l.withLimit(max)
+		}
+		x = append(x, d)
+	}
+	return limited{Pipeline: x, max: max}
+}
+
+// limited is a pipeline with the bound Limit set on it.
+type limited struct {
+	Pipeline
+	max int64
+}
+
+func (l limited) withLimit(max int64) Decoder {
+	return l.Pipeline.Limit(max)
+}
+
+func (l limited) limit() int64 {
+	return l.max
+}
+
+// MaxBytes returns the bound Limit set on the output of d, 0 if none.
+func MaxBytes(d Decoder) int64 {
+	switch x := d.(type) {
+	case Pipeline:
+		max := int64(0)
+		for _, y := range x {
+			if m := MaxBytes(y); m > 0 && (max == 0 || m < max) {
+				max = m
+			}
+		}
+		return max
+	case limiter:
+		return x.limit()
+	}
+	return 0
+}
+
+// NewDecoder returns the decoder registered under name.
+func NewDecoder(name string) (Decoder, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case None, "":
+		return noneDecoder{}, nil
+	case Url:
+		return urlDecoder{}, nil
+	case Base64:
+		return base64Decoder{}, nil
+	case Gzip:
+		return gzipDecoder{}, nil
+	case JsonString:
+		return jsonStringDecoder{}, nil
+	case Auto:
+		return autoDecoder{}, nil
+	}
+	return nil, fmt.Errorf("%s is not a supported decoder", name)
+}
+
+// NewPipeline builds a pipeline from a list of decoder names, e.g.
+// ["base64", "gzip"].
+func NewPipeline(names ...string) (Pipeline, error) {
+	p := Pipeline{}
+	for _, name := range names {
+		d, err := NewDecoder(name)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, d)
+	}
+	return p, nil
+}
+
+// FromEscaped maps the old url-escaped flag onto a decoder.
+func FromEscaped(escaped bool) Decoder {
+	if escaped {
+		return urlDecoder{}
+	}
+	return noneDecoder{}
+}
+
+// Detect returns the decoder that should be applied next to text, or nil if
+// text already looks like a JSON document.
+func Detect(text string) Decoder {
+	t := strings.TrimSpace(text)
+	switch {
+	case t == "":
+		return nil
+	case strings.HasPrefix(t, "{"), strings.HasPrefix(t, "["):
+		return nil
+	case strings.HasPrefix(t, "\x1f\x8b"):
+		return gzipDecoder{}
+	case strings.HasPrefix(t, "\""):
+		return jsonStringDecoder{}
+	case strings.HasPrefix(t, "%"):
+		return urlDecoder{}
+	}
+	if _, err := decodeBase64(t); err == nil {
+		return base64Decoder{}
+	}
+	if strings.Contains(t, "%") {
+		return urlDecoder{}
+	}
+	return nil
+}
+
+type noneDecoder struct{}
+
+func (noneDecoder) Name() string { return None }
+
+func (noneDecoder) Decode(text string) (string, error) {
+	return text, nil
+}
+
+type urlDecoder struct{}
+
+func (urlDecoder) Name() string { return Url }
+
+func (urlDecoder) Decode(text string) (string, error) {
+	return url.QueryUnescape(text)
+}
+
+type base64Decoder struct{}
+
+func (base64Decoder) Name() string { return Base64 }
+
+func (base64Decoder) Decode(text string) (string, error) {
+	b, err := decodeBase64(strings.TrimSpace(text))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func decodeBase64(text string) ([]byte, error) {
+	text = strings.Join(strings.Fields(text), "")
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	} {
+		if b, err := enc.DecodeString(text); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("not base64 encoded")
+}
+
+type gzipDecoder struct {
+	max int64
+}
+
+func (gzipDecoder) Name() string { return Gzip }
+
+func (d gzipDecoder) Decode(text string) (string, error) {
+	r, err := gzip.NewReader(bytes.NewReader([]byte(text)))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	var src io.Reader = r
+	if d.max > 0 {
+		src = io.LimitReader(r, d.max+1)
+	}
+	b, err := ioutil.ReadAll(src)
+	if err != nil {
+		return "", err
+	}
+	if d.max > 0 && int64(len(b)) > d.max {
+		return "", fmt.Errorf("%w: over %d bytes", ErrTooLarge, d.max)
+	}
+	return string(b), nil
+}
+
+func (d gzipDecoder) withLimit(max int64) Decoder {
+	d.max = max
+	return d
+}
+
+func (d gzipDecoder) limit() int64 {
+	return d.max
+}
+
+// jsonStringDecoder unwraps a document that was serialized as a JSON string
+// inside another JSON document.
+type jsonStringDecoder struct{}
+
+func (jsonStringDecoder) Name() string { return JsonString }
+
+func (jsonStringDecoder) Decode(text string) (string, error) {
+	var s string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// autoDecoder keeps detecting and applying decoders until the text looks
+// like a JSON document.
+type autoDecoder struct {
+	max int64
+}
+
+func (autoDecoder) Name() string { return Auto }
+
+func (a autoDecoder) Decode(text string) (string, error) {
+	for i := 0; i < maxAutoRounds; i++ {
+		d := Detect(text)
+		if d == nil {
+			return text, nil
+		}
+		if l, ok := d.(limiter); ok && a.max > 0 {
+			d = l.withLimit(a.max)
+		}
+		decoded, err := d.Decode(text)
+		if err != nil {
+			return "", fmt.Errorf("%s decoder: %w", d.Name(), err)
+		}
+		text = decoded
+	}
+	return "", fmt.Errorf("input is still encoded after %d decoding rounds", maxAutoRounds)
+}
+
+func (a autoDecoder) withLimit(max int64) Decoder {
+	a.max = max
+	return a
+}
+
+func (a autoDecoder) limit() int64 {
+	return a.max
+}
@@ -0,0 +1,90 @@
+package decoder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const document = `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`
+
+func gzipped(t *testing.T, s string) string {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write([]byte(s))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	return b.String()
+}
+
+func TestPipeline_Decode(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(gzipped(t, document)))
+
+	p, err := NewPipeline(Base64, Gzip)
+	assert.Nil(t, err)
+
+	decoded, err := p.Decode(encoded)
+	assert.Nil(t, err)
+	assert.EqualValues(t, document, decoded)
+	assert.EqualValues(t, "base64,gzip", p.Name())
+
+	_, err = NewPipeline("rot13")
+	assert.NotNil(t, err)
+}
+
+func TestAuto_Decode(t *testing.T) {
+	quoted, err := json.Marshal(document)
+	assert.Nil(t, err)
+
+	inputs := map[string]string{
+		"plain":      document,
+		"url":        url.QueryEscape(document),
+		"double-url": url.QueryEscape(url.QueryEscape(document)),
+		"base64":     base64.StdEncoding.EncodeToString([]byte(document)),
+		"gzip":       gzipped(t, document),
+		"base64-gzip": base64.StdEncoding.EncodeToString(
+			[]byte(gzipped(t, document))),
+		"json-string": string(quoted),
+		"json-url":    string(quoted[0]) + url.QueryEscape(document) + string(quoted[len(quoted)-1]),
+	}
+
+	d, err := NewDecoder(Auto)
+	assert.Nil(t, err)
+
+	for name, input := range inputs {
+		decoded, err := d.Decode(input)
+		assert.Nil(t, err, name)
+		assert.EqualValues(t, document, decoded, name)
+	}
+}
+
+func TestPipeline_Limit(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(gzipped(t, document)))
+
+	for _, names := range [][]string{{Base64, Gzip}, {Auto}} {
+		p, err := NewPipeline(names...)
+		assert.Nil(t, err)
+
+		decoded, err := p.Limit(int64(len(document))).Decode(encoded)
+		assert.Nil(t, err)
+		assert.EqualValues(t, document, decoded)
+
+		_, err = p.Limit(int64(len(document) - 1)).Decode(encoded)
+		assert.True(t, errors.Is(err, ErrTooLarge), "%v", err)
+		assert.EqualValues(t, int64(len(document)-1), MaxBytes(p.Limit(int64(len(document)-1))))
+		assert.EqualValues(t, 0, MaxBytes(p))
+	}
+
+	// the bound is kept by pipelines with no decoder to apply it to
+	for _, names := range [][]string{{None}, {Url}} {
+		p, err := NewPipeline(names...)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 100, MaxBytes(p.Limit(100)), names)
+	}
+}
@@ -0,0 +1,343 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+const (
+	Added     = "added"
+	Removed   = "removed"
+	Changed   = "changed"
+	Unchanged = "unchanged"
+)
+
+// Changes lists the entries of one policy field that were added or removed.
+type Changes struct {
+	Added   []string `json:"added,omitempty" yaml:"added,omitempty"`
+	Removed []string `json:"removed,omitempty" yaml:"removed,omitempty"`
+}
+
+func (c Changes) empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// StatementDiff is the difference between a statement of the old policy and
+// the statement of the new policy it was matched with.
+type StatementDiff struct {
+	Sid           string         `json:"sid,omitempty" yaml:"sid,omitempty"`
+	Old           *policy.Policy `json:"-" yaml:"-"`
+	New           *policy.Policy `json:"-" yaml:"-"`
+	OldId         string         `json:"old-id,omitempty" yaml:"old-id,omitempty"`
+	NewId         string         `json:"new-id,omitempty" yaml:"new-id,omitempty"`
+	Status        string         `json:"status" yaml:"status"` // added, removed, changed or unchanged
+	Effect        string         `json:"effect,omitempty" yaml:"effect,omitempty"`
+	Actions       Changes        `json:"actions" yaml:"actions"`
+	NotActions    Changes        `json:"not-actions" yaml:"not-actions"`
+	Resources     Changes        `json:"resources" yaml:"resources"`
+	NotResources  Changes        `json:"not-resources" yaml:"not-resources"`
+	Principals    Changes        `json:"principals" yaml:"principals"`
+	NotPrincipals Changes        `json:"not-principals" yaml:"not-principals"`
+	Conditions    Changes        `json:"conditions" yaml:"conditions"`
+	Broadens      bool           `json:"broadens" yaml:"broadens"` // the change may grant access that was not granted before
+	Narrows       bool           `json:"narrows" yaml:"narrows"`   // the change may take away access that was granted before
+}
+
+// Diff is the semantic difference between two versions of a policy.
+type Diff struct {
+	Statements []*StatementDiff `json:"statements" yaml:"statements"`
+	Broadens   bool             `json:"broadens" yaml:"broadens"`
+	Narrows    bool             `json:"narrows" yaml:"narrows"`
+}
+
+// Compare matches the statements of before and after, first by Sid and then
+// by content, and reports what changed in each.
+func Compare(before, after []*policy.Policy) *Diff {
+	d := &Diff{Statements: []*StatementDiff{}}
+
+	matchedOld := map[int]bool{}
+	matchedNew := map[int]bool{}
+	pairs := [][2]int{}
+
+	// statements with the same Sid are the same statement
+	for i, o := range before {
+		if o.Sid == "" {
+			continue
+		}
+		for j, n := range after {
+			if !matchedNew[j] && n.Sid == o.Sid {
+				pairs = append(pairs, [2]int{i, j})
+				matchedOld[i], matchedNew[j] = true, true
+				break
+			}
+		}
+	}
+
+	// the remaining statements are matched greedily by similarity
+	type candidate struct {
+		i, j  int
+		score float64
+	}
+	candidates := []candidate{}
+	for i, o := range before {
+		if matchedOld[i] {
+			continue
+		}
+		for j, n := range after {
+			if matchedNew[j] || o.Allowed != n.Allowed {
+				continue
+			}
+			if (o.Sid != "" && n.Sid != "") && o.Sid != n.Sid {
+				continue
+			}
+			if score := similarity(o, n); score > 0 {
+				candidates = append(candidates, candidate{i, j, score})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(x, y int) bool {
+		return candidates[x].score > candidates[y].score
+	})
+	for _, c := range candidates {
+		if matchedOld[c.i] || matchedNew[c.j] {
+			continue
+		}
+		pairs = append(pairs, [2]int{c.i, c.j})
+		matchedOld[c.i], matchedNew[c.j] = true, true
+	}
+	sort.Slice(pairs, func(x, y int) bool {
+		return pairs[x][0] < pairs[y][0]
+	})
+
+	for _, pair := range pairs {
+		d.Statements = append(d.Statements, compareStatements(before[pair[0]], after[pair[1]]))
+	}
+	for i, o := range before {
+		if !matchedOld[i] {
+			d.Statements = append(d.Statements, compareStatements(o, nil))
+		}
+	}
+	for j, n := range after {
+		if !matchedNew[j] {
+			d.Statements = append(d.Statements, compareStatements(nil, n))
+		}
+	}
+
+	for _, s := range d.Statements {
+		d.Broadens = d.Broadens || s.Broadens
+		d.Narrows = d.Narrows || s.Narrows
+	}
+	return d
+}
+
+func compareStatements(o, n *policy.Policy) *StatementDiff {
+	s := &StatementDiff{Old: o, New: n}
+	empty := &policy.Policy{}
+
+	switch {
+	case o == nil:
+		s.Status = Added
+		s.Sid, s.NewId = n.Sid, n.Id
+		s.Effect = effect(n)
+		o = empty
+	case n == nil:
+		s.Status = Removed
+		s.Sid, s.OldId = o.Sid, o.Id
+		s.Effect = effect(o)
+		n = empty
+	default:
+		s.Status = Changed
+		s.Sid, s.OldId, s.NewId = n.Sid, o.Id, n.Id
+		s.Effect = effect(n)
+		if o.Allowed != n.Allowed {
+			s.Effect = fmt.Sprintf("%s -> %s", effect(o), effect(n))
+		}
+	}
+
+	s.Actions = changes(o.Globs(policy.FieldActions), n.Globs(policy.FieldActions), true)
+	s.NotActions = changes(o.Globs(policy.FieldNotActions), n.Globs(policy.FieldNotActions), true)
+	s.Resources = changes(o.Globs(policy.FieldResources), n.Globs(policy.FieldResources), false)
+	s.NotResources = changes(o.Globs(policy.FieldNotResources), n.Globs(policy.FieldNotResources), false)
+	s.Principals = changes(o.Globs(policy.FieldSubjects), n.Globs(policy.FieldSubjects), false)
+	s.NotPrincipals = changes(o.Globs(policy.FieldNotSubjects), n.Globs(policy.FieldNotSubjects), false)
+	s.Conditions = changes(o.ConditionStrings(), n.ConditionStrings(), false)
+
+	if s.Status == Changed && o.Allowed == n.Allowed &&
+		s.Actions.empty() && s.NotActions.empty() &&
+		s.Resources.empty() && s.NotResources.empty() &&
+		s.Principals.empty() && s.NotPrincipals.empty() &&
+		s.Conditions.empty() {
+		s.Status = Unchanged
+	}
+
+	s.Broadens, s.Narrows = direction(s, o, n)
+	return s
+}
+
+// direction works out whether the statement change grants more (broadens)
+// or less (narrows) access. Both can be true, e.g. when one action is swapped
+// for another.
+func direction(s *StatementDiff, o, n *policy.Policy) (bool, bool) {
+	switch s.Status {
+	case Unchanged:
+		return false, false
+	case Added:
+		return n.Allowed, !n.Allowed
+	case Removed:
+		return !o.Allowed, o.Allowed
+	}
+	if o.Allowed != n.Allowed {
+		return n.Allowed, o.Allowed
+	}
+
+	// wider or narrower scope of the statement itself
+	wider, narrower := false, false
+	scope := func(before, after []string, foldCase bool) {
+		w, n := widens(before, after, foldCase)
+		// an empty field does not restrict the statement at all
+		switch {
+		case len(before) == 0 && len(after) > 0:
+			w, n = false, true
+		case len(after) == 0 && len(before) > 0:
+			w, n = true, false
+		}
+		wider, narrower = wider || w, narrower || n
+	}
+	// the Not* fields scope the statement the other way around
+	inverse := func(before, after []string, foldCase bool) {
+		w, n := widens(before, after, foldCase)
+		wider, narrower = wider || n, narrower || w
+	}
+
+	scope(o.Globs(policy.FieldActions), n.Globs(policy.FieldActions), true)
+	inverse(o.Globs(policy.FieldNotActions), n.Globs(policy.FieldNotActions), true)
+	scope(o.Globs(policy.FieldResources), n.Globs(policy.FieldResources), false)
+	inverse(o.Globs(policy.FieldNotResources), n.Globs(policy.FieldNotResources), false)
+	scope(o.Globs(policy.FieldSubjects), n.Globs(policy.FieldSubjects), false)
+	inverse(o.Globs(policy.FieldNotSubjects), n.Globs(policy.FieldNotSubjects), false)
+
+	// conditions restrict the statement: removing one widens it
+	if len(s.Conditions.Removed) > 0 {
+		wider = true
+	}
+	if len(s.Conditions.Added) > 0 {
+		narrower = true
+	}
+
+	if n.Allowed {
+		return wider, narrower
+	}
+	// a wider Deny grants less
+	return narrower, wider
+}
+
+// widens reports whether after matches values before did not (wider) and
+// whether before matches values after does not (narrower).
+func widens(before, after []string, foldCase bool) (bool, bool) {
+	if foldCase {
+		before, after = lower(before), lower(after)
+	}
+	wider, narrower := false, false
+	for _, a := range after {
+		if !coveredBy(a, before) {
+			wider = true
+		}
+	}
+	for _, b := range before {
+		if !coveredBy(b, after) {
+			narrower = true
+		}
+	}
+	return wider, narrower
+}
+
+func coveredBy(glob string, globs []string) bool {
+	for _, g := range globs {
+		if policy.GlobContains(g, glob) {
+			return true
+		}
+	}
+	return false
+}
+
+func changes(before, after []string, foldCase bool) Changes {
+	c := Changes{}
+	key := func(s string) string {
+		if foldCase {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	inBefore := map[string]bool{}
+	for _, b := range before {
+		inBefore[key(b)] = true
+	}
+	inAfter := map[string]bool{}
+	for _, a := range after {
+		inAfter[key(a)] = true
+		if !inBefore[key(a)] {
+			c.Added = append(c.Added, a)
+		}
+	}
+	for _, b := range before {
+		if !inAfter[key(b)] {
+			c.Removed = append(c.Removed, b)
+		}
+	}
+	return c
+}
+
+// similarity is the share of actions, resources and principals two
+// statements have in common.
+func similarity(o, n *policy.Policy) float64 {
+	a := fieldSet(o)
+	b := fieldSet(n)
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for k := range a {
+		if b[k] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+func fieldSet(p *policy.Policy) map[string]bool {
+	x := map[string]bool{}
+	for _, field := range []string{
+		policy.FieldActions,
+		policy.FieldNotActions,
+		policy.FieldResources,
+		policy.FieldNotResources,
+		policy.FieldSubjects,
+		policy.FieldNotSubjects,
+	} {
+		for _, g := range p.Globs(field) {
+			if field == policy.FieldActions || field == policy.FieldNotActions {
+				g = strings.ToLower(g)
+			}
+			x[field+"|"+g] = true
+		}
+	}
+	return x
+}
+
+func effect(p *policy.Policy) string {
+	if p.Allowed {
+		return "Allow"
+	}
+	return "Deny"
+}
+
+func lower(x []string) []string {
+	y := []string{}
+	for _, s := range x {
+		y = append(y, strings.ToLower(s))
+	}
+	return y
+}
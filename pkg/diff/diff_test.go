@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func withSid(sid string, p *policy.Policy) *policy.Policy {
+	p.Sid = sid
+	return p
+}
+
+func TestCompare(t *testing.T) {
+	before := []*policy.Policy{
+		withSid("Read", policy.NewStatement("", "", true, nil, []string{"s3:GetObject", "s3:ListBucket"}, []string{"arn:aws:s3:::data/*"})),
+		policy.NewStatement("", "", false, nil, []string{"iam:*"}, []string{"*"}),
+		policy.NewStatement("", "", true, nil, []string{"sqs:SendMessage"}, []string{"*"}),
+	}
+	after := []*policy.Policy{
+		policy.NewStatement("", "", false, nil, []string{"iam:*", "organizations:*"}, []string{"*"}),
+		withSid("Read", policy.NewStatement("", "", true, nil, []string{"s3:Get*", "s3:ListBucket"}, []string{"arn:aws:s3:::data/*"})),
+		policy.NewStatement("", "", true, nil, []string{"sqs:SendMessage"}, []string{"*"}),
+		withSid("Write", policy.NewStatement("", "", true, nil, []string{"s3:PutObject"}, []string{"arn:aws:s3:::data/*"})),
+	}
+
+	d := Compare(before, after)
+	assert.Len(t, d.Statements, 4)
+	if len(d.Statements) != 4 {
+		t.FailNow()
+	}
+	assert.True(t, d.Broadens)
+	assert.True(t, d.Narrows)
+
+	read := d.Statements[0]
+	assert.EqualValues(t, "Read", read.Sid)
+	assert.EqualValues(t, Changed, read.Status)
+	assert.EqualValues(t, []string{"s3:Get*"}, read.Actions.Added)
+	assert.EqualValues(t, []string{"s3:GetObject"}, read.Actions.Removed)
+	assert.True(t, read.Broadens)
+	assert.False(t, read.Narrows)
+
+	deny := d.Statements[1]
+	assert.EqualValues(t, Changed, deny.Status)
+	assert.EqualValues(t, []string{"organizations:*"}, deny.Actions.Added)
+	assert.False(t, deny.Broadens)
+	assert.True(t, deny.Narrows)
+
+	assert.EqualValues(t, Unchanged, d.Statements[2].Status)
+	assert.False(t, d.Statements[2].Broadens)
+	assert.False(t, d.Statements[2].Narrows)
+
+	write := d.Statements[3]
+	assert.EqualValues(t, Added, write.Status)
+	assert.EqualValues(t, "Write", write.Sid)
+	assert.True(t, write.Broadens)
+}
+
+func TestCompare_Conditions(t *testing.T) {
+	before := policy.NewStatement("", "", true, nil, []string{"s3:GetObject"}, []string{"*"})
+	before.Condition = []policy.Condition{
+		{Operation: "Bool", Key: "aws:SecureTransport", Value: policy.BoolValues(true)},
+	}
+	after := policy.NewStatement("", "", true, nil, []string{"s3:GetObject"}, []string{"*"})
+	after.NotResources, after.Patterns.NotResources = policy.NewPatterns([]string{"arn:aws:s3:::secret/*"}, false)
+
+	d := Compare([]*policy.Policy{before}, []*policy.Policy{after})
+	assert.Len(t, d.Statements, 1)
+	s := d.Statements[0]
+	assert.EqualValues(t, []string{"Bool aws:SecureTransport [true]"}, s.Conditions.Removed)
+	assert.EqualValues(t, []string{"arn:aws:s3:::secret/*"}, s.NotResources.Added)
+	assert.True(t, s.Broadens)
+	assert.True(t, s.Narrows)
+
+	d = Compare([]*policy.Policy{before}, nil)
+	assert.EqualValues(t, Removed, d.Statements[0].Status)
+	assert.False(t, d.Broadens)
+	assert.True(t, d.Narrows)
+}
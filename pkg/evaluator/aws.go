@@ -0,0 +1,283 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Rules of the AWS policy evaluation logic, in the order they are checked.
+const (
+	RuleExplicitDeny = "explicit-deny"
+	RuleRCP          = "resource-control-policies"
+	RuleSCP          = "service-control-policies"
+	RuleResource     = "resource-policies"
+	RuleIdentity     = "identity-policies"
+	RuleBoundary     = "permissions-boundaries"
+	RuleSession      = "session-policies"
+)
+
+// EvaluateAws decides a request the way AWS combines the policies that
+// apply to it, each tagged with its Kind:
+//
+//  1. an explicit deny in any policy denies;
+//  2. resource control policies, then service control policies, if any,
+//     must allow;
+//  3. within an account a resource policy allowing the request is enough,
+//     and if it names the exact principal, e.g. the user or the role
+//     session, no boundary or session policy limits it;
+//  4. otherwise an identity policy must allow, and across accounts a
+//     resource policy must allow as well;
+//  5. permissions boundaries, then session policies, if any, must allow.
+//
+// Trust policies count as the resource policy of the role. Identity,
+// boundary and session policies attached to identities, e.g. those of an
+// authorization details dump, only apply to the principal they are attached
+// to, and trust policies to the role they belong to.
+//
+// The request is across accounts when both accounts are known and differ.
+// When one is not, which the account of an S3 arn never is, it is only
+// taken as across accounts if a resource policy is about the action and
+// resource: set ResourceAccount for those.
+func EvaluateAws(policies []*policy.Policy, request Request) *Decision {
+	return evaluateAws(policies, policies, request)
+}
+
+// evaluateAws evaluates the statements of policies that may apply to the
+// request, given all the policies present: those of a limiting kind that
+// apply to the principal must allow the request even if none of their
+// statements is about it.
+func evaluateAws(policies, present []*policy.Policy, request Request) *Decision {
+	request = request.normalize()
+	e := &awsEvaluation{
+		evaluation: &evaluation{
+			request:  request,
+			decision: &Decision{Steps: []*Step{}},
+		},
+		byKind: map[string][]*policy.Policy{},
+		kinds:  kindsOf(scoped(present, request)),
+	}
+	policies = scoped(policies, request)
+	for _, p := range policies {
+		kind := awsKind(p)
+		e.byKind[kind] = append(e.byKind[kind], p)
+	}
+	return e.evaluate(policies)
+}
+
+// scoped returns the policies that apply to the principal and resource of
+// request, see appliesTo.
+func scoped(policies []*policy.Policy, request Request) []*policy.Policy {
+	x := []*policy.Policy{}
+	for _, p := range policies {
+		if appliesTo(p, request) {
+			x = append(x, p)
+		}
+	}
+	return x
+}
+
+// appliesTo reports whether p applies to the request given where it is
+// attached. Policies attached to no identity apply to any principal. As
+// group membership is not known, the policies of groups only apply with
+// their deny statements.
+func appliesTo(p *policy.Policy, request Request) bool {
+	if p.Origin == nil || len(p.Origin.AttachedTo) == 0 {
+		return true
+	}
+	switch p.Kind {
+	case "", policy.KindIdentity, policy.KindBoundary, policy.KindSession:
+		for _, a := range p.Origin.AttachedTo {
+			if a.Type == attachmentGroup && !p.Allowed || request.attachedTo(a) {
+				return true
+			}
+		}
+		return false
+	case policy.KindTrust:
+		if request.Resource == "" {
+			return true
+		}
+		for _, a := range p.Origin.AttachedTo {
+			if a.Arn == "" || a.Arn == request.Resource {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// representatives returns one policy of each kind, effect and origin of
+// policies: as appliesTo only depends on those, the kinds of the
+// representatives that apply to a request are those of all the policies.
+func representatives(policies []*policy.Policy) []*policy.Policy {
+	x := []*policy.Policy{}
+	seen := map[string]bool{}
+	for _, p := range policies {
+		key := fmt.Sprintf("%s|%t|%p", p.Kind, p.Allowed, p.Origin)
+		if !seen[key] {
+			seen[key] = true
+			x = append(x, p)
+		}
+	}
+	return x
+}
+
+// awsKind returns the kind p is evaluated as: identity when unknown, and
+// trust policies as the resource policies of roles.
+func awsKind(p *policy.Policy) string {
+	switch p.Kind {
+	case "":
+		return policy.KindIdentity
+	case policy.KindTrust:
+		return policy.KindResource
+	}
+	return p.Kind
+}
+
+func kindsOf(policies []*policy.Policy) map[string]bool {
+	kinds := map[string]bool{}
+	for _, p := range policies {
+		kinds[awsKind(p)] = true
+	}
+	return kinds
+}
+
+type awsEvaluation struct {
+	*evaluation
+	byKind map[string][]*policy.Policy
+	kinds  map[string]bool
+}
+
+func (e *awsEvaluation) evaluate(policies []*policy.Policy) *Decision {
+	if e.applying(RuleExplicitDeny, policies, false) {
+		return e.decide(ExplicitDeny, "explicitly denied by "+e.last().Statements[0])
+	}
+
+	for _, limit := range []struct {
+		rule, kind, reason string
+	}{
+		{RuleRCP, policy.KindRCP, "no resource control policy allows the request"},
+		{RuleSCP, policy.KindSCP, "no service control policy allows the request"},
+	} {
+		if !e.requiredAllow(limit.rule, limit.kind) {
+			return e.decide(ImplicitDeny, limit.reason)
+		}
+	}
+
+	resourceAllows := e.allowed(RuleResource, policy.KindResource)
+	resourceStep := e.last()
+	about := e.resourcePoliciesAbout()
+	resourceAccount := e.request.ResourceAccount
+	for _, p := range about {
+		if resourceAccount == "" && p.Origin != nil {
+			resourceAccount = p.Origin.Account
+		}
+	}
+	sameAccount := e.request.PrincipalAccount != "" && e.request.PrincipalAccount == resourceAccount
+	crossAccount := !sameAccount &&
+		(len(about) > 0 || e.request.PrincipalAccount != "" && resourceAccount != "")
+	if resourceAllows && sameAccount {
+		if e.namesPrincipal(resourceStep.Statements) {
+			return e.decide(Allow, "allowed by a resource policy naming the principal")
+		}
+		if reason, ok := e.limited(); !ok {
+			return e.decide(ImplicitDeny, reason)
+		}
+		return e.decide(Allow, "allowed by a resource policy in the account of the principal")
+	}
+
+	if !e.allowed(RuleIdentity, policy.KindIdentity) {
+		if crossAccount && resourceAllows {
+			return e.decide(ImplicitDeny, "cross-account request allowed by a resource policy but by no identity policy")
+		}
+		return e.decide(ImplicitDeny, "no identity policy allows the request")
+	}
+	if crossAccount && !resourceAllows {
+		return e.decide(ImplicitDeny, "cross-account request allowed by an identity policy but by no resource policy")
+	}
+
+	if reason, ok := e.limited(); !ok {
+		return e.decide(ImplicitDeny, reason)
+	}
+
+	if crossAccount {
+		return e.decide(Allow, "allowed by identity and resource policies across accounts")
+	}
+	return e.decide(Allow, "allowed by an identity policy")
+}
+
+// limited checks the permissions boundaries and session policies, which
+// must allow the request when there are any, and returns why they do not.
+func (e *awsEvaluation) limited() (string, bool) {
+	for _, limit := range []struct {
+		rule, kind, reason string
+	}{
+		{RuleBoundary, policy.KindBoundary, "no permissions boundary allows the request"},
+		{RuleSession, policy.KindSession, "no session policy allows the request"},
+	} {
+		if !e.requiredAllow(limit.rule, limit.kind) {
+			return limit.reason, false
+		}
+	}
+	return "", true
+}
+
+// resourcePoliciesAbout returns the resource policies with statements
+// about the action and resource of the request, whatever their principals
+// and conditions.
+func (e *awsEvaluation) resourcePoliciesAbout() []*policy.Policy {
+	x := []*policy.Policy{}
+	for _, p := range e.byKind[policy.KindResource] {
+		if p.MatchesAction(e.request.Action) && p.MatchesResource(e.request.Resource) {
+			x = append(x, p)
+		}
+	}
+	return x
+}
+
+// namesPrincipal reports whether one of the resource policy statements ids
+// names the principal of the request literally, rather than its role, its
+// account or a wildcard.
+func (e *awsEvaluation) namesPrincipal(ids []string) bool {
+	for _, p := range e.byKind[policy.KindResource] {
+		if !contains(ids, p.Id) {
+			continue
+		}
+		for _, subject := range p.Globs(policy.FieldSubjects) {
+			if subject == e.request.Principal && !strings.ContainsAny(subject, "*?") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(x []string, s string) bool {
+	for _, y := range x {
+		if y == s {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredAllow checks the policies of a kind that must allow the request
+// when there are any.
+func (e *awsEvaluation) requiredAllow(rule, kind string) bool {
+	if !e.kinds[kind] {
+		e.skip(rule)
+		return true
+	}
+	return e.applying(rule, e.byKind[kind], true)
+}
+
+// allowed reports whether an Allow statement of the given kind applies.
+func (e *awsEvaluation) allowed(rule, kind string) bool {
+	if !e.kinds[kind] {
+		e.skip(rule)
+		return false
+	}
+	return e.applying(rule, e.byKind[kind], true)
+}
@@ -0,0 +1,251 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func withPrincipals(p *policy.Policy, principals ...string) *policy.Policy {
+	p.Subjects, p.Patterns.Subjects = policy.NewPatterns(principals, false)
+	return p
+}
+
+func rules(d *Decision) []string {
+	x := []string{}
+	for _, s := range d.Steps {
+		x = append(x, s.Rule+":"+s.Result)
+	}
+	return x
+}
+
+const (
+	alice  = "arn:aws:iam::111111111111:user/alice"
+	bucket = "arn:aws:s3:::data/report.csv"
+)
+
+func TestEvaluateAws_Identity(t *testing.T) {
+	identity := policy.NewStatement("id:0", policy.KindIdentity, true, nil, []string{"s3:Get*"}, []string{"arn:aws:s3:::data/*"})
+	request := Request{Principal: alice, Action: "s3:GetObject", Resource: bucket}
+
+	d := EvaluateAws([]*policy.Policy{identity}, request)
+	assert.True(t, d.Allowed)
+	assert.EqualValues(t, Allow, d.Effect)
+	assert.EqualValues(t, []string{
+		"explicit-deny:no-match",
+		"resource-control-policies:not-applicable",
+		"service-control-policies:not-applicable",
+		"resource-policies:not-applicable",
+		"identity-policies:allow",
+		"permissions-boundaries:not-applicable",
+		"session-policies:not-applicable",
+	}, rules(d))
+	assert.EqualValues(t, []string{"id:0"}, d.Steps[4].Statements)
+
+	request.Action = "s3:PutObject"
+	d = EvaluateAws([]*policy.Policy{identity}, request)
+	assert.False(t, d.Allowed)
+	assert.EqualValues(t, ImplicitDeny, d.Effect)
+	assert.EqualValues(t, "no identity policy allows the request", d.Reason)
+}
+
+func TestEvaluateAws_ExplicitDeny(t *testing.T) {
+	policies := []*policy.Policy{
+		policy.NewStatement("id:0", policy.KindIdentity, true, nil, []string{"s3:*"}, []string{"*"}),
+		withPrincipals(policy.NewStatement("bucket:0", policy.KindResource, false, nil, []string{"s3:*"}, []string{"arn:aws:s3:::data/*"}), "*"),
+	}
+	d := EvaluateAws(policies, Request{Principal: alice, Action: "s3:GetObject", Resource: bucket})
+	assert.EqualValues(t, ExplicitDeny, d.Effect)
+	assert.EqualValues(t, []string{"bucket:0"}, d.Steps[0].Statements)
+	assert.Len(t, d.Steps, 1)
+}
+
+func TestEvaluateAws_Limits(t *testing.T) {
+	identity := policy.NewStatement("id:0", policy.KindIdentity, true, nil, []string{"*"}, []string{"*"})
+	scp := policy.NewStatement("scp:0", policy.KindSCP, true, nil, []string{"s3:*", "ec2:*"}, []string{"*"})
+	rcp := policy.NewStatement("rcp:0", policy.KindRCP, true, nil, []string{"*"}, []string{"*"})
+	boundary := policy.NewStatement("boundary:0", policy.KindBoundary, true, nil, []string{"s3:*"}, []string{"*"})
+	session := policy.NewStatement("session:0", policy.KindSession, true, nil, []string{"s3:GetObject"}, []string{"*"})
+	policies := []*policy.Policy{identity, scp, rcp, boundary, session}
+
+	tests := []struct {
+		action  string
+		effect  string
+		reason  string
+		allowed bool
+	}{
+		{"s3:GetObject", Allow, "allowed by an identity policy", true},
+		{"s3:PutObject", ImplicitDeny, "no session policy allows the request", false},
+		{"ec2:RunInstances", ImplicitDeny, "no permissions boundary allows the request", false},
+		{"iam:PassRole", ImplicitDeny, "no service control policy allows the request", false},
+	}
+	for _, tt := range tests {
+		d := EvaluateAws(policies, Request{Principal: alice, Action: tt.action, Resource: bucket})
+		assert.EqualValues(t, tt.effect, d.Effect, tt.action)
+		assert.EqualValues(t, tt.reason, d.Reason, tt.action)
+		assert.EqualValues(t, tt.allowed, d.Allowed, tt.action)
+	}
+
+	rcp.Allowed = false
+	d := EvaluateAws(policies, Request{Principal: alice, Action: "s3:GetObject", Resource: bucket})
+	assert.EqualValues(t, ExplicitDeny, d.Effect)
+}
+
+func TestEvaluateAws_ResourcePolicies(t *testing.T) {
+	bob := "arn:aws:iam::222222222222:user/bob"
+	resource := withPrincipals(policy.NewStatement("bucket:0", policy.KindResource, true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::data/*"}),
+		"arn:aws:iam::111111111111:root", "222222222222")
+	resource.Subjects, resource.Patterns.Subjects = policy.NewPatterns(
+		[]string{"arn:aws:iam::111111111111:root", "arn:aws:iam::222222222222:root"}, false)
+	identity := policy.NewStatement("id:0", policy.KindIdentity, true, nil, []string{"s3:GetObject"}, []string{"*"})
+
+	// within the account the resource policy is enough
+	d := EvaluateAws([]*policy.Policy{resource}, Request{Principal: alice, Action: "s3:GetObject", Resource: bucket, ResourceAccount: "111111111111"})
+	assert.EqualValues(t, Allow, d.Effect)
+	assert.EqualValues(t, "allowed by a resource policy in the account of the principal", d.Reason)
+
+	// across accounts both must allow
+	request := Request{Principal: bob, Action: "s3:GetObject", Resource: bucket, ResourceAccount: "111111111111"}
+	d = EvaluateAws([]*policy.Policy{resource}, request)
+	assert.EqualValues(t, ImplicitDeny, d.Effect)
+	assert.EqualValues(t, "cross-account request allowed by a resource policy but by no identity policy", d.Reason)
+
+	d = EvaluateAws([]*policy.Policy{identity}, request)
+	assert.EqualValues(t, ImplicitDeny, d.Effect)
+	assert.EqualValues(t, "cross-account request allowed by an identity policy but by no resource policy", d.Reason)
+
+	d = EvaluateAws([]*policy.Policy{identity, resource}, request)
+	assert.EqualValues(t, Allow, d.Effect)
+	assert.EqualValues(t, "allowed by identity and resource policies across accounts", d.Reason)
+
+	// the account of an S3 arn is unknown, so the request may cross
+	// accounts and the resource policy alone is not enough
+	request = Request{Principal: bob, Action: "s3:GetObject", Resource: bucket}
+	d = EvaluateAws([]*policy.Policy{resource}, request)
+	assert.EqualValues(t, ImplicitDeny, d.Effect)
+	d = EvaluateAws([]*policy.Policy{identity, resource}, request)
+	assert.EqualValues(t, Allow, d.Effect)
+}
+
+func TestEvaluateAws_ResourcePolicyLimits(t *testing.T) {
+	session := "arn:aws:sts::111111111111:assumed-role/reader/alice"
+	role := "arn:aws:iam::111111111111:role/reader"
+	boundary := policy.NewStatement("boundary:0", policy.KindBoundary, true, nil, []string{"s3:ListBucket"}, []string{"*"})
+	request := Request{Principal: session, Action: "s3:GetObject", Resource: bucket, ResourceAccount: "111111111111"}
+
+	// a resource policy naming the role is limited by its boundary
+	resource := withPrincipals(policy.NewStatement("bucket:0", policy.KindResource, true, nil, []string{"s3:GetObject"}, []string{"*"}), role)
+	d := EvaluateAws([]*policy.Policy{resource, boundary}, request)
+	assert.EqualValues(t, ImplicitDeny, d.Effect)
+	assert.EqualValues(t, "no permissions boundary allows the request", d.Reason)
+
+	d = EvaluateAws([]*policy.Policy{resource}, request)
+	assert.EqualValues(t, Allow, d.Effect)
+	assert.EqualValues(t, "allowed by a resource policy in the account of the principal", d.Reason)
+
+	// one naming the session is not
+	resource = withPrincipals(policy.NewStatement("bucket:0", policy.KindResource, true, nil, []string{"s3:GetObject"}, []string{"*"}), session)
+	d = EvaluateAws([]*policy.Policy{resource, boundary}, request)
+	assert.EqualValues(t, Allow, d.Effect)
+	assert.EqualValues(t, "allowed by a resource policy naming the principal", d.Reason)
+}
+
+func TestEvaluateAws_TrustPolicy(t *testing.T) {
+	trust := withPrincipals(policy.NewStatement("ci:0", policy.KindTrust, true, nil, []string{"sts:AssumeRole"}, nil),
+		"arn:aws:iam::111111111111:role/deployer")
+	trust.Condition = []policy.Condition{
+		{Operation: "StringEquals", Key: "sts:ExternalId", Value: policy.StringValues("secret")},
+	}
+	request := Request{
+		Principal: "arn:aws:sts::111111111111:assumed-role/deployer/build-42",
+		Action:    "sts:AssumeRole",
+		Resource:  "arn:aws:iam::111111111111:role/ci",
+		Context:   map[string][]string{"sts:externalid": {"secret"}},
+	}
+	d := EvaluateAws([]*policy.Policy{trust}, request)
+	assert.EqualValues(t, Allow, d.Effect)
+
+	request.Context = nil
+	d = EvaluateAws([]*policy.Policy{trust}, request)
+	assert.EqualValues(t, ImplicitDeny, d.Effect)
+}
+
+func TestEvaluateAws_Variables(t *testing.T) {
+	identity := policy.NewStatement("id:0", policy.KindIdentity, true, nil, []string{"s3:*"}, []string{"arn:aws:s3:::home/${aws:username}/*"})
+	request := Request{
+		Principal: alice,
+		Action:    "s3:PutObject",
+		Resource:  "arn:aws:s3:::home/alice/notes.txt",
+		Context:   map[string][]string{"aws:username": {"alice"}},
+	}
+	assert.True(t, EvaluateAws([]*policy.Policy{identity}, request).Allowed)
+
+	request.Resource = "arn:aws:s3:::home/bob/notes.txt"
+	assert.False(t, EvaluateAws([]*policy.Policy{identity}, request).Allowed)
+
+	request.Context = nil
+	request.Resource = "arn:aws:s3:::home/${aws:username}/notes.txt"
+	assert.False(t, EvaluateAws([]*policy.Policy{identity}, request).Allowed)
+}
+
+func attached(p *policy.Policy, origin *policy.Origin) *policy.Policy {
+	p.Origin = origin
+	return p
+}
+
+func TestEvaluateAws_AuthorizationDetails(t *testing.T) {
+	bob := "arn:aws:iam::111111111111:user/bob"
+	ci := "arn:aws:iam::111111111111:role/ci"
+	aliceOnly := &policy.Origin{Type: policy.OriginManaged, Name: "ReadOnly", AttachedTo: []policy.Attachment{{Type: "user", Name: "alice", Arn: alice}}}
+	bobOnly := &policy.Origin{Type: policy.OriginInline, Name: "admin", AttachedTo: []policy.Attachment{{Type: "user", Name: "bob", Arn: bob}}}
+	admins := &policy.Origin{Type: policy.OriginInline, Name: "admins", AttachedTo: []policy.Attachment{{Type: "group", Name: "admins"}}}
+	trust := &policy.Origin{Type: policy.OriginTrust, Name: "ci", Account: "111111111111", AttachedTo: []policy.Attachment{{Type: "role", Name: "ci", Arn: ci}}}
+
+	// the policies of an authorization details dump, attached to different
+	// identities
+	policies := []*policy.Policy{
+		attached(policy.NewStatement("user/alice/ReadOnly:0", policy.KindIdentity, true, nil, []string{"s3:Get*"}, []string{"*"}), aliceOnly),
+		attached(policy.NewStatement("user/bob/admin:0", policy.KindIdentity, true, nil, []string{"*"}, []string{"*"}), bobOnly),
+		attached(policy.NewStatement("bob-boundary:0", policy.KindBoundary, true, nil, []string{"s3:*", "iam:*"}, []string{"*"}), bobOnly),
+		attached(policy.NewStatement("group/admins/admins:0", policy.KindIdentity, true, nil, []string{"iam:*"}, []string{"*"}), admins),
+		attached(policy.NewStatement("group/admins/admins:1", policy.KindIdentity, false, nil, []string{"s3:DeleteObject"}, []string{"*"}), admins),
+		attached(withPrincipals(policy.NewStatement("ci:0", policy.KindTrust, true, nil, []string{"sts:AssumeRole"}, nil), "ec2.amazonaws.com"), trust),
+	}
+	e := NewEvaluator(policies)
+
+	tests := []struct {
+		principal, action, resource string
+		effect, reason              string
+	}{
+		// the trust policy of a role does not make S3 requests cross accounts
+		{alice, "s3:GetObject", bucket, Allow, "allowed by an identity policy"},
+		// the policies of bob and of groups do not grant alice anything
+		{alice, "ec2:RunInstances", "*", ImplicitDeny, "no identity policy allows the request"},
+		{alice, "iam:CreateUser", "*", ImplicitDeny, "no identity policy allows the request"},
+		// the boundary of bob limits bob only
+		{bob, "ec2:RunInstances", "*", ImplicitDeny, "no permissions boundary allows the request"},
+		{bob, "iam:CreateUser", "*", Allow, "allowed by an identity policy"},
+		// group membership is unknown: the deny of a group applies
+		{bob, "s3:DeleteObject", bucket, ExplicitDeny, "explicitly denied by group/admins/admins:1"},
+		// the trust policy of ci is about ci only
+		{"arn:aws:iam::111111111111:role/web", "sts:AssumeRole", "arn:aws:iam::111111111111:role/other", ImplicitDeny, "no identity policy allows the request"},
+	}
+	for _, tt := range tests {
+		request := Request{Principal: tt.principal, Action: tt.action, Resource: tt.resource}
+		d := EvaluateAws(policies, request)
+		assert.EqualValues(t, tt.effect, d.Effect, tt.principal+" "+tt.action)
+		assert.EqualValues(t, tt.reason, d.Reason, tt.principal+" "+tt.action)
+		indexed := e.EvaluateAws(request)
+		assert.EqualValues(t, rules(d), rules(indexed), tt.principal+" "+tt.action)
+	}
+
+	// an unattached identity policy applies to any principal, and an
+	// unrelated trust policy does not make the request cross accounts
+	d := EvaluateAws([]*policy.Policy{
+		policy.NewStatement("id:0", policy.KindIdentity, true, nil, []string{"*"}, []string{"*"}),
+		policies[5],
+	}, Request{Principal: "arn:aws:iam::111111111111:role/r", Action: "s3:GetObject", Resource: bucket})
+	assert.EqualValues(t, Allow, d.Effect)
+}
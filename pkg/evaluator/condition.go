@@ -0,0 +1,268 @@
+package evaluator
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+const (
+	forAnyValue  = "ForAnyValue:"
+	forAllValues = "ForAllValues:"
+	ifExists     = "IfExists"
+)
+
+// operator is a condition operator taken apart, e.g.
+// ForAllValues:StringNotLikeIfExists.
+type operator struct {
+	name      string // StringNotLike
+	forAny    bool
+	forAll    bool
+	ifExists  bool
+	negated   bool // the operator matches when no value matches
+	supported bool
+}
+
+func parseOperator(op string) operator {
+	o := operator{}
+	switch {
+	case strings.HasPrefix(op, forAnyValue):
+		o.forAny = true
+		op = op[len(forAnyValue):]
+	case strings.HasPrefix(op, forAllValues):
+		o.forAll = true
+		op = op[len(forAllValues):]
+	}
+	if op != "Null" && strings.HasSuffix(op, ifExists) {
+		o.ifExists = true
+		op = strings.TrimSuffix(op, ifExists)
+	}
+	o.name = op
+	_, o.supported = matchers[op]
+	o.negated = strings.Contains(op, "Not")
+	return o
+}
+
+// matchers compare one value of the request context with one value of the
+// condition, for the positive form of each operator.
+var matchers = map[string]func(ctx, value string) bool{
+	"StringEquals":              stringEquals,
+	"StringNotEquals":           stringEquals,
+	"StringEqualsIgnoreCase":    strings.EqualFold,
+	"StringNotEqualsIgnoreCase": strings.EqualFold,
+	"StringLike":                stringLike,
+	"StringNotLike":             stringLike,
+	"NumericEquals":             numeric(func(c int) bool { return c == 0 }),
+	"NumericNotEquals":          numeric(func(c int) bool { return c == 0 }),
+	"NumericLessThan":           numeric(func(c int) bool { return c < 0 }),
+	"NumericLessThanEquals":     numeric(func(c int) bool { return c <= 0 }),
+	"NumericGreaterThan":        numeric(func(c int) bool { return c > 0 }),
+	"NumericGreaterThanEquals":  numeric(func(c int) bool { return c >= 0 }),
+	"DateEquals":                date(func(c int) bool { return c == 0 }),
+	"DateNotEquals":             date(func(c int) bool { return c == 0 }),
+	"DateLessThan":              date(func(c int) bool { return c < 0 }),
+	"DateLessThanEquals":        date(func(c int) bool { return c <= 0 }),
+	"DateGreaterThan":           date(func(c int) bool { return c > 0 }),
+	"DateGreaterThanEquals":     date(func(c int) bool { return c >= 0 }),
+	"Bool":                      strings.EqualFold,
+	"BinaryEquals":              stringEquals,
+	"IpAddress":                 ipAddress,
+	"NotIpAddress":              ipAddress,
+	"ArnEquals":                 stringLike,
+	"ArnNotEquals":              stringLike,
+	"ArnLike":                   stringLike,
+	"ArnNotLike":                stringLike,
+	"Null":                      nil,
+}
+
+// ConditionTrace is the evaluation of one condition of a statement.
+type ConditionTrace struct {
+	Operator string   `json:"operator" yaml:"operator"`
+	Key      string   `json:"key" yaml:"key"`
+	Values   []string `json:"values" yaml:"values"`
+	// Context holds the values of the key in the request.
+	Context []string `json:"context,omitempty" yaml:"context,omitempty"`
+	Passed  bool     `json:"passed" yaml:"passed"`
+	Reason  string   `json:"reason" yaml:"reason"`
+}
+
+// evaluateCondition reports whether c holds for the request context, and
+// why.
+func evaluateCondition(c policy.Condition, context map[string][]string) *ConditionTrace {
+	values := c.Value.Text()
+	ctx, present := lookup(context, c.Key)
+	t := &ConditionTrace{
+		Operator: c.Operation,
+		Key:      c.Key,
+		Values:   values,
+		Context:  ctx,
+	}
+	result := func(passed bool, format string, args ...interface{}) *ConditionTrace {
+		t.Passed = passed
+		t.Reason = fmt.Sprintf(format, args...)
+		return t
+	}
+
+	op := parseOperator(c.Operation)
+	if !op.supported {
+		return result(false, "operator %s is not supported", c.Operation)
+	}
+
+	if op.name == "Null" {
+		// "Null": true holds when the key is absent
+		for _, v := range values {
+			if strings.EqualFold(v, "true") == !present {
+				return result(true, "key is %s", presence(present))
+			}
+		}
+		return result(false, "key is %s", presence(present))
+	}
+
+	if !present {
+		switch {
+		case op.ifExists:
+			return result(true, "key is absent and the operator ends with IfExists")
+		case op.forAll:
+			return result(true, "key is absent, ForAllValues holds for an empty set")
+		case op.forAny:
+			return result(false, "key is absent, ForAnyValue needs at least one value")
+		case op.negated:
+			return result(true, "key is absent, a negated operator holds")
+		}
+		return result(false, "key is absent")
+	}
+
+	match := matchers[op.name]
+	matching := func(v string) string {
+		for _, value := range values {
+			if match(v, value) {
+				return value
+			}
+		}
+		return ""
+	}
+	matchesAny := func(v string) bool {
+		for _, value := range values {
+			if match(v, value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if op.forAll {
+		for _, v := range ctx {
+			if matchesAny(v) == op.negated {
+				return result(false, "ForAllValues: %q %s", v, describe(op, matching(v)))
+			}
+		}
+		return result(true, "ForAllValues: every request value holds")
+	}
+	if op.forAny {
+		for _, v := range ctx {
+			if matchesAny(v) != op.negated {
+				return result(true, "ForAnyValue: %q %s", v, describe(op, matching(v)))
+			}
+		}
+		return result(false, "ForAnyValue: no request value holds")
+	}
+
+	// a single valued key; negated operators hold when no value matches
+	for _, v := range ctx {
+		if matchesAny(v) {
+			return result(!op.negated, "%q %s", v, describe(op, matching(v)))
+		}
+	}
+	return result(op.negated, "%q %s", strings.Join(ctx, ", "), describe(op, ""))
+}
+
+func describe(op operator, matched string) string {
+	if matched == "" {
+		return "matches no value of " + op.name
+	}
+	return fmt.Sprintf("matches %q of %s", matched, op.name)
+}
+
+func presence(present bool) string {
+	if present {
+		return "present"
+	}
+	return "absent"
+}
+
+// lookup returns the values of key, which is case-insensitive.
+func lookup(context map[string][]string, key string) ([]string, bool) {
+	if v, ok := context[key]; ok {
+		return v, len(v) > 0
+	}
+	for k, v := range context {
+		if strings.EqualFold(k, key) {
+			return v, len(v) > 0
+		}
+	}
+	return nil, false
+}
+
+func stringEquals(ctx, value string) bool {
+	return ctx == value
+}
+
+func stringLike(ctx, value string) bool {
+	return policy.MatchGlob(value, ctx)
+}
+
+func numeric(cmp func(int) bool) func(ctx, value string) bool {
+	return func(ctx, value string) bool {
+		a, err := strconv.ParseFloat(ctx, 64)
+		if err != nil {
+			return false
+		}
+		b, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		switch {
+		case a < b:
+			return cmp(-1)
+		case a > b:
+			return cmp(1)
+		}
+		return cmp(0)
+	}
+}
+
+func date(cmp func(int) bool) func(ctx, value string) bool {
+	return func(ctx, value string) bool {
+		a, err := policy.ParseDate(ctx)
+		if err != nil {
+			return false
+		}
+		b, err := policy.ParseDate(value)
+		if err != nil {
+			return false
+		}
+		switch {
+		case a.Before(b):
+			return cmp(-1)
+		case a.After(b):
+			return cmp(1)
+		}
+		return cmp(0)
+	}
+}
+
+func ipAddress(ctx, value string) bool {
+	ip := net.ParseIP(ctx)
+	if ip == nil {
+		return false
+	}
+	if !strings.Contains(value, "/") {
+		other := net.ParseIP(value)
+		return other != nil && other.Equal(ip)
+	}
+	_, network, err := net.ParseCIDR(value)
+	return err == nil && network.Contains(ip)
+}
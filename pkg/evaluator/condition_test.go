@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	context := map[string][]string{
+		"aws:SourceIp":          {"10.1.2.3"},
+		"aws:SecureTransport":   {"true"},
+		"s3:max-keys":           {"25"},
+		"aws:CurrentTime":       {"2020-06-01T00:00:00Z"},
+		"aws:PrincipalTag/team": {"Platform"},
+		"aws:TagKeys":           {"team", "cost-center"},
+		"aws:SourceArn":         {"arn:aws:sns:us-east-1:111111111111:alerts"},
+	}
+
+	tests := []struct {
+		operator string
+		key      string
+		values   policy.Values
+		holds    bool
+	}{
+		{"StringEquals", "aws:PrincipalTag/team", policy.StringValues("Platform"), true},
+		{"StringEquals", "aws:principaltag/team", policy.StringValues("platform"), false},
+		{"StringEqualsIgnoreCase", "aws:PrincipalTag/team", policy.StringValues("platform"), true},
+		{"StringNotEquals", "aws:PrincipalTag/team", policy.StringValues("Security", "Audit"), true},
+		{"StringNotEquals", "aws:PrincipalTag/missing", policy.StringValues("Security"), true},
+		{"StringLike", "aws:PrincipalTag/team", policy.StringValues("Plat*"), true},
+		{"StringLike", "aws:PrincipalTag/missing", policy.StringValues("*"), false},
+		{"StringLikeIfExists", "aws:PrincipalTag/missing", policy.StringValues("x"), true},
+		{"NumericLessThan", "s3:max-keys", policy.Int64Values(30), true},
+		{"NumericGreaterThanEquals", "s3:max-keys", policy.Float64Values(25.5), false},
+		{"DateGreaterThan", "aws:CurrentTime", policy.StringValues("2020-01-01"), true},
+		{"DateLessThan", "aws:CurrentTime", policy.StringValues("1577836800"), false},
+		{"Bool", "aws:SecureTransport", policy.BoolValues(true), true},
+		{"Bool", "aws:SecureTransport", policy.BoolValues(false), false},
+		{"IpAddress", "aws:SourceIp", policy.StringValues("10.0.0.0/8"), true},
+		{"NotIpAddress", "aws:SourceIp", policy.StringValues("10.0.0.0/8", "192.168.0.1"), false},
+		{"ArnLike", "aws:SourceArn", policy.StringValues("arn:aws:sns:*:111111111111:*"), true},
+		{"ArnNotEquals", "aws:SourceArn", policy.StringValues("arn:aws:sns:*:222222222222:*"), true},
+		{"Null", "aws:PrincipalTag/missing", policy.BoolValues(true), true},
+		{"Null", "aws:SourceIp", policy.BoolValues(true), false},
+		{"ForAllValues:StringEquals", "aws:TagKeys", policy.StringValues("team", "cost-center", "owner"), true},
+		{"ForAllValues:StringEquals", "aws:TagKeys", policy.StringValues("team"), false},
+		{"ForAllValues:StringEquals", "aws:RequestTag/missing", policy.StringValues("team"), true},
+		{"ForAnyValue:StringEquals", "aws:TagKeys", policy.StringValues("owner", "team"), true},
+		{"ForAnyValue:StringEquals", "aws:RequestTag/missing", policy.StringValues("team"), false},
+		{"ForAnyValue:StringNotLike", "aws:TagKeys", policy.StringValues("team"), true},
+		{"StringMatchesRegex", "aws:TagKeys", policy.StringValues("team"), false},
+	}
+
+	for _, tt := range tests {
+		c := policy.Condition{Operation: tt.operator, Key: tt.key, Value: tt.values}
+		ct := evaluateCondition(c, context)
+		assert.EqualValues(t, tt.holds, ct.Passed, "%s %s %v: %s", tt.operator, tt.key, tt.values.Text(), ct.Reason)
+		assert.NotEmpty(t, ct.Reason)
+	}
+}
+
+func TestEvaluateCondition_Reason(t *testing.T) {
+	context := map[string][]string{"aws:SourceIp": {"192.168.1.1"}}
+
+	ct := evaluateCondition(policy.Condition{
+		Operation: "IpAddress",
+		Key:       "aws:SourceIp",
+		Value:     policy.StringValues("10.0.0.0/8", "172.16.0.0/12"),
+	}, context)
+	assert.False(t, ct.Passed)
+	assert.EqualValues(t, []string{"192.168.1.1"}, ct.Context)
+	assert.EqualValues(t, `"192.168.1.1" matches no value of IpAddress`, ct.Reason)
+
+	ct = evaluateCondition(policy.Condition{
+		Operation: "StringEquals",
+		Key:       "aws:PrincipalTag/team",
+		Value:     policy.StringValues("platform"),
+	}, context)
+	assert.False(t, ct.Passed)
+	assert.EqualValues(t, "key is absent", ct.Reason)
+}
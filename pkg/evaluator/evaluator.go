@@ -0,0 +1,278 @@
+package evaluator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Effects of a decision.
+const (
+	Allow        = "Allow"
+	ExplicitDeny = "ExplicitDeny"
+	ImplicitDeny = "ImplicitDeny"
+)
+
+var (
+	assumedRolePattern = regexp.MustCompile(`^arn:([^:]+):sts::([0-9]{12}):assumed-role/([^/]+)/.+$`)
+	identityPattern    = regexp.MustCompile(`^arn:[^:]+:iam::[0-9]{12}:(user|role)/(.+)$`)
+)
+
+// Types of the identities policies are attached to, see policy.Attachment.
+const (
+	attachmentUser  = "user"
+	attachmentRole  = "role"
+	attachmentGroup = "group"
+)
+
+// Request is an authorization request. The accounts default to the account
+// field of the principal and resource arns.
+type Request struct {
+	Principal        string `json:"principal,omitempty" yaml:"principal,omitempty"`
+	PrincipalAccount string `json:"principal-account,omitempty" yaml:"principal-account,omitempty"`
+	Action           string `json:"action" yaml:"action"`
+	Resource         string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	ResourceAccount  string `json:"resource-account,omitempty" yaml:"resource-account,omitempty"`
+	// Context holds the condition keys of the request, e.g.
+	// aws:SourceIp. Keys are case-insensitive.
+	Context map[string][]string `json:"context,omitempty" yaml:"context,omitempty"`
+}
+
+// Decision is the result of evaluating a request, with the steps that led
+// to it.
+type Decision struct {
+	Effect  string `json:"effect" yaml:"effect"`
+	Allowed bool   `json:"allowed" yaml:"allowed"`
+	// Reason is the rule that decided, in words.
+	Reason string  `json:"reason" yaml:"reason"`
+	Steps  []*Step `json:"steps" yaml:"steps"`
+}
+
+// Step is the evaluation of one rule, e.g. that no service control policy
+// allows the request.
+type Step struct {
+	Rule   string `json:"rule" yaml:"rule"`
+	Result string `json:"result" yaml:"result"`
+	// Statements are the statements that produced the result.
+	Statements []string `json:"statements,omitempty" yaml:"statements,omitempty"`
+	// Considered are all the statements evaluated for the rule.
+	Considered []*StatementTrace `json:"considered,omitempty" yaml:"considered,omitempty"`
+}
+
+// Step results.
+const (
+	ResultAllow         = "allow"
+	ResultDeny          = "deny"
+	ResultNoMatch       = "no-match"
+	ResultNotApplicable = "not-applicable"
+)
+
+// normalize fills in the accounts and the global condition keys derived
+// from the request.
+func (r Request) normalize() Request {
+	if r.PrincipalAccount == "" {
+		r.PrincipalAccount = accountOf(r.Principal)
+	}
+	if r.ResourceAccount == "" {
+		r.ResourceAccount = accountOf(r.Resource)
+	}
+	ctx := map[string][]string{}
+	for k, v := range r.Context {
+		ctx[k] = v
+	}
+	setDefault := func(key, value string) {
+		if _, ok := lookup(ctx, key); !ok && value != "" {
+			ctx[key] = []string{value}
+		}
+	}
+	setDefault("aws:PrincipalArn", r.Principal)
+	setDefault("aws:PrincipalAccount", r.PrincipalAccount)
+	setDefault("aws:ResourceAccount", r.ResourceAccount)
+	r.Context = ctx
+	return r
+}
+
+// variables returns the single valued context keys, for policy variables.
+func (r Request) variables() map[string]string {
+	vars := map[string]string{}
+	for k, v := range r.Context {
+		if len(v) == 1 {
+			vars[k] = v[0]
+		}
+	}
+	return vars
+}
+
+// principals returns the names a policy can give the principal of r: its
+// arn, the role of an assumed-role session and the root of its account.
+func (r Request) principals() []string {
+	x := []string{r.Principal}
+	if m := assumedRolePattern.FindStringSubmatch(r.Principal); m != nil {
+		x = append(x, fmt.Sprintf("arn:%s:iam::%s:role/%s", m[1], m[2], m[3]))
+	}
+	if r.PrincipalAccount != "" {
+		x = append(x, fmt.Sprintf("arn:aws:iam::%s:root", r.PrincipalAccount))
+	}
+	return x
+}
+
+// identity returns the type, user or role, and the name of the IAM
+// identity the principal of r is, e.g. the role of an assumed-role session.
+func (r Request) identity() (string, string) {
+	if m := assumedRolePattern.FindStringSubmatch(r.Principal); m != nil {
+		return attachmentRole, m[3]
+	}
+	if m := identityPattern.FindStringSubmatch(r.Principal); m != nil {
+		return m[1], m[2][strings.LastIndex(m[2], "/")+1:]
+	}
+	return "", ""
+}
+
+// attachedTo reports whether a is the identity of the principal of r.
+func (r Request) attachedTo(a policy.Attachment) bool {
+	kind, name := r.identity()
+	if kind == "" || a.Type != kind {
+		return false
+	}
+	if a.Arn != "" {
+		if account := accountOf(a.Arn); account != "" && r.PrincipalAccount != "" && account != r.PrincipalAccount {
+			return false
+		}
+		if a.Name == "" {
+			return a.Arn[strings.LastIndex(a.Arn, "/")+1:] == name
+		}
+	}
+	return a.Name == name
+}
+
+// StatementTrace is the evaluation of one statement against a request.
+type StatementTrace struct {
+	Id        string `json:"id" yaml:"id"`
+	Sid       string `json:"sid,omitempty" yaml:"sid,omitempty"`
+	Kind      string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Effect    string `json:"effect" yaml:"effect"`
+	Principal bool   `json:"principal" yaml:"principal"`
+	Action    bool   `json:"action" yaml:"action"`
+	Resource  bool   `json:"resource" yaml:"resource"`
+	// Unresolved are the policy variables the request has no value for,
+	// which keep the statement from applying.
+	Unresolved []string          `json:"unresolved,omitempty" yaml:"unresolved,omitempty"`
+	Conditions []*ConditionTrace `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Applies    bool              `json:"applies" yaml:"applies"`
+}
+
+// traceStatement evaluates statement p against the request. The statement
+// applies when it names the principal, action and resource and all its
+// conditions hold. A statement with a policy variable the request has no
+// value for does not apply.
+func traceStatement(p *policy.Policy, r Request) *StatementTrace {
+	t := &StatementTrace{
+		Id:         p.Id,
+		Sid:        p.Sid,
+		Kind:       p.Kind,
+		Effect:     "Deny",
+		Conditions: []*ConditionTrace{},
+	}
+	if p.Allowed {
+		t.Effect = "Allow"
+	}
+
+	resolved, err := p.Resolve(r.variables())
+	if u, ok := err.(*policy.UnresolvedError); ok {
+		t.Unresolved = u.Names
+	}
+
+	t.Action = resolved.MatchesAction(r.Action)
+	t.Resource = resolved.MatchesResource(r.Resource)
+	for _, candidate := range r.principals() {
+		if resolved.MatchesPrincipal(candidate) {
+			t.Principal = true
+			break
+		}
+	}
+	conditions := true
+	for _, c := range resolved.Condition {
+		ct := evaluateCondition(c, r.Context)
+		t.Conditions = append(t.Conditions, ct)
+		conditions = conditions && ct.Passed
+	}
+
+	t.Applies = err == nil && t.Principal && t.Action && t.Resource && conditions
+	return t
+}
+
+// Rule of the evaluation of a single set of policies.
+const RuleAllow = "allow"
+
+// Evaluate decides a request against policies taken as a single set,
+// whatever their kind: an explicit deny wins over an allow, and without an
+// allow the request is implicitly denied.
+func Evaluate(policies []*policy.Policy, request Request) *Decision {
+	e := &evaluation{
+		request:  request.normalize(),
+		decision: &Decision{Steps: []*Step{}},
+	}
+	if e.applying(RuleExplicitDeny, policies, false) {
+		return e.decide(ExplicitDeny, "explicitly denied by "+e.last().Statements[0])
+	}
+	if e.applying(RuleAllow, policies, true) {
+		return e.decide(Allow, "allowed by "+e.last().Statements[0])
+	}
+	return e.decide(ImplicitDeny, "no statement allows the request")
+}
+
+// evaluation records the steps of a decision.
+type evaluation struct {
+	request  Request
+	decision *Decision
+}
+
+// applying evaluates the statements of policies with the given effect and
+// records the step of rule. It reports whether any applies.
+func (e *evaluation) applying(rule string, policies []*policy.Policy, allowed bool) bool {
+	step := &Step{Rule: rule, Result: ResultNoMatch, Considered: []*StatementTrace{}}
+	for _, p := range policies {
+		if p.Allowed != allowed {
+			continue
+		}
+		t := traceStatement(p, e.request)
+		step.Considered = append(step.Considered, t)
+		if t.Applies {
+			step.Statements = append(step.Statements, p.Id)
+		}
+	}
+	if len(step.Statements) > 0 {
+		step.Result = ResultAllow
+		if !allowed {
+			step.Result = ResultDeny
+		}
+	}
+	e.decision.Steps = append(e.decision.Steps, step)
+	return len(step.Statements) > 0
+}
+
+func (e *evaluation) skip(rule string) {
+	e.decision.Steps = append(e.decision.Steps, &Step{Rule: rule, Result: ResultNotApplicable})
+}
+
+func (e *evaluation) last() *Step {
+	return e.decision.Steps[len(e.decision.Steps)-1]
+}
+
+func (e *evaluation) decide(effect, reason string) *Decision {
+	e.decision.Effect = effect
+	e.decision.Allowed = effect == Allow
+	e.decision.Reason = reason
+	return e.decision
+}
+
+// accountOf returns the account field of arn.
+func accountOf(arn string) string {
+	fields := strings.SplitN(arn, ":", 6)
+	if len(fields) < 6 || fields[0] != "arn" {
+		return ""
+	}
+	return fields[4]
+}
@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func TestEvaluate_Trace(t *testing.T) {
+	allow := policy.NewStatement("p:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::data/*"})
+	allow.Sid = "Read"
+	allow.Condition = []policy.Condition{
+		{Operation: "Bool", Key: "aws:SecureTransport", Value: policy.BoolValues(true)},
+	}
+	deny := policy.NewStatement("p:1", "", false, nil, []string{"s3:*"}, []string{"arn:aws:s3:::data/secret/*"})
+	other := policy.NewStatement("p:2", "", true, nil, []string{"s3:PutObject"}, []string{"*"})
+	policies := []*policy.Policy{allow, deny, other}
+
+	request := Request{Principal: alice, Action: "s3:GetObject", Resource: bucket}
+	d := Evaluate(policies, request)
+	assert.EqualValues(t, ImplicitDeny, d.Effect)
+	assert.EqualValues(t, "no statement allows the request", d.Reason)
+	assert.Len(t, d.Steps, 2)
+	if len(d.Steps) != 2 {
+		t.FailNow()
+	}
+
+	assert.EqualValues(t, RuleExplicitDeny, d.Steps[0].Rule)
+	assert.Len(t, d.Steps[0].Considered, 1)
+	st := d.Steps[0].Considered[0]
+	assert.EqualValues(t, "p:1", st.Id)
+	assert.True(t, st.Principal)
+	assert.True(t, st.Action)
+	assert.False(t, st.Resource)
+	assert.False(t, st.Applies)
+
+	assert.EqualValues(t, RuleAllow, d.Steps[1].Rule)
+	assert.Len(t, d.Steps[1].Considered, 2)
+	st = d.Steps[1].Considered[0]
+	assert.EqualValues(t, "Read", st.Sid)
+	assert.EqualValues(t, "Allow", st.Effect)
+	assert.True(t, st.Action && st.Resource && st.Principal)
+	assert.Len(t, st.Conditions, 1)
+	assert.False(t, st.Conditions[0].Passed)
+	assert.EqualValues(t, "key is absent", st.Conditions[0].Reason)
+	assert.False(t, d.Steps[1].Considered[1].Action)
+
+	request.Context = map[string][]string{"aws:SecureTransport": {"true"}}
+	d = Evaluate(policies, request)
+	assert.EqualValues(t, Allow, d.Effect)
+	assert.EqualValues(t, "allowed by p:0", d.Reason)
+	assert.EqualValues(t, []string{"p:0"}, d.Steps[1].Statements)
+
+	request.Resource = "arn:aws:s3:::data/secret/key"
+	d = Evaluate(policies, request)
+	assert.EqualValues(t, ExplicitDeny, d.Effect)
+	assert.EqualValues(t, "explicitly denied by p:1", d.Reason)
+
+	vars := policy.NewStatement("p:3", "", true, nil, []string{"s3:*"}, []string{"arn:aws:s3:::home/${aws:username}/*"})
+	d = Evaluate([]*policy.Policy{vars}, Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::home/alice/x"})
+	assert.EqualValues(t, []string{"aws:username"}, d.Steps[1].Considered[0].Unresolved)
+}
+
+func TestEvaluator(t *testing.T) {
+	policies := []*policy.Policy{
+		policy.NewStatement("id:0", policy.KindIdentity, true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::data/*"}),
+		policy.NewStatement("id:1", policy.KindIdentity, true, nil, []string{"ec2:*"}, []string{"*"}),
+		policy.NewStatement("id:2", policy.KindIdentity, false, nil, []string{"s3:*"}, []string{"arn:aws:s3:::data/secret/*"}),
+		policy.NewStatement("scp:0", policy.KindSCP, true, nil, []string{"ec2:*"}, []string{"*"}),
+	}
+	e := NewEvaluator(policies)
+
+	for _, request := range []Request{
+		{Principal: alice, Action: "s3:GetObject", Resource: bucket},
+		{Principal: alice, Action: "s3:GetObject", Resource: "arn:aws:s3:::data/secret/key"},
+		{Principal: alice, Action: "ec2:RunInstances", Resource: "arn:aws:ec2:us-east-1:111111111111:instance/*"},
+	} {
+		want := EvaluateAws(policies, request)
+		got := e.EvaluateAws(request)
+		assert.EqualValues(t, want.Effect, got.Effect, request.Resource)
+		assert.EqualValues(t, want.Reason, got.Reason, request.Resource)
+		assert.EqualValues(t, rules(want), rules(got), request.Resource)
+	}
+
+	// the scp limits s3 even though none of its statements is about s3
+	d := e.EvaluateAws(Request{Principal: alice, Action: "s3:GetObject", Resource: bucket})
+	assert.EqualValues(t, "no service control policy allows the request", d.Reason)
+
+	// the deny of another prefix is not even considered
+	assert.Empty(t, d.Steps[0].Considered)
+	assert.Len(t, EvaluateAws(policies, Request{Principal: alice, Action: "s3:GetObject", Resource: bucket}).Steps[0].Considered, 1)
+}
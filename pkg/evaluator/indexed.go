@@ -0,0 +1,34 @@
+package evaluator
+
+import (
+	"github.com/aumahesh/policyparser/pkg/index"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Evaluator decides requests against policies indexed once, so that each
+// request only evaluates the statements that may apply to it. Decisions are
+// those of Evaluate and EvaluateAws, with only those statements in their
+// traces.
+type Evaluator struct {
+	index   *index.Index
+	present []*policy.Policy
+}
+
+// NewEvaluator indexes policies for evaluation.
+func NewEvaluator(policies []*policy.Policy) *Evaluator {
+	return &Evaluator{index: index.New(policies), present: representatives(policies)}
+}
+
+// Evaluate decides request as Evaluate does.
+func (e *Evaluator) Evaluate(request Request) *Decision {
+	return Evaluate(e.candidates(request), request)
+}
+
+// EvaluateAws decides request as EvaluateAws does.
+func (e *Evaluator) EvaluateAws(request Request) *Decision {
+	return evaluateAws(e.candidates(request), e.present, request)
+}
+
+func (e *Evaluator) candidates(request Request) []*policy.Policy {
+	return e.index.Lookup(index.Query{Action: request.Action, Resource: request.Resource})
+}
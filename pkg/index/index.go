@@ -0,0 +1,206 @@
+package index
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Index is an inverted index of statements by the literal prefixes of their
+// action, resource and principal globs, e.g. "s3:get" for s3:Get*. A
+// lookup walks the prefixes of the queried value instead of scanning every
+// statement, and returns the statements that may match it; callers still
+// check each one, e.g. with Policy.Matches.
+type Index struct {
+	policies   []*policy.Policy
+	actions    *field
+	resources  *field
+	principals *field
+}
+
+// Query selects statements by the values they may match. Empty fields
+// select any statement. A value may be a glob, e.g. arn:aws:s3:::data/*,
+// selecting the statements that may match some value it matches.
+type Query struct {
+	Principal string
+	Action    string
+	Resource  string
+}
+
+// New indexes the statements of policies.
+func New(policies []*policy.Policy) *Index {
+	x := &Index{
+		policies:   policies,
+		actions:    newField(true),
+		resources:  newField(false),
+		principals: newField(false),
+	}
+	for i, p := range policies {
+		x.actions.add(i, p.Globs(policy.FieldActions))
+		x.resources.add(i, p.Globs(policy.FieldResources))
+		x.principals.add(i, p.Globs(policy.FieldSubjects))
+	}
+	return x
+}
+
+// Policies returns the indexed statements.
+func (x *Index) Policies() []*policy.Policy {
+	return x.policies
+}
+
+// Lookup returns the statements that may match q, in the order they were
+// indexed. Statements with a NotAction, NotResource or NotPrincipal, or
+// without the field, may match any value of it.
+func (x *Index) Lookup(q Query) []*policy.Policy {
+	var ids []int
+	for _, f := range []struct {
+		field *field
+		value string
+	}{
+		{x.actions, q.Action},
+		{x.resources, q.Resource},
+		{x.principals, q.Principal},
+	} {
+		if f.value == "" {
+			continue
+		}
+		found := f.field.lookup(f.value)
+		if ids == nil {
+			ids = found
+		} else {
+			ids = intersect(ids, found)
+		}
+	}
+
+	if ids == nil {
+		return append([]*policy.Policy{}, x.policies...)
+	}
+	found := make([]*policy.Policy, 0, len(ids))
+	for _, i := range ids {
+		found = append(found, x.policies[i])
+	}
+	return found
+}
+
+// field indexes the globs of one statement field in a trie of their
+// literal prefixes.
+type field struct {
+	root *node
+	// any are the statements that may match any value of the field.
+	any      []int
+	foldCase bool
+}
+
+type node struct {
+	children map[byte]*node
+	// ids are the statements with a glob whose literal prefix ends here.
+	ids []int
+}
+
+func newField(foldCase bool) *field {
+	return &field{root: &node{}, any: []int{}, foldCase: foldCase}
+}
+
+func (f *field) add(id int, globs []string) {
+	if len(globs) == 0 {
+		f.any = append(f.any, id)
+		return
+	}
+	for _, g := range globs {
+		n := f.root
+		for _, c := range []byte(literalPrefix(f.fold(g))) {
+			child, ok := n.children[c]
+			if !ok {
+				if n.children == nil {
+					n.children = map[byte]*node{}
+				}
+				child = &node{}
+				n.children[c] = child
+			}
+			n = child
+		}
+		if len(n.ids) == 0 || n.ids[len(n.ids)-1] != id {
+			n.ids = append(n.ids, id)
+		}
+	}
+}
+
+// lookup returns the statements with a glob whose literal prefix is a
+// prefix of value, or, for a glob value, that extends its literal prefix,
+// sorted.
+func (f *field) lookup(value string) []int {
+	value = f.fold(value)
+	prefix := literalPrefix(value)
+
+	ids := append([]int{}, f.any...)
+	n := f.root
+	ids = append(ids, n.ids...)
+	for i := 0; i < len(prefix) && n != nil; i++ {
+		n = n.children[prefix[i]]
+		if n != nil {
+			ids = append(ids, n.ids...)
+		}
+	}
+	if n != nil && prefix != value {
+		for _, child := range n.children {
+			ids = child.collect(ids)
+		}
+	}
+	return unique(ids)
+}
+
+func (n *node) collect(ids []int) []int {
+	ids = append(ids, n.ids...)
+	for _, child := range n.children {
+		ids = child.collect(ids)
+	}
+	return ids
+}
+
+func (f *field) fold(s string) string {
+	if f.foldCase {
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// literalPrefix returns glob up to its first wildcard or policy variable.
+func literalPrefix(glob string) string {
+	end := len(glob)
+	if i := strings.IndexAny(glob, "*?"); i >= 0 {
+		end = i
+	}
+	if i := strings.Index(glob, "${"); i >= 0 && i < end {
+		end = i
+	}
+	return glob[:end]
+}
+
+func unique(ids []int) []int {
+	sort.Ints(ids)
+	x := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			x = append(x, id)
+		}
+	}
+	return x
+}
+
+func intersect(a, b []int) []int {
+	x := []int{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			x = append(x, a[i])
+			i++
+			j++
+		}
+	}
+	return x
+}
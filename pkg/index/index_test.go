@@ -0,0 +1,140 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func ids(policies []*policy.Policy) []string {
+	x := []string{}
+	for _, p := range policies {
+		x = append(x, p.Id)
+	}
+	return x
+}
+
+func TestIndex_Lookup(t *testing.T) {
+	notAction := policy.NewStatement("p:5", "", true, nil, nil, []string{"*"})
+	notAction.NotActions, notAction.Patterns.NotActions = policy.NewPatterns([]string{"iam:*"}, true)
+
+	x := New([]*policy.Policy{
+		policy.NewStatement("p:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::data/*"}),
+		policy.NewStatement("p:1", "", true, nil, []string{"EC2:*", "s3:Put*"}, []string{"*"}),
+		policy.NewStatement("p:2", "", true, nil, []string{"*"}, []string{"arn:aws:s3:::logs/${aws:username}/*"}),
+		policy.NewStatement("p:3", "", true, []string{"arn:aws:iam::111111111111:root"}, []string{"s3:Get*"}, []string{"arn:aws:s3:::data/shared/*"}),
+		policy.NewStatement("p:4", "", true, nil, []string{"s?:List*"}, []string{"arn:aws:s3:::data"}),
+		notAction,
+	})
+
+	tests := []struct {
+		query Query
+		found []string
+	}{
+		{Query{Action: "ec2:RunInstances"}, []string{"p:1", "p:2", "p:5"}},
+		// p:4 may match as far as its literal prefixes tell
+		{Query{Action: "S3:GetObject"}, []string{"p:0", "p:2", "p:3", "p:4", "p:5"}},
+		{Query{Action: "s3:GetObject", Resource: "arn:aws:s3:::data/report.csv"}, []string{"p:0", "p:4", "p:5"}},
+		{Query{Action: "s3:GetObject", Resource: "arn:aws:s3:::data/*"}, []string{"p:0", "p:3", "p:4", "p:5"}},
+		{Query{Resource: "arn:aws:s3:::logs/alice/x"}, []string{"p:1", "p:2", "p:5"}},
+		{Query{Action: "s3:*"}, []string{"p:0", "p:1", "p:2", "p:3", "p:4", "p:5"}},
+		{Query{Principal: "arn:aws:iam::222222222222:root"}, []string{"p:0", "p:1", "p:2", "p:4", "p:5"}},
+		{Query{}, []string{"p:0", "p:1", "p:2", "p:3", "p:4", "p:5"}},
+	}
+	for _, tt := range tests {
+		assert.EqualValues(t, tt.found, ids(x.Lookup(tt.query)), "%+v", tt.query)
+	}
+}
+
+// TestIndex_Superset checks that a lookup never misses a matching statement.
+func TestIndex_Superset(t *testing.T) {
+	policies := generate(2000)
+	x := New(policies)
+	matched := 0
+	for _, q := range queries(policies) {
+		found := map[string]bool{}
+		for _, p := range x.Lookup(q) {
+			found[p.Id] = true
+		}
+		for _, p := range policies {
+			if p.MatchesAction(q.Action) && p.MatchesResource(q.Resource) {
+				assert.True(t, found[p.Id], "%s for %+v", p.Id, q)
+				matched++
+			}
+		}
+	}
+	// every query is one the statement it was made from matches
+	assert.GreaterOrEqual(t, matched, len(queries(policies)))
+}
+
+var services = []string{"s3", "ec2", "iam", "kms", "lambda", "dynamodb", "sqs", "sns", "logs", "sts"}
+
+// generate returns n statements spread over services, buckets and actions,
+// with a few wildcards.
+func generate(n int) []*policy.Policy {
+	policies := make([]*policy.Policy, 0, n)
+	for i := 0; i < n; i++ {
+		service := services[i%len(services)]
+		action := fmt.Sprintf("%s:Action%d", service, i%97)
+		resource := fmt.Sprintf("arn:aws:%s:::resource-%d/*", service, i%1009)
+		switch i % 1000 {
+		case 0:
+			action = "*"
+		case 1:
+			action = service + ":*"
+		case 2:
+			resource = "*"
+		}
+		policies = append(policies, policy.NewStatement(fmt.Sprintf("p:%d", i), "", true, nil, []string{action}, []string{resource}))
+	}
+	return policies
+}
+
+// queries returns requests for concrete actions and resources, named the
+// way generate names them.
+func queries(policies []*policy.Policy) []Query {
+	x := []Query{}
+	for i := 0; i < len(policies); i += len(policies) / 50 {
+		x = append(x, Query{
+			Action:   fmt.Sprintf("%s:Action%d", services[i%len(services)], i%97),
+			Resource: fmt.Sprintf("arn:aws:%s:::resource-%d/object", services[i%len(services)], i%1009),
+		})
+	}
+	return x
+}
+
+func BenchmarkIndex_Build(b *testing.B) {
+	policies := generate(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New(policies)
+	}
+}
+
+func BenchmarkIndex_Lookup(b *testing.B) {
+	policies := generate(100000)
+	x := New(policies)
+	q := queries(policies)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Lookup(q[i%len(q)])
+	}
+}
+
+// BenchmarkScan is the linear scan the index replaces.
+func BenchmarkScan(b *testing.B) {
+	policies := generate(100000)
+	q := queries(policies)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		found := []*policy.Policy{}
+		for _, p := range policies {
+			if p.MatchesAction(q[i%len(q)].Action) && p.MatchesResource(q[i%len(q)].Resource) {
+				found = append(found, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package optimize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/aumahesh/policyparser/internal/aws"
+	"github.com/aumahesh/policyparser/pkg/catalog"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Options of the optimizer.
+type Options struct {
+	// Catalog is used to collapse action lists into wildcards. Without one
+	// actions are kept as written.
+	Catalog *catalog.Catalog
+	// CollapseActions replaces lists of actions with wildcards matching
+	// exactly the same actions of the catalog. A wildcard also matches
+	// actions missing from the catalog, so only the actions of services the
+	// catalog declares complete are collapsed, and a catalog declaring none,
+	// like the embedded one, is an error.
+	CollapseActions bool
+	// Limits override the size of the quotas documents are reported
+	// against, see aws.DefaultLimits; unknown quotas are an error.
+	Limits map[string]int
+	// Quota is the quota of documents whose origin does not tell where
+	// they are used, see aws.QuotaOf.
+	Quota string
+}
+
+// Document reports the optimization of the statements of one policy
+// document.
+type Document struct {
+	Name             string `json:"name" yaml:"name"`
+	StatementsBefore int    `json:"statements-before" yaml:"statements-before"`
+	StatementsAfter  int    `json:"statements-after" yaml:"statements-after"`
+	// Sizes of the document rendered to AWS JSON, as counted by AWS.
+	SizeBefore int `json:"size-before" yaml:"size-before"`
+	SizeAfter  int `json:"size-after" yaml:"size-after"`
+	// Quota is the quota the document counts against, and Limit its size,
+	// 0 if the check is disabled.
+	Quota    string `json:"quota" yaml:"quota"`
+	Limit    int    `json:"limit" yaml:"limit"`
+	Rendered string `json:"rendered" yaml:"rendered"`
+}
+
+// Result of an optimization.
+type Result struct {
+	Policies  []*policy.Policy `json:"policies" yaml:"policies"`
+	Documents []*Document      `json:"documents" yaml:"documents"`
+	// Changes describe each rewrite, e.g. "removed p:2, subsumed by p:0".
+	Changes []string `json:"changes" yaml:"changes"`
+}
+
+// Optimize returns policies rewritten into fewer, shorter statements
+// granting and denying exactly the same requests. Statements of different
+// documents are never combined.
+func Optimize(policies []*policy.Policy, opts Options) (*Result, error) {
+	r := &Result{
+		Policies:  []*policy.Policy{},
+		Documents: []*Document{},
+		Changes:   []string{},
+	}
+	if opts.Quota != "" {
+		if _, ok := aws.DefaultLimits()[opts.Quota]; !ok {
+			return nil, fmt.Errorf("%s is not a known quota", opts.Quota)
+		}
+	}
+	if opts.CollapseActions && !anyComplete(opts.Catalog) {
+		return nil, fmt.Errorf("collapsing actions needs a catalog that declares complete services")
+	}
+	if err := aws.CheckLimits(opts.Limits); err != nil {
+		return nil, err
+	}
+	limits := aws.DefaultLimits()
+	for quota, size := range opts.Limits {
+		limits[quota] = size
+	}
+
+	for _, group := range documents(policies) {
+		before, err := aws.Render(group)
+		if err != nil {
+			return nil, err
+		}
+
+		o := &optimizer{opts: opts}
+		x := o.removeSubsumed(group)
+		x = o.merge(x)
+		if opts.Catalog != nil && opts.CollapseActions {
+			x = o.collapse(x)
+			x = o.removeSubsumed(x)
+		}
+
+		after, err := aws.Render(x)
+		if err != nil {
+			return nil, err
+		}
+		r.Policies = append(r.Policies, x...)
+		r.Changes = append(r.Changes, o.changes...)
+		name := group[0].DocumentName()
+		if name == "" {
+			name = "policy"
+		}
+		quota := aws.QuotaOf(group[0].Origin, group[0].Kind, opts.Quota)
+		r.Documents = append(r.Documents, &Document{
+			Name:             name,
+			StatementsBefore: len(group),
+			StatementsAfter:  len(x),
+			SizeBefore:       aws.Size(before),
+			SizeAfter:        aws.Size(after),
+			Quota:            quota,
+			Limit:            limits[quota],
+			Rendered:         string(after),
+		})
+	}
+	return r, nil
+}
+
+// documents groups policies by the document they were parsed from, in
+// order.
+func documents(policies []*policy.Policy) [][]*policy.Policy {
+	groups := [][]*policy.Policy{}
+	index := map[string]int{}
+	for _, p := range policies {
+		key := fmt.Sprintf("%p|%s|%s", p.Origin, p.Kind, p.DocumentName())
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, []*policy.Policy{})
+		}
+		groups[i] = append(groups[i], p)
+	}
+	return groups
+}
+
+type optimizer struct {
+	opts    Options
+	changes []string
+}
+
+func (o *optimizer) note(format string, args ...interface{}) {
+	o.changes = append(o.changes, fmt.Sprintf(format, args...))
+}
+
+// removeSubsumed drops the statements whose every request is covered by
+// another statement of the same effect that applies at least as often.
+// Of two equivalent statements the first one is kept.
+func (o *optimizer) removeSubsumed(policies []*policy.Policy) []*policy.Policy {
+	removed := make([]bool, len(policies))
+	for i, s := range policies {
+		for j, t := range policies {
+			if i == j || removed[j] || !covers(t, s) {
+				continue
+			}
+			if j > i && covers(s, t) {
+				continue
+			}
+			removed[i] = true
+			o.note("removed %s, subsumed by %s", s.Id, t.Id)
+			break
+		}
+	}
+	x := []*policy.Policy{}
+	for i, p := range policies {
+		if !removed[i] {
+			x = append(x, p)
+		}
+	}
+	return x
+}
+
+// merge joins the actions of statements identical but for their actions.
+func (o *optimizer) merge(policies []*policy.Policy) []*policy.Policy {
+	x := []*policy.Policy{}
+	index := map[string]int{}
+	for _, p := range policies {
+		actions := p.WrittenGlobs(policy.FieldActions)
+		if len(actions) == 0 {
+			x = append(x, p)
+			continue
+		}
+		key := scopeKey(p)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(x)
+			x = append(x, p)
+			continue
+		}
+		x[i] = withActions(x[i], union(x[i].WrittenGlobs(policy.FieldActions), actions))
+		o.note("merged %s into %s", p.Id, x[i].Id)
+	}
+	return x
+}
+
+// collapse replaces the actions of each statement with the shortest
+// wildcards matching only actions it already matches, in the services the
+// catalog declares complete.
+func (o *optimizer) collapse(policies []*policy.Policy) []*policy.Policy {
+	x := []*policy.Policy{}
+	for _, p := range policies {
+		actions := p.WrittenGlobs(policy.FieldActions)
+		collapsed := collapseActions(actions, o.opts.Catalog)
+		if len(collapsed) < len(actions) {
+			o.note("collapsed %d actions of %s into %s", len(actions), p.Id, strings.Join(collapsed, ", "))
+			p = withActions(p, collapsed)
+		}
+		x = append(x, p)
+	}
+	return x
+}
+
+// anyComplete reports whether c declares a service complete.
+func anyComplete(c *catalog.Catalog) bool {
+	if c == nil {
+		return false
+	}
+	for _, s := range c.Services() {
+		if s.Complete {
+			return true
+		}
+	}
+	return false
+}
+
+func collapseActions(actions []string, c *catalog.Catalog) []string {
+	granted := map[*catalog.Action]bool{}
+	for _, glob := range actions {
+		for _, a := range c.Expand(glob) {
+			granted[a] = true
+		}
+	}
+
+	kept := []string{}
+	literals := []*catalog.Action{}
+	for _, glob := range actions {
+		if a := c.Lookup(glob); a != nil && !strings.ContainsAny(glob, "*?") && c.Complete(a.Service) {
+			literals = append(literals, a)
+			continue
+		}
+		// wildcards are kept as written, and so are the actions of services
+		// the catalog may not know every action of: a wildcard would match
+		// those too
+		kept = append(kept, glob)
+	}
+
+	for _, a := range literals {
+		if matchAny(kept, a.FullName()) {
+			continue
+		}
+		kept = append(kept, widest(a, c, granted))
+	}
+	return union(nil, kept)
+}
+
+// widest returns the shortest wildcard of the form service:prefix*
+// matching a and at least another action, all of them granted, or the
+// name of a when there is none. Prefixes end between words of the action
+// name, e.g. s3:GetObject* but not s3:GetO*, and are never empty: service:*
+// would also match the actions AWS adds to the service later.
+func widest(a *catalog.Action, c *catalog.Catalog, granted map[*catalog.Action]bool) string {
+	for n := 1; n <= len(a.Name); n++ {
+		if n < len(a.Name) && !unicode.IsUpper(rune(a.Name[n])) {
+			continue
+		}
+		glob := a.Service + ":" + a.Name[:n] + "*"
+		matched := c.Expand(glob)
+		if len(matched) < 2 {
+			continue
+		}
+		safe := true
+		for _, m := range matched {
+			if !granted[m] {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			return glob
+		}
+	}
+	return a.FullName()
+}
+
+// covers reports whether every request in the scope of s is in the scope
+// of t, and t applies whenever s does.
+func covers(t, s *policy.Policy) bool {
+	if t.Allowed != s.Allowed || !policy.ConditionsIn(t.Condition, s.Condition) {
+		return false
+	}
+	if len(s.Globs(policy.FieldActions)) == 0 && len(s.Globs(policy.FieldNotActions)) == 0 {
+		return false
+	}
+	return scopeCovers(t, s, policy.FieldActions, policy.FieldNotActions, false) &&
+		scopeCovers(t, s, policy.FieldResources, policy.FieldNotResources, true) &&
+		scopeCovers(t, s, policy.FieldSubjects, policy.FieldNotSubjects, true)
+}
+
+// scopeCovers reports whether the values matched by the positive and
+// negative field of s are all matched by those of t. A statement with
+// neither field matches any value when emptyIsAny is set.
+func scopeCovers(t, s *policy.Policy, positive, negative string, emptyIsAny bool) bool {
+	tPos, tNeg := t.ComparableGlobs(positive), t.ComparableGlobs(negative)
+	sPos, sNeg := s.ComparableGlobs(positive), s.ComparableGlobs(negative)
+
+	if emptyIsAny && len(tPos) == 0 && len(tNeg) == 0 {
+		return true
+	}
+	if emptyIsAny && len(sPos) == 0 && len(sNeg) == 0 {
+		return false
+	}
+
+	switch {
+	case len(sPos) > 0 && len(tPos) > 0:
+		for _, i := range sPos {
+			if !containedIn(i, tPos) {
+				return false
+			}
+		}
+		return true
+	case len(sPos) > 0 && len(tNeg) > 0:
+		for _, i := range sPos {
+			for _, n := range tNeg {
+				if policy.GlobsOverlap(i, n) {
+					return false
+				}
+			}
+		}
+		return true
+	case len(sNeg) > 0 && len(tNeg) > 0:
+		// the complement of sNeg is in the complement of tNeg
+		for _, n := range tNeg {
+			if !containedIn(n, sNeg) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func containedIn(glob string, globs []string) bool {
+	for _, g := range globs {
+		if policy.GlobContains(g, glob) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeKey identifies the effect, resources, principals and conditions of
+// a statement.
+func scopeKey(p *policy.Policy) string {
+	x := []string{fmt.Sprintf("%t", p.Allowed)}
+	for _, field := range []string{
+		policy.FieldResources,
+		policy.FieldNotResources,
+		policy.FieldSubjects,
+		policy.FieldNotSubjects,
+	} {
+		globs := append([]string{}, p.Globs(field)...)
+		sort.Strings(globs)
+		x = append(x, field+"="+strings.Join(globs, "\x00"))
+	}
+	conditions := []string{}
+	for _, c := range p.Condition {
+		conditions = append(conditions, c.Canonical())
+	}
+	sort.Strings(conditions)
+	x = append(x, conditions...)
+	return strings.Join(x, "\x01")
+}
+
+// withActions returns a copy of p with the given actions.
+func withActions(p *policy.Policy, actions []string) *policy.Policy {
+	q := *p
+	q.Actions, q.Patterns.Actions = policy.NewVersionPatterns(p.Version, actions, true)
+	q.Variables = policy.CollectVariables(&q)
+	return &q
+}
+
+// union appends the values of y missing from x, ignoring case.
+func union(x, y []string) []string {
+	seen := map[string]bool{}
+	z := []string{}
+	for _, s := range append(append([]string{}, x...), y...) {
+		if !seen[strings.ToLower(s)] {
+			seen[strings.ToLower(s)] = true
+			z = append(z, s)
+		}
+	}
+	return z
+}
+
+func matchAny(globs []string, value string) bool {
+	for _, g := range globs {
+		if policy.MatchGlob(strings.ToLower(g), strings.ToLower(value)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/internal/aws"
+	"github.com/aumahesh/policyparser/pkg/catalog"
+	"github.com/aumahesh/policyparser/pkg/policy"
+	"github.com/aumahesh/policyparser/pkg/subsume"
+)
+
+func TestOptimize_MergeAndRemove(t *testing.T) {
+	policies := []*policy.Policy{
+		policy.NewStatement("p:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::data/*"}),
+		policy.NewStatement("p:1", "", true, nil, []string{"s3:PutObject"}, []string{"arn:aws:s3:::data/*"}),
+		policy.NewStatement("p:2", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::data/reports/*"}),
+		policy.NewStatement("p:3", "", false, nil, []string{"s3:DeleteObject"}, []string{"*"}),
+		policy.NewStatement("p:4", "", false, nil, []string{"s3:DeleteObject"}, []string{"*"}),
+	}
+	conditional := policy.NewStatement("p:5", "", true, nil, []string{"s3:PutObject"}, []string{"arn:aws:s3:::data/*"})
+	conditional.Condition = []policy.Condition{
+		{Operation: "Bool", Key: "aws:SecureTransport", Value: policy.BoolValues(true)},
+	}
+	policies = append(policies, conditional)
+
+	r, err := Optimize(policies, Options{})
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Len(t, r.Policies, 2)
+	if len(r.Policies) != 2 {
+		t.FailNow()
+	}
+	assert.EqualValues(t, "p:0", r.Policies[0].Id)
+	assert.EqualValues(t, []string{"s3:GetObject", "s3:PutObject"}, r.Policies[0].Globs(policy.FieldActions))
+	assert.EqualValues(t, "p:3", r.Policies[1].Id)
+	assert.Contains(t, r.Changes, "removed p:2, subsumed by p:0")
+	assert.Contains(t, r.Changes, "removed p:4, subsumed by p:3")
+	assert.Contains(t, r.Changes, "removed p:5, subsumed by p:1")
+	assert.Contains(t, r.Changes, "merged p:1 into p:0")
+
+	assert.Len(t, r.Documents, 1)
+	d := r.Documents[0]
+	assert.EqualValues(t, "p", d.Name)
+	assert.EqualValues(t, 6, d.StatementsBefore)
+	assert.EqualValues(t, 2, d.StatementsAfter)
+	assert.True(t, d.SizeAfter < d.SizeBefore)
+	assert.EqualValues(t, aws.QuotaManaged, d.Quota)
+	assert.EqualValues(t, 6144, d.Limit)
+
+	// only the union of p:0 and p:1 covers the merged statement, which the
+	// subsumption check cannot prove but must not refute
+	assert.Len(t, subsume.Check(policies, r.Policies).Counterexamples, 0)
+	assert.True(t, subsume.Check(r.Policies, policies).Subsumed)
+}
+
+func TestOptimize_Collapse(t *testing.T) {
+	c, err := catalog.Parse([]byte(`{"services": [{
+		"prefix": "s3", "name": "Amazon S3", "complete": true,
+		"actions": [
+			{"name": "GetBucketPolicy", "access-level": "Read"},
+			{"name": "GetObject", "access-level": "Read"},
+			{"name": "GetObjectAcl", "access-level": "Read"},
+			{"name": "GetObjectTagging", "access-level": "Tagging"},
+			{"name": "ListBucket", "access-level": "List"},
+			{"name": "PutObject", "access-level": "Write"}
+		]
+	}, {
+		"prefix": "sqs", "name": "Amazon SQS", "complete": true,
+		"actions": [
+			{"name": "ReceiveMessage", "access-level": "Read"},
+			{"name": "SendMessage", "access-level": "Write"}
+		]
+	}]}`))
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	actions := []string{"s3:ListBucket", "s3:GetObject", "s3:GetObjectAcl", "s3:GetObjectTagging",
+		"s3:Put*", "s3:PutObject", "athena:GetQueryResults", "sqs:ReceiveMessage", "sqs:SendMessage"}
+
+	policies := []*policy.Policy{policy.NewStatement("p:0", "", true, nil, actions, []string{"*"})}
+	r, err := Optimize(policies, Options{Catalog: c, CollapseActions: true})
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Len(t, r.Policies, 1)
+	if len(r.Policies) != 1 {
+		t.FailNow()
+	}
+	// never sqs:*, which would also match the actions added to SQS later
+	assert.EqualValues(t, []string{"s3:Put*", "athena:GetQueryResults", "s3:ListBucket", "s3:GetObject*",
+		"sqs:ReceiveMessage", "sqs:SendMessage"}, r.Policies[0].Globs(policy.FieldActions))
+
+	// the rewritten statement grants the same actions of the catalog
+	before := c.ExpandPolicy(policies[0]).Actions
+	after := c.ExpandPolicy(r.Policies[0]).Actions
+	assert.EqualValues(t, before, after)
+
+	r, err = Optimize(policies, Options{Catalog: c})
+	assert.Nil(t, err)
+	assert.EqualValues(t, actions, r.Policies[0].Globs(policy.FieldActions))
+
+	// the embedded catalog is not complete: s3:GetObject* would also grant
+	// s3:GetObjectAttributes, s3:GetObjectRetention, ... so collapsing with
+	// it, or with no catalog, is refused rather than silently doing nothing
+	actions = []string{"s3:GetObject", "s3:GetObjectAcl", "s3:GetObjectTagging", "s3:GetObjectVersion"}
+	policies = []*policy.Policy{policy.NewStatement("p:0", "", true, nil, actions, []string{"*"})}
+	for _, c := range []*catalog.Catalog{catalog.Default(), nil} {
+		_, err = Optimize(policies, Options{Catalog: c, CollapseActions: true})
+		assert.NotNil(t, err)
+		if err != nil {
+			assert.Contains(t, err.Error(), "complete services")
+		}
+	}
+	r, err = Optimize(policies, Options{Catalog: catalog.Default()})
+	assert.Nil(t, err)
+	assert.EqualValues(t, actions, r.Policies[0].Globs(policy.FieldActions))
+}
+
+func TestOptimize_Limits(t *testing.T) {
+	p := policy.NewStatement("p:0", "", true, nil, []string{"s3:GetObject"}, []string{"*"})
+	r, err := Optimize([]*policy.Policy{p}, Options{Quota: aws.QuotaSCP})
+	assert.Nil(t, err)
+	assert.EqualValues(t, aws.QuotaSCP, r.Documents[0].Quota)
+	assert.EqualValues(t, 5120, r.Documents[0].Limit)
+
+	r, err = Optimize([]*policy.Policy{p}, Options{Quota: aws.QuotaSCP, Limits: map[string]int{aws.QuotaSCP: 0}})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, r.Documents[0].Limit)
+
+	trust := policy.NewStatement("t:0", "", true, nil, []string{"sts:AssumeRole"}, nil)
+	trust.Kind = policy.KindTrust
+	r, err = Optimize([]*policy.Policy{trust}, Options{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, aws.QuotaTrust, r.Documents[0].Quota)
+	assert.EqualValues(t, 2048, r.Documents[0].Limit)
+
+	_, err = Optimize([]*policy.Policy{p}, Options{Quota: "unknown"})
+	assert.NotNil(t, err)
+	_, err = Optimize([]*policy.Policy{p}, Options{Limits: map[string]int{"unknown": 0, "other": 0}})
+	assert.NotNil(t, err)
+	if err != nil {
+		assert.EqualValues(t, "other, unknown are not known quotas", err.Error())
+	}
+}
+
+func TestOptimize_Documents(t *testing.T) {
+	a := policy.NewStatement("a:0", "", true, nil, []string{"s3:GetObject"}, []string{"*"})
+	b := policy.NewStatement("b:0", "", true, nil, []string{"s3:GetObject"}, []string{"*"})
+	r, err := Optimize([]*policy.Policy{a, b}, Options{})
+	assert.Nil(t, err)
+	assert.Len(t, r.Policies, 2)
+	assert.Len(t, r.Documents, 2)
+}
@@ -6,30 +6,63 @@ import (
 	"github.com/aumahesh/policyparser/internal/aws"
 	"github.com/aumahesh/policyparser/internal/azure"
 	"github.com/aumahesh/policyparser/internal/gcp"
+	"github.com/aumahesh/policyparser/pkg/decoder"
 	"github.com/aumahesh/policyparser/pkg/policy"
 )
 
 const (
-	Aws   = "aws"
+	Aws   = aws.Provider
 	Azure = "azure"
 	Gcp   = "gcp"
 )
 
 type Parser interface {
 	Parse() error
+	Validate() error
+	Warnings() []error
 	GetPolicy() ([]*policy.Policy, error)
+	// Json returns the parsed policies in their policy.Envelope.
 	Json() ([]byte, error)
-	WriteJson(string) error
 }
 
-func NewParser(p, policyText string, escaped bool) (Parser, error) {
+// Limited is implemented by parsers whose Validate checks the size of the
+// documents against the quotas of their cloud provider.
+type Limited interface {
+	// SetLimits overrides the size of the given quotas, failing for an
+	// unknown quota.
+	SetLimits(limits map[string]int) error
+	// SetQuota sets the quota of documents that do not tell where they are
+	// used, failing for an unknown quota.
+	SetQuota(quota string) error
+}
+
+// NewParser returns the parser for cloud provider p. In strict mode values
+// the parser would otherwise have to guess at fail the parse instead of being
+// reported as warnings.
+func NewParser(p, policyText string, dec decoder.Decoder, strict bool) (Parser, error) {
 	switch p {
 	case Aws:
-		return aws.NewAwsPolicyParser(policyText, escaped)
+		return aws.NewAwsPolicyParser(policyText, dec, strict)
 	case Azure:
-		return azure.NewAzurePolicyParser(policyText, escaped)
+		return azure.NewAzurePolicyParser(policyText, dec, strict)
 	case Gcp:
-		return gcp.NewGcpPolicyParser(policyText, escaped)
+		return gcp.NewGcpPolicyParser(policyText, dec, strict)
 	}
 	return nil, fmt.Errorf("%s is not a supported cloud provider", p)
 }
+
+// Errors returns the individual errors of an error returned by Validate,
+// e.g. one per invalid statement, each with its position in the document.
+func Errors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	x := []error{}
+	if errs, ok := err.(aws.ValidationErrors); ok {
+		for _, e := range errs {
+			x = append(x, e)
+		}
+		return x
+	}
+	return append(x, err)
+}
@@ -0,0 +1,235 @@
+package pdp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aumahesh/policyparser/pkg/decoder"
+	"github.com/aumahesh/policyparser/pkg/evaluator"
+	"github.com/aumahesh/policyparser/pkg/parser"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Evaluation logics of a PDP.
+const (
+	LogicAws    = "aws"
+	LogicSingle = "single"
+)
+
+// Options configure a PDP.
+type Options struct {
+	// Dir holds the .json policy files, in any depth of subdirectories. A
+	// directory named after a provider, e.g. aws, sets the provider of the
+	// files below it, and one named after a kind, e.g. scp, their kind.
+	// Hidden files are skipped.
+	Dir      string
+	Provider string
+	Decoders []string
+	Strict   bool
+	// Logic is LogicAws, the default, or LogicSingle, see
+	// evaluator.EvaluateAws and evaluator.Evaluate.
+	Logic string
+}
+
+// Snapshot is a set of policies loaded together.
+type Snapshot struct {
+	Policies []*policy.Policy
+	Files    []string
+	LoadedAt time.Time
+	// Warnings are the problems a lenient parse found and let through,
+	// e.g. a condition value kept as a string, prefixed with their file.
+	Warnings []string
+
+	evaluator *evaluator.Evaluator
+}
+
+// PDP is a policy decision point: it decides requests against the policies
+// of a directory, reloading them on demand or, with Watch, whenever they
+// change. Decisions never wait for a reload; they use the snapshot current
+// when they start. A failed reload keeps the last good snapshot.
+type PDP struct {
+	opts Options
+	// current holds the *Snapshot requests are decided against.
+	current atomic.Value
+	// reloading serializes reloads.
+	reloading sync.Mutex
+	lastErr   atomic.Value
+}
+
+// errHolder lets a nil error be stored in an atomic.Value.
+type errHolder struct {
+	err error
+}
+
+// New loads the policies of opts.Dir. It fails if they do not parse.
+func New(opts Options) (*PDP, error) {
+	if opts.Provider == "" {
+		opts.Provider = parser.Aws
+	}
+	if len(opts.Decoders) == 0 {
+		opts.Decoders = []string{decoder.Auto}
+	}
+	if opts.Logic == "" {
+		opts.Logic = LogicAws
+	}
+	if opts.Logic != LogicAws && opts.Logic != LogicSingle {
+		return nil, fmt.Errorf("%s is not a supported logic", opts.Logic)
+	}
+	if !providers[opts.Provider] {
+		return nil, fmt.Errorf("the %s parser returns no policies to decide against", opts.Provider)
+	}
+
+	p := &PDP{opts: opts}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Snapshot returns the policies requests are currently decided against.
+func (p *PDP) Snapshot() *Snapshot {
+	return p.current.Load().(*Snapshot)
+}
+
+// Err returns the error of the last reload, nil if it succeeded.
+func (p *PDP) Err() error {
+	h, _ := p.lastErr.Load().(errHolder)
+	return h.err
+}
+
+// Decide decides request against the current snapshot.
+func (p *PDP) Decide(request evaluator.Request) *evaluator.Decision {
+	s := p.Snapshot()
+	if p.opts.Logic == LogicSingle {
+		return s.evaluator.Evaluate(request)
+	}
+	return s.evaluator.EvaluateAws(request)
+}
+
+// Reload parses every policy file of the directory and, if all of them
+// parse, makes them the current snapshot. Otherwise the current snapshot is
+// kept and the error, also returned by Err, names the files that failed.
+func (p *PDP) Reload() error {
+	p.reloading.Lock()
+	defer p.reloading.Unlock()
+
+	s, err := p.load()
+	p.lastErr.Store(errHolder{err: err})
+	if err != nil {
+		return err
+	}
+	p.current.Store(s)
+	return nil
+}
+
+func (p *PDP) load() (*Snapshot, error) {
+	files := []string{}
+	err := filepath.Walk(p.opts.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != p.opts.Dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && isPolicyFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	s := &Snapshot{Policies: []*policy.Policy{}, Files: files, LoadedAt: time.Now(), Warnings: []string{}}
+	failed := []string{}
+	for _, path := range files {
+		policies, warnings, err := p.parseFile(path)
+		if err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		s.Policies = append(s.Policies, policies...)
+		s.Warnings = append(s.Warnings, warnings...)
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	s.evaluator = evaluator.NewEvaluator(s.Policies)
+	return s, nil
+}
+
+// isPolicyFile reports whether path is a file the parsers can read: none
+// of them reads YAML yet.
+func isPolicyFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".json"
+}
+
+// providers are those whose parser returns policies; the azure and gcp
+// parsers are stubs, so their files would silently drop out of decisions.
+var providers = map[string]bool{
+	parser.Aws: true,
+}
+
+// parseFile parses the file at path with the provider and kind its
+// directories name, and returns its policies along with the warnings of
+// the parse.
+func (p *PDP) parseFile(path string) ([]*policy.Policy, []string, error) {
+	provider, kind := p.opts.Provider, ""
+	rel, err := filepath.Rel(p.opts.Dir, filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range strings.Split(filepath.ToSlash(rel), "/") {
+		switch name {
+		case parser.Aws, parser.Azure, parser.Gcp:
+			if !providers[name] {
+				return nil, nil, fmt.Errorf("%s: the %s parser returns no policies to decide against", path, name)
+			}
+			provider = name
+		case policy.KindIdentity, policy.KindResource, policy.KindTrust, policy.KindSCP,
+			policy.KindRCP, policy.KindBoundary, policy.KindSession:
+			kind = name
+		}
+	}
+
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec, err := decoder.NewPipeline(p.opts.Decoders...)
+	if err != nil {
+		return nil, nil, err
+	}
+	x, err := parser.NewParser(provider, string(text), dec, p.opts.Strict)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = x.Parse(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	policies, err := x.GetPolicy()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	if kind != "" {
+		for _, pol := range policies {
+			pol.Kind = kind
+		}
+	}
+	warnings := []string{}
+	for _, w := range x.Warnings() {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", path, w.Error()))
+	}
+	return policies, warnings, nil
+}
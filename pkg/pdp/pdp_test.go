@@ -0,0 +1,180 @@
+package pdp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/evaluator"
+)
+
+const (
+	readData = `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::data/*"}]}`
+	readAll = `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": "s3:Get*", "Resource": "*"}]}`
+	onlyEc2 = `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": "ec2:*", "Resource": "*"}]}`
+)
+
+func write(t *testing.T, dir, name, text string) {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(text), 0644))
+}
+
+var (
+	getData  = evaluator.Request{Principal: "arn:aws:iam::111111111111:user/alice", Action: "s3:GetObject", Resource: "arn:aws:s3:::data/x"}
+	getLogs  = evaluator.Request{Principal: "arn:aws:iam::111111111111:user/alice", Action: "s3:GetObject", Resource: "arn:aws:s3:::logs/x"}
+	runEc2   = evaluator.Request{Principal: "arn:aws:iam::111111111111:user/alice", Action: "ec2:RunInstances", Resource: "*"}
+	anything = []evaluator.Request{getData, getLogs, runEc2}
+)
+
+func TestPDP_Reload(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "read.json", readData)
+	write(t, dir, "aws/scp/org.json", onlyEc2)
+	write(t, dir, ".read.json.swp", "not a policy")
+	// no parser reads yaml
+	write(t, dir, "read.yaml", "Version: 2012-10-17")
+
+	p, err := New(Options{Dir: dir})
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Len(t, p.Snapshot().Files, 2)
+	// the scp only allows ec2
+	assert.False(t, p.Decide(getData).Allowed)
+	assert.EqualValues(t, "no service control policy allows the request", p.Decide(getData).Reason)
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "aws/scp/org.json")))
+	assert.Nil(t, p.Reload())
+	assert.True(t, p.Decide(getData).Allowed)
+	assert.False(t, p.Decide(getLogs).Allowed)
+
+	// a broken file keeps the last good policies
+	write(t, dir, "broken.json", `{"Statement": [`)
+	err = p.Reload()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "broken.json")
+	assert.EqualValues(t, err, p.Err())
+	assert.True(t, p.Decide(getData).Allowed)
+
+	assert.Nil(t, os.Remove(filepath.Join(dir, "broken.json")))
+	assert.Nil(t, p.Reload())
+	assert.Nil(t, p.Err())
+	assert.Empty(t, p.Snapshot().Warnings)
+
+	// what a lenient parse lets through is reported, and fails a strict one
+	write(t, dir, "lenient.json", `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Deny", "Action": "s3:*", "Resource": "*",
+		 "Condition": {"NumericGreaterThan": {"s3:max-keys": "many"}}}]}`)
+	assert.Nil(t, p.Reload())
+	assert.Len(t, p.Snapshot().Warnings, 1)
+	if len(p.Snapshot().Warnings) == 1 {
+		assert.Contains(t, p.Snapshot().Warnings[0], "lenient.json: ")
+	}
+	_, err = New(Options{Dir: dir, Strict: true})
+	assert.NotNil(t, err)
+	assert.Nil(t, os.Remove(filepath.Join(dir, "lenient.json")))
+	assert.Nil(t, p.Reload())
+
+	// the gcp parser returns no policies, which must not go unnoticed
+	write(t, dir, "gcp/iam.json", `{"bindings": []}`)
+	err = p.Reload()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the gcp parser returns no policies")
+	assert.Nil(t, os.RemoveAll(filepath.Join(dir, "gcp")))
+
+	_, err = New(Options{Dir: dir, Provider: "azure"})
+	assert.NotNil(t, err)
+
+	_, err = New(Options{Dir: filepath.Join(dir, "missing")})
+	assert.NotNil(t, err)
+}
+
+func TestPDP_Watch(t *testing.T) {
+	Debounce = 10 * time.Millisecond
+	dir := t.TempDir()
+	write(t, dir, "policy.json", readData)
+
+	p, err := New(Options{Dir: dir})
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	reloads := make(chan error, 10)
+	done := make(chan struct{})
+	stopped := make(chan error)
+	go func() {
+		stopped <- p.Watch(done, func(err error) { reloads <- err })
+	}()
+	// give the watcher time to start
+	time.Sleep(50 * time.Millisecond)
+
+	// readers keep deciding, lock free, while the policies are reloaded
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					for _, r := range anything {
+						p.Decide(r)
+					}
+				}
+			}
+		}()
+	}
+
+	// next waits for the reloads of a change to settle and returns the
+	// error of the last one
+	next := func() error {
+		var err error
+		select {
+		case err = <-reloads:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload")
+		}
+		for {
+			select {
+			case err = <-reloads:
+			case <-time.After(10 * Debounce):
+				return err
+			}
+		}
+	}
+
+	write(t, dir, "policy.json", readAll)
+	assert.Nil(t, next())
+	assert.True(t, p.Decide(getLogs).Allowed)
+
+	write(t, dir, "policy.json", `{"Statement": `)
+	assert.NotNil(t, next())
+	assert.True(t, p.Decide(getLogs).Allowed)
+
+	// files of new directories are loaded as well
+	write(t, dir, "scp/org.json", onlyEc2)
+	write(t, dir, "policy.json", readAll)
+	for p.Err() != nil || len(p.Snapshot().Files) != 2 {
+		next()
+	}
+	assert.False(t, p.Decide(getLogs).Allowed)
+
+	close(stop)
+	wg.Wait()
+	close(done)
+	assert.Nil(t, <-stopped)
+}
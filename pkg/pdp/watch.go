@@ -0,0 +1,85 @@
+package pdp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+// Debounce is how long Watch waits for changes to settle before reloading,
+// so that saving several files, or an editor writing one in steps, reloads
+// once.
+var Debounce = 100 * time.Millisecond
+
+// Watch reloads the policies whenever the files of the directory change,
+// until done is closed. After each reload it calls reloaded, if not nil,
+// with the error of the reload.
+func (p *PDP) Watch(done <-chan struct{}, reloaded func(error)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err = watchTree(w, p.opts.Dir); err != nil {
+		return err
+	}
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-done:
+			return nil
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if strings.HasPrefix(filepath.Base(event.Name), ".") {
+				continue
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err = watchTree(w, event.Name); err != nil {
+						log.Warnf("watching %s: %s", event.Name, err.Error())
+					}
+				}
+			}
+			settled = time.After(Debounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warnf("watching %s: %s", p.opts.Dir, err.Error())
+		case <-settled:
+			settled = nil
+			err := p.Reload()
+			if err != nil {
+				log.Warnf("reloading %s, keeping the last good policies: %s", p.opts.Dir, err.Error())
+			} else {
+				log.Debugf("reloaded %d policies from %s", len(p.Snapshot().Policies), p.opts.Dir)
+			}
+			if reloaded != nil {
+				reloaded(err)
+			}
+		}
+	}
+}
+
+// watchTree adds dir and its subdirectories, but hidden ones, to w.
+func watchTree(w *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
@@ -0,0 +1,49 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// String renders c as "operator key [values]", its values sorted.
+func (c Condition) String() string {
+	values := c.Value.Text()
+	sort.Strings(values)
+	return fmt.Sprintf("%s %s [%s]", c.Operation, c.Key, strings.Join(values, ", "))
+}
+
+// ConditionStrings renders each condition of p, see Condition.String.
+func (p *Policy) ConditionStrings() []string {
+	x := []string{}
+	for _, c := range p.Condition {
+		x = append(x, c.String())
+	}
+	return x
+}
+
+// Canonical returns c in a form equal for the conditions with the same
+// operator, key in any case, and values in any order.
+func (c Condition) Canonical() string {
+	values := c.Value.Text()
+	sort.Strings(values)
+	return fmt.Sprintf("%s|%s|%s|%s", c.Operation, strings.ToLower(c.Key), c.Value.Type(), strings.Join(values, "\x00"))
+}
+
+// ConditionsIn reports whether every condition of inner is also one of
+// outer, so that inner holds whenever outer does.
+func ConditionsIn(inner, outer []Condition) bool {
+	for _, i := range inner {
+		found := false
+		for _, o := range outer {
+			if i.Canonical() == o.Canonical() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
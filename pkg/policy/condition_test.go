@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCondition_String(t *testing.T) {
+	p := &Policy{Condition: []Condition{
+		{Operation: "StringEquals", Key: "aws:PrincipalTag/team", Value: StringValues("red", "blue")},
+		{Operation: "NumericLessThan", Key: "s3:max-keys", Value: Int64Values(10)},
+	}}
+	assert.EqualValues(t, []string{
+		"StringEquals aws:PrincipalTag/team [blue, red]",
+		"NumericLessThan s3:max-keys [10]",
+	}, p.ConditionStrings())
+	assert.Empty(t, (&Policy{}).ConditionStrings())
+}
+
+func TestConditionsIn(t *testing.T) {
+	ip := Condition{Operation: "IpAddress", Key: "aws:SourceIp", Value: StringValues("10.0.0.0/8", "192.168.0.0/16")}
+	tag := Condition{Operation: "StringEquals", Key: "aws:PrincipalTag/team", Value: StringValues("red")}
+	same := Condition{Operation: "IpAddress", Key: "AWS:sourceip", Value: StringValues("192.168.0.0/16", "10.0.0.0/8")}
+
+	assert.Equal(t, ip.Canonical(), same.Canonical())
+	assert.True(t, ConditionsIn(nil, []Condition{ip}))
+	assert.True(t, ConditionsIn([]Condition{same}, []Condition{tag, ip}))
+	assert.False(t, ConditionsIn([]Condition{ip, tag}, []Condition{ip}))
+	assert.False(t, ConditionsIn([]Condition{{Operation: "NotIpAddress", Key: "aws:SourceIp", Value: ip.Value}}, []Condition{ip}))
+}
@@ -0,0 +1,46 @@
+package policy
+
+// FormatVersion is the version of the JSON and YAML format of policies
+// written in an Envelope. The minor version changes when fields are added,
+// the major version when a change would break consumers.
+const FormatVersion = "1.0"
+
+// Envelope wraps parsed policies with the version of their format and the
+// cloud provider they were parsed from.
+type Envelope struct {
+	FormatVersion string    `json:"format-version" yaml:"format-version"`
+	Provider      string    `json:"provider" yaml:"provider"`
+	Policies      []*Policy `json:"policies" yaml:"policies"`
+}
+
+// NewEnvelope wraps policies parsed from provider in an Envelope of the
+// current FormatVersion.
+func NewEnvelope(provider string, policies []*Policy) *Envelope {
+	if policies == nil {
+		policies = []*Policy{}
+	}
+	return &Envelope{
+		FormatVersion: FormatVersion,
+		Provider:      provider,
+		Policies:      policies,
+	}
+}
+
+// JSONSchema describes the serialized form of a Condition, see conditionDoc.
+func (c Condition) JSONSchema() map[string]interface{} {
+	types := []interface{}{TypeString, TypeInt64, TypeFloat64, TypeBool, TypeDate, TypeNull}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"operator": map[string]interface{}{"type": "string"},
+			"key":      map[string]interface{}{"type": "string"},
+			"values": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": []interface{}{"string", "number", "boolean", "null"}},
+			},
+			"value-type": map[string]interface{}{"type": "string", "enum": types},
+		},
+		"required":             []interface{}{"operator", "key", "values", "value-type"},
+		"additionalProperties": false,
+	}
+}
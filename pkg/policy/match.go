@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"strings"
+)
+
+// MatchesAction reports whether action is in the scope of p: it matches one
+// of Actions, or none of NotActions. Action names are case-insensitive.
+func (p *Policy) MatchesAction(action string) bool {
+	action = strings.ToLower(action)
+	if actions := p.Globs(FieldActions); len(actions) > 0 {
+		return matchAny(lowerAll(actions), action)
+	}
+	if notActions := p.Globs(FieldNotActions); len(notActions) > 0 {
+		return !matchAny(lowerAll(notActions), action)
+	}
+	return false
+}
+
+// MatchesResource reports whether resource is in the scope of p. A policy
+// without Resource or NotResource, e.g. a trust policy, applies to any
+// resource.
+func (p *Policy) MatchesResource(resource string) bool {
+	if resources := p.Globs(FieldResources); len(resources) > 0 {
+		return matchAny(resources, resource)
+	}
+	if notResources := p.Globs(FieldNotResources); len(notResources) > 0 {
+		return !matchAny(notResources, resource)
+	}
+	return true
+}
+
+// MatchesPrincipal reports whether principal is in the scope of p. A policy
+// without Principal or NotPrincipal, e.g. an identity policy, applies to
+// any principal.
+func (p *Policy) MatchesPrincipal(principal string) bool {
+	if subjects := p.Globs(FieldSubjects); len(subjects) > 0 {
+		return matchAny(subjects, principal)
+	}
+	if notSubjects := p.Globs(FieldNotSubjects); len(notSubjects) > 0 {
+		return !matchAny(notSubjects, principal)
+	}
+	return true
+}
+
+// Matches reports whether the request is in the scope of p, ignoring its
+// conditions.
+func (p *Policy) Matches(principal, action, resource string) bool {
+	return p.MatchesAction(action) && p.MatchesResource(resource) && p.MatchesPrincipal(principal)
+}
+
+func matchAny(globs []string, value string) bool {
+	for _, g := range globs {
+		if MatchGlob(g, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(x []string) []string {
+	y := []string{}
+	for _, s := range x {
+		y = append(y, strings.ToLower(s))
+	}
+	return y
+}
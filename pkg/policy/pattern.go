@@ -0,0 +1,383 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	TokenLiteral   = "literal"    // text that must match exactly
+	TokenAnyString = "any-string" // "*", any sequence of characters
+	TokenAnyChar   = "any-char"   // "?", any single character
+	TokenVariable  = "variable"   // "${aws:username}", a policy variable
+)
+
+// VersionWithoutVariables is the policy language version that predates
+// policy variables: in its documents "${...}" is literal text.
+const VersionWithoutVariables = "2008-10-17"
+
+// Token is a piece of a glob. For variables Value is the variable name and
+// Default the value to use when the variable is not set.
+type Token struct {
+	Kind    string `json:"kind" yaml:"kind"`
+	Value   string `json:"value,omitempty" yaml:"value,omitempty"`
+	Default string `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// Pattern carries a value from a policy document both as the glob it was
+// written as and as an anchored regular expression.
+type Pattern struct {
+	Glob  string `json:"glob" yaml:"glob"`
+	Regex string `json:"regex" yaml:"regex"`
+}
+
+// Patterns holds the Pattern for every entry of the corresponding Policy
+// fields, in the same order.
+type Patterns struct {
+	Subjects     []Pattern `json:"subjects,omitempty" yaml:"subjects,omitempty"`
+	NotSubjects  []Pattern `json:"not-subjects,omitempty" yaml:"not-subjects,omitempty"`
+	Resources    []Pattern `json:"resources,omitempty" yaml:"resources,omitempty"`
+	NotResources []Pattern `json:"not-resources,omitempty" yaml:"not-resources,omitempty"`
+	Actions      []Pattern `json:"actions,omitempty" yaml:"actions,omitempty"`
+	NotActions   []Pattern `json:"not-actions,omitempty" yaml:"not-actions,omitempty"`
+}
+
+// NewPattern translates glob. When foldCase is set the regular expression
+// matches case-insensitively, as AWS does for action names.
+func NewPattern(glob string, foldCase bool) Pattern {
+	regex := GlobToRegex(glob)
+	if foldCase {
+		regex = "(?i)" + regex
+	}
+	return Pattern{
+		Glob:  glob,
+		Regex: regex,
+	}
+}
+
+// NewPatterns translates every glob in globs and returns the delimited
+// patterns alongside the Pattern list. Both are nil when globs is empty.
+func NewPatterns(globs []string, foldCase bool) ([]string, []Pattern) {
+	return NewVersionPatterns("", globs, foldCase)
+}
+
+// NewVersionPatterns is NewPatterns for the globs of a policy written in
+// the given language version. Each Glob is kept as written, while the
+// delimited form and the Regex read it the way that version does: in a
+// VersionWithoutVariables policy "${...}" is literal text.
+func NewVersionPatterns(version string, globs []string, foldCase bool) ([]string, []Pattern) {
+	if len(globs) == 0 {
+		return globs, nil
+	}
+	delimited := []string{}
+	patterns := []Pattern{}
+	for _, glob := range globs {
+		tokenized := tokenizable(version, glob)
+		pattern := NewPattern(tokenized, foldCase)
+		pattern.Glob = glob
+		delimited = append(delimited, GlobToPattern(tokenized))
+		patterns = append(patterns, pattern)
+	}
+	return delimited, patterns
+}
+
+// Tokenize splits glob into literals, wildcards and policy variables. The
+// escapes ${*}, ${?} and ${$} are literal "*", "?" and "$".
+func Tokenize(glob string) []Token {
+	tokens := []Token{}
+	literal := strings.Builder{}
+	flush := func() {
+		if literal.Len() > 0 {
+			tokens = append(tokens, Token{Kind: TokenLiteral, Value: literal.String()})
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			flush()
+			tokens = append(tokens, Token{Kind: TokenAnyString})
+		case '?':
+			flush()
+			tokens = append(tokens, Token{Kind: TokenAnyChar})
+		case '$':
+			end := strings.IndexByte(glob[i:], '}')
+			if !strings.HasPrefix(glob[i:], "${") || end < 0 {
+				literal.WriteByte(glob[i])
+				continue
+			}
+			body := glob[i+2 : i+end]
+			i += end
+			switch body {
+			case "*", "?", "$":
+				literal.WriteString(body)
+				continue
+			}
+			flush()
+			tokens = append(tokens, parseVariable(body))
+		default:
+			literal.WriteByte(glob[i])
+		}
+	}
+	flush()
+	return tokens
+}
+
+// EscapeVariables returns glob with every "$" escaped, so that the "${...}"
+// of a VersionWithoutVariables document is matched as written.
+func EscapeVariables(glob string) string {
+	return strings.ReplaceAll(glob, "$", "${$}")
+}
+
+// tokenizable returns a glob written in a policy of the given version in
+// the form Tokenize reads.
+func tokenizable(version, glob string) string {
+	if version == VersionWithoutVariables {
+		return EscapeVariables(glob)
+	}
+	return glob
+}
+
+// parseVariable parses the body of ${name} or ${name, 'default'}.
+func parseVariable(body string) Token {
+	t := Token{Kind: TokenVariable, Value: strings.TrimSpace(body)}
+	if comma := strings.IndexByte(body, ','); comma >= 0 {
+		t.Value = strings.TrimSpace(body[:comma])
+		t.Default = strings.Trim(strings.TrimSpace(body[comma+1:]), "'")
+	}
+	return t
+}
+
+// VariableText renders a variable token the way it is written in a policy,
+// which is also the only text an unresolved variable matches.
+func VariableText(t Token) string {
+	if t.Default != "" {
+		return fmt.Sprintf("${%s, '%s'}", t.Value, t.Default)
+	}
+	return fmt.Sprintf("${%s}", t.Value)
+}
+
+// GlobToPattern returns the delimited form used in the Policy fields: literal
+// text is kept as is and wildcards become regular expressions enclosed in
+// < and >, e.g. arn:aws:s3:::bucket/<.*>.
+func GlobToPattern(glob string) string {
+	b := strings.Builder{}
+	for _, t := range Tokenize(glob) {
+		switch t.Kind {
+		case TokenLiteral:
+			b.WriteString(t.Value)
+		case TokenAnyString:
+			b.WriteString("<.*>")
+		case TokenAnyChar:
+			b.WriteString("<.>")
+		case TokenVariable:
+			b.WriteString(VariableText(t))
+		}
+	}
+	return b.String()
+}
+
+// GlobToRegex returns an anchored regular expression matching the same
+// values as glob, with the regex metacharacters of literals escaped.
+func GlobToRegex(glob string) string {
+	b := strings.Builder{}
+	b.WriteString("^")
+	for _, t := range Tokenize(glob) {
+		switch t.Kind {
+		case TokenLiteral:
+			b.WriteString(regexp.QuoteMeta(t.Value))
+		case TokenAnyString:
+			b.WriteString(".*")
+		case TokenAnyChar:
+			b.WriteString(".")
+		case TokenVariable:
+			b.WriteString(regexp.QuoteMeta(VariableText(t)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// MatchGlob reports whether value matches glob. Unresolved variables only
+// match their own text; use Policy.Resolve to substitute them first.
+func MatchGlob(glob, value string) bool {
+	return matchRunes(globRunes(glob), []rune(value))
+}
+
+// wildcard runes stand for the wildcard tokens in a flattened glob; they are
+// taken from the Unicode private use area so they cannot clash with literals.
+const (
+	anyStringRune = '\uE000'
+	anyCharRune   = '\uE001'
+)
+
+func globRunes(glob string) []rune {
+	runes := []rune{}
+	for _, t := range Tokenize(glob) {
+		switch t.Kind {
+		case TokenLiteral:
+			runes = append(runes, []rune(t.Value)...)
+		case TokenAnyString:
+			runes = append(runes, anyStringRune)
+		case TokenAnyChar:
+			runes = append(runes, anyCharRune)
+		case TokenVariable:
+			runes = append(runes, []rune(VariableText(t))...)
+		}
+	}
+	return runes
+}
+
+// matchRunes is the usual linear-backtracking wildcard match: on a mismatch
+// it resumes after the most recent "*", letting it absorb one more rune.
+func matchRunes(pattern, value []rune) bool {
+	p, v := 0, 0
+	star, mark := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && pattern[p] == anyStringRune:
+			star, mark = p, v
+			p++
+		case p < len(pattern) && (pattern[p] == anyCharRune || pattern[p] == value[v]):
+			p++
+			v++
+		case star >= 0:
+			mark++
+			p, v = star+1, mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == anyStringRune {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// GlobContains reports whether every value matched by inner is also matched
+// by outer. Wildcards in inner are treated as symbols that only an equal or
+// wider wildcard in outer can cover, which makes the check sound: a true
+// result is always correct, while a few exotic overlaps, e.g. "a*" in "a*a*",
+// are conservatively reported as not contained.
+func GlobContains(outer, inner string) bool {
+	o, i := globRunes(outer), globRunes(inner)
+
+	// m[x][y]: outer[x:] covers inner[y:]
+	m := make([][]bool, len(o)+1)
+	for x := range m {
+		m[x] = make([]bool, len(i)+1)
+	}
+	m[len(o)][len(i)] = true
+	for x := len(o) - 1; x >= 0; x-- {
+		for y := len(i); y >= 0; y-- {
+			switch {
+			case o[x] == anyStringRune:
+				m[x][y] = m[x+1][y] || (y < len(i) && m[x][y+1])
+			case y == len(i):
+				m[x][y] = false
+			case o[x] == anyCharRune:
+				m[x][y] = i[y] != anyStringRune && m[x+1][y+1]
+			default:
+				m[x][y] = o[x] == i[y] && m[x+1][y+1]
+			}
+		}
+	}
+	return m[0][0]
+}
+
+// GlobsOverlap reports whether some value is matched by both a and b.
+func GlobsOverlap(a, b string) bool {
+	x, y := globRunes(a), globRunes(b)
+
+	// m[i][j]: x[i:] and y[j:] match a common value
+	m := make([][]bool, len(x)+1)
+	for i := range m {
+		m[i] = make([]bool, len(y)+1)
+	}
+	m[len(x)][len(y)] = true
+	for i := len(x); i >= 0; i-- {
+		for j := len(y); j >= 0; j-- {
+			if i == len(x) && j == len(y) {
+				continue
+			}
+			switch {
+			case i < len(x) && x[i] == anyStringRune:
+				m[i][j] = m[i+1][j] || (j < len(y) && m[i][j+1])
+			case j < len(y) && y[j] == anyStringRune:
+				m[i][j] = m[i][j+1] || (i < len(x) && m[i+1][j])
+			case i == len(x) || j == len(y):
+				m[i][j] = false
+			case x[i] == anyCharRune || y[j] == anyCharRune || x[i] == y[j]:
+				m[i][j] = m[i+1][j+1]
+			}
+		}
+	}
+	return m[0][0]
+}
+
+// PatternToGlob reverses GlobToPattern for the wildcards it produces.
+func PatternToGlob(pattern string) string {
+	pattern = strings.ReplaceAll(pattern, "<.*>", "*")
+	return strings.ReplaceAll(pattern, "<.>", "?")
+}
+
+// Globs returns the globs of a policy field, one of the Field* constants,
+// in the form Tokenize and the matching functions read: for a
+// VersionWithoutVariables policy with its "$" escaped. Policies built
+// without Patterns fall back to the delimited strings.
+func (p *Policy) Globs(field string) []string {
+	patterns, delimited := p.field(field)
+	if len(patterns) > 0 {
+		x := []string{}
+		for _, glob := range globs(patterns) {
+			x = append(x, tokenizable(p.Version, glob))
+		}
+		return x
+	}
+	x := []string{}
+	for _, d := range delimited {
+		x = append(x, PatternToGlob(d))
+	}
+	return x
+}
+
+// WrittenGlobs returns the globs of a policy field as written in its
+// document, which is what rendering it back must produce.
+func (p *Policy) WrittenGlobs(field string) []string {
+	if patterns, _ := p.field(field); len(patterns) > 0 {
+		return globs(patterns)
+	}
+	return p.Globs(field)
+}
+
+// field returns the Patterns and the delimited strings of field.
+func (p *Policy) field(field string) ([]Pattern, []string) {
+	var patterns []Pattern
+	var delimited []string
+	switch field {
+	case FieldSubjects:
+		patterns, delimited = p.Patterns.Subjects, p.Subjects
+	case FieldNotSubjects:
+		patterns, delimited = p.Patterns.NotSubjects, p.NotSubjects
+	case FieldResources:
+		patterns, delimited = p.Patterns.Resources, p.Resources
+	case FieldNotResources:
+		patterns, delimited = p.Patterns.NotResources, p.NotResources
+	case FieldActions:
+		patterns, delimited = p.Patterns.Actions, p.Actions
+	case FieldNotActions:
+		patterns, delimited = p.Patterns.NotActions, p.NotActions
+	}
+	return patterns, delimited
+}
+
+// ComparableGlobs returns the globs of field in the form they compare in:
+// lower-cased for the action fields, whose names are case-insensitive.
+func (p *Policy) ComparableGlobs(field string) []string {
+	x := p.Globs(field)
+	if field == FieldActions || field == FieldNotActions {
+		return lowerAll(x)
+	}
+	return x
+}
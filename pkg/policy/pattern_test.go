@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobTranslation(t *testing.T) {
+	tests := []struct {
+		glob      string
+		delimited string
+		regex     string
+		matches   []string
+		misses    []string
+	}{
+		{
+			glob:      "*",
+			delimited: "<.*>",
+			regex:     `^.*$`,
+			matches:   []string{"", "anything"},
+		},
+		{
+			glob:      "arn:aws:s3:::my.bucket/*",
+			delimited: "arn:aws:s3:::my.bucket/<.*>",
+			regex:     `^arn:aws:s3:::my\.bucket/.*$`,
+			matches:   []string{"arn:aws:s3:::my.bucket/", "arn:aws:s3:::my.bucket/a/b.txt"},
+			misses:    []string{"arn:aws:s3:::myxbucket/a", "arn:aws:s3:::my.bucket"},
+		},
+		{
+			glob:      "arn:aws:s3:::logs-20??/*",
+			delimited: "arn:aws:s3:::logs-20<.><.>/<.*>",
+			regex:     `^arn:aws:s3:::logs-20../.*$`,
+			matches:   []string{"arn:aws:s3:::logs-2020/x", "arn:aws:s3:::logs-20ab/"},
+			misses:    []string{"arn:aws:s3:::logs-202/x", "arn:aws:s3:::logs-20201/x"},
+		},
+		{
+			glob:      "arn:aws:iam::*:role/aws-service-role/ec2.application-autoscaling.amazonaws.com/*",
+			delimited: "arn:aws:iam::<.*>:role/aws-service-role/ec2.application-autoscaling.amazonaws.com/<.*>",
+			regex:     `^arn:aws:iam::.*:role/aws-service-role/ec2\.application-autoscaling\.amazonaws\.com/.*$`,
+			matches:   []string{"arn:aws:iam::123456789012:role/aws-service-role/ec2.application-autoscaling.amazonaws.com/x"},
+			misses:    []string{"arn:aws:iam::123456789012:role/aws-service-role/ec2xapplication-autoscaling.amazonaws.com/x"},
+		},
+		{
+			glob:      "arn:aws:sns:us-east-1:123456789012:topic+(1)",
+			delimited: "arn:aws:sns:us-east-1:123456789012:topic+(1)",
+			regex:     `^arn:aws:sns:us-east-1:123456789012:topic\+\(1\)$`,
+			matches:   []string{"arn:aws:sns:us-east-1:123456789012:topic+(1)"},
+			misses:    []string{"arn:aws:sns:us-east-1:123456789012:topicc(1)"},
+		},
+	}
+
+	for _, tt := range tests {
+		assert.EqualValues(t, tt.delimited, GlobToPattern(tt.glob))
+		assert.EqualValues(t, tt.regex, GlobToRegex(tt.glob))
+
+		re := regexp.MustCompile(tt.regex)
+		for _, m := range tt.matches {
+			assert.True(t, MatchGlob(tt.glob, m), "%s ~ %s", tt.glob, m)
+			assert.True(t, re.MatchString(m), "%s ~ %s", tt.regex, m)
+		}
+		for _, m := range tt.misses {
+			assert.False(t, MatchGlob(tt.glob, m), "%s !~ %s", tt.glob, m)
+			assert.False(t, re.MatchString(m), "%s !~ %s", tt.regex, m)
+		}
+	}
+}
+
+func TestNewPattern(t *testing.T) {
+	p := NewPattern("s3:Get*", true)
+	assert.EqualValues(t, "s3:Get*", p.Glob)
+	assert.EqualValues(t, `(?i)^s3:Get.*$`, p.Regex)
+	assert.True(t, regexp.MustCompile(p.Regex).MatchString("S3:GETOBJECT"))
+
+	delimited, patterns := NewPatterns(nil, false)
+	assert.Nil(t, delimited)
+	assert.Nil(t, patterns)
+}
+
+func TestGlobContains(t *testing.T) {
+	tests := []struct {
+		outer, inner string
+		contains     bool
+	}{
+		{"*", "anything*", true},
+		{"s3:*", "s3:Get*", true},
+		{"s3:Get*", "s3:*", false},
+		{"s3:Get*", "s3:GetObject", true},
+		{"s3:GetObject", "s3:Get*", false},
+		{"arn:aws:s3:::b/*", "arn:aws:s3:::b/??/*", true},
+		{"arn:aws:s3:::b/??/*", "arn:aws:s3:::b/*", false},
+		{"a?c", "abc", true},
+		{"a?c", "a*c", false},
+		{"a*b*c", "a*xb*yc", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+	}
+	for _, tt := range tests {
+		assert.EqualValues(t, tt.contains, GlobContains(tt.outer, tt.inner), "%s ⊇ %s", tt.outer, tt.inner)
+	}
+}
+
+func TestGlobsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		overlap bool
+	}{
+		{"s3:Get*", "s3:*Object", true},
+		{"s3:Get*", "s3:Put*", false},
+		{"a?c", "*c", true},
+		{"abc", "abd", false},
+		{"*", "", true},
+		{"a*", "", false},
+	}
+	for _, tt := range tests {
+		assert.EqualValues(t, tt.overlap, GlobsOverlap(tt.a, tt.b), "%s ∩ %s", tt.a, tt.b)
+		assert.EqualValues(t, tt.overlap, GlobsOverlap(tt.b, tt.a), "%s ∩ %s", tt.b, tt.a)
+	}
+}
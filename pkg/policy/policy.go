@@ -1,21 +1,87 @@
 package policy
 
+import "strings"
+
 type Policy struct {
-	Id           string      `json:"id" yaml:"id"`                       // policy Id
-	Version      string      `json:"version" yaml:"version"`             // policy Version
-	Subjects     []string    `json:"subjects" yaml:"subjects"`           // list of subjects included
-	NotSubjects  []string    `json:"not-subjects" yaml:"not-subjects"`   // list of subjects excluded
-	Resources    []string    `json:"resources" yaml:"resources"`         // list of resources included
-	NotResources []string    `json:"not-resources" yaml:"not-resources"` // list of resources excluded
-	Actions      []string    `json:"actions" yaml:"actions"`             // list of actions included
-	NotActions   []string    `json:"not-actions" yaml:"not-actions"`     // list of actions excluded
-	Allowed      bool        `json:"allowed" yaml:"allowed"`             // effect of a policy match
-	Condition    []Condition `json:"conditions" yaml:"conditions"`       // map key is the operator
+	Id           string      `json:"id" yaml:"id"`                                   // policy Id
+	Sid          string      `json:"sid,omitempty" yaml:"sid,omitempty"`             // statement Id
+	Version      string      `json:"version" yaml:"version"`                         // policy Version
+	Subjects     []string    `json:"subjects" yaml:"subjects"`                       // list of subjects included
+	NotSubjects  []string    `json:"not-subjects" yaml:"not-subjects"`               // list of subjects excluded
+	Resources    []string    `json:"resources" yaml:"resources"`                     // list of resources included
+	NotResources []string    `json:"not-resources" yaml:"not-resources"`             // list of resources excluded
+	Actions      []string    `json:"actions" yaml:"actions"`                         // list of actions included
+	NotActions   []string    `json:"not-actions" yaml:"not-actions"`                 // list of actions excluded
+	Allowed      bool        `json:"allowed" yaml:"allowed"`                         // effect of a policy match
+	Condition    []Condition `json:"conditions" yaml:"conditions"`                   // map key is the operator
+	Patterns     Patterns    `json:"patterns" yaml:"patterns"`                       // globs and regular expressions of the fields above
+	Variables    []Variable  `json:"variables,omitempty" yaml:"variables,omitempty"` // policy variables referenced by the policy
+	Kind         string      `json:"kind" yaml:"kind"`                               // one of the Kind* constants
+	Origin       *Origin     `json:"origin,omitempty" yaml:"origin,omitempty"`       // where the policy document came from
+}
+
+// NewStatement returns a statement of kind allowing or denying actions on
+// resources to subjects, with their patterns and variables. Empty lists
+// leave their field unset.
+func NewStatement(id, kind string, allowed bool, subjects, actions, resources []string) *Policy {
+	p := &Policy{Id: id, Kind: kind, Allowed: allowed}
+	p.Subjects, p.Patterns.Subjects = NewPatterns(subjects, false)
+	p.Actions, p.Patterns.Actions = NewPatterns(actions, true)
+	p.Resources, p.Patterns.Resources = NewPatterns(resources, false)
+	p.Variables = CollectVariables(p)
+	return p
 }
 
+// DocumentName returns the name of the document p was parsed from: the
+// name of its origin, or else the document part of its Id.
+func (p *Policy) DocumentName() string {
+	if p.Origin != nil && p.Origin.Name != "" {
+		return p.Origin.Name
+	}
+	if i := strings.LastIndex(p.Id, ":"); i >= 0 {
+		return p.Id[:i]
+	}
+	return p.Id
+}
+
+// Condition is serialized with its values as "values" and their type as
+// "value-type", see values.go.
 type Condition struct {
-	Operation string      `json:"operator" yaml:"operator"`     // condition operator
-	Key       string      `json:"key" yaml:"key"`               // name of the parameter that should match the value
-	Value     interface{} `json:"values" yaml:"values"`         // is a list of either string, int64 or bool
-	Type      string      `json:"value-type" yaml:"value-type"` // string, int64, bool
+	Operation string // condition operator
+	Key       string // name of the parameter that should match the value
+	Value     Values // typed list of values
+}
+
+const (
+	KindIdentity = "identity" // attached to a user, group or role
+	KindResource = "resource" // attached to a resource, names the principals it applies to
+	KindTrust    = "trust"    // names the principals that may assume a role
+	KindSCP      = "scp"      // service control policy, limits the principals of an organization
+	KindRCP      = "rcp"      // resource control policy, limits the resources of an organization
+	KindBoundary = "boundary" // permissions boundary of a user or role
+	KindSession  = "session"  // session policy passed when assuming a role or federating
+)
+
+const (
+	OriginManaged = "managed"
+	OriginInline  = "inline"
+	OriginTrust   = "trust"
+	OriginBucket  = "bucket"
+)
+
+// Origin records the envelope a policy document was extracted from, e.g. the
+// output of `aws iam get-account-authorization-details`.
+type Origin struct {
+	Type       string       `json:"type" yaml:"type"`                                   // managed, inline, trust or bucket
+	Name       string       `json:"name,omitempty" yaml:"name,omitempty"`               // policy name
+	Arn        string       `json:"arn,omitempty" yaml:"arn,omitempty"`                 // policy arn, for managed policies
+	Account    string       `json:"account,omitempty" yaml:"account,omitempty"`         // account owning the policy
+	VersionId  string       `json:"version-id,omitempty" yaml:"version-id,omitempty"`   // policy version, for managed policies
+	AttachedTo []Attachment `json:"attached-to,omitempty" yaml:"attached-to,omitempty"` // identities the policy applies to
+}
+
+type Attachment struct {
+	Type string `json:"type" yaml:"type"`                   // role, user or group
+	Name string `json:"name" yaml:"name"`                   // name of the identity
+	Arn  string `json:"arn,omitempty" yaml:"arn,omitempty"` // arn of the identity
 }
@@ -0,0 +1,440 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	TypeString  = "string"
+	TypeInt64   = "int64"
+	TypeFloat64 = "float64"
+	TypeBool    = "bool"
+	TypeDate    = "date"
+	TypeNull    = "null"
+)
+
+// dateLayouts are the ISO 8601 forms accepted for dates, besides epoch
+// seconds.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseDate parses a date the way the Date* condition operators read it,
+// as epoch seconds or in one of the ISO 8601 forms, and returns it in UTC.
+func ParseDate(s string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date", s)
+}
+
+// Values is the typed list of values of a condition. Exactly one of the
+// typed lists is in use, as reported by Type.
+type Values struct {
+	typ     string
+	strings []string
+	ints    []int64
+	floats  []float64
+	bools   []bool
+	dates   []time.Time
+	nulls   int
+}
+
+func StringValues(v ...string) Values {
+	return Values{typ: TypeString, strings: append([]string{}, v...)}
+}
+
+func Int64Values(v ...int64) Values {
+	return Values{typ: TypeInt64, ints: append([]int64{}, v...)}
+}
+
+func Float64Values(v ...float64) Values {
+	return Values{typ: TypeFloat64, floats: append([]float64{}, v...)}
+}
+
+func BoolValues(v ...bool) Values {
+	return Values{typ: TypeBool, bools: append([]bool{}, v...)}
+}
+
+func DateValues(v ...time.Time) Values {
+	return Values{typ: TypeDate, dates: append([]time.Time{}, v...)}
+}
+
+func NullValues(n int) Values {
+	return Values{typ: TypeNull, nulls: n}
+}
+
+// Type returns one of string, int64, float64, bool, date or null. The zero
+// Values is an empty list of strings.
+func (v Values) Type() string {
+	if v.typ == "" {
+		return TypeString
+	}
+	return v.typ
+}
+
+func (v Values) Len() int {
+	switch v.Type() {
+	case TypeInt64:
+		return len(v.ints)
+	case TypeFloat64:
+		return len(v.floats)
+	case TypeBool:
+		return len(v.bools)
+	case TypeDate:
+		return len(v.dates)
+	case TypeNull:
+		return v.nulls
+	}
+	return len(v.strings)
+}
+
+// Strings returns the values if they are strings, nil otherwise.
+func (v Values) Strings() []string {
+	if v.Type() != TypeString {
+		return nil
+	}
+	return v.strings
+}
+
+// Int64s returns the values if they are int64s, nil otherwise.
+func (v Values) Int64s() []int64 {
+	return v.ints
+}
+
+// Float64s returns the values as float64s if they are numbers, nil otherwise.
+func (v Values) Float64s() []float64 {
+	switch v.Type() {
+	case TypeFloat64:
+		return v.floats
+	case TypeInt64:
+		x := []float64{}
+		for _, i := range v.ints {
+			x = append(x, float64(i))
+		}
+		return x
+	}
+	return nil
+}
+
+// Bools returns the values if they are bools, nil otherwise.
+func (v Values) Bools() []bool {
+	return v.bools
+}
+
+// Dates returns the values if they are dates, nil otherwise.
+func (v Values) Dates() []time.Time {
+	return v.dates
+}
+
+// Text returns every value in its string form, the way it would be written
+// in a policy. Nulls are empty strings.
+func (v Values) Text() []string {
+	x := []string{}
+	switch v.Type() {
+	case TypeString:
+		x = append(x, v.strings...)
+	case TypeInt64:
+		for _, i := range v.ints {
+			x = append(x, strconv.FormatInt(i, 10))
+		}
+	case TypeFloat64:
+		for _, f := range v.floats {
+			x = append(x, strconv.FormatFloat(f, 'f', -1, 64))
+		}
+	case TypeBool:
+		for _, b := range v.bools {
+			x = append(x, strconv.FormatBool(b))
+		}
+	case TypeDate:
+		for _, d := range v.dates {
+			x = append(x, d.Format(time.RFC3339Nano))
+		}
+	case TypeNull:
+		for i := 0; i < v.nulls; i++ {
+			x = append(x, "")
+		}
+	}
+	return x
+}
+
+// list returns the values as a slice of their Go types, for marshalling.
+func (v Values) list() interface{} {
+	switch v.Type() {
+	case TypeInt64:
+		return v.ints
+	case TypeFloat64:
+		return v.floats
+	case TypeBool:
+		return v.bools
+	case TypeDate:
+		return v.Text()
+	case TypeNull:
+		return make([]interface{}, v.nulls)
+	}
+	if v.strings == nil {
+		return []string{}
+	}
+	return v.strings
+}
+
+// MarshalJSON writes the values as a plain JSON list; the type travels next
+// to it as the value-type of the condition.
+func (v Values) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.list())
+}
+
+// UnmarshalJSON reads a plain JSON list, typing it by its JSON types. Dates
+// are only recognized when the type is known, see Condition.UnmarshalJSON.
+func (v *Values) UnmarshalJSON(b []byte) error {
+	var raw []interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return err
+	}
+	values, err := valuesOf(raw, "")
+	if err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
+
+func (v Values) MarshalYAML() (interface{}, error) {
+	return v.list(), nil
+}
+
+func (v *Values) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw []interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	values, err := valuesOf(raw, "")
+	if err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
+
+// conditionDoc is the serialized form of a Condition.
+type conditionDoc struct {
+	Operation string      `json:"operator" yaml:"operator"`
+	Key       string      `json:"key" yaml:"key"`
+	Value     interface{} `json:"values" yaml:"values"`
+	Type      string      `json:"value-type" yaml:"value-type"`
+}
+
+func (c Condition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(conditionDoc{
+		Operation: c.Operation,
+		Key:       c.Key,
+		Value:     c.Value.list(),
+		Type:      c.Value.Type(),
+	})
+}
+
+func (c *Condition) UnmarshalJSON(b []byte) error {
+	doc := struct {
+		Operation string          `json:"operator"`
+		Key       string          `json:"key"`
+		Value     json.RawMessage `json:"values"`
+		Type      string          `json:"value-type"`
+	}{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	raw := []interface{}{}
+	if len(doc.Value) > 0 {
+		d := json.NewDecoder(bytes.NewReader(doc.Value))
+		d.UseNumber()
+		if err := d.Decode(&raw); err != nil {
+			return err
+		}
+	}
+	values, err := valuesOf(raw, doc.Type)
+	if err != nil {
+		return fmt.Errorf("condition %s on %s: %s", doc.Operation, doc.Key, err.Error())
+	}
+	c.Operation, c.Key, c.Value = doc.Operation, doc.Key, values
+	return nil
+}
+
+func (c Condition) MarshalYAML() (interface{}, error) {
+	return conditionDoc{
+		Operation: c.Operation,
+		Key:       c.Key,
+		Value:     c.Value.list(),
+		Type:      c.Value.Type(),
+	}, nil
+}
+
+func (c *Condition) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	doc := struct {
+		Operation string        `yaml:"operator"`
+		Key       string        `yaml:"key"`
+		Value     []interface{} `yaml:"values"`
+		Type      string        `yaml:"value-type"`
+	}{}
+	if err := unmarshal(&doc); err != nil {
+		return err
+	}
+	values, err := valuesOf(doc.Value, doc.Type)
+	if err != nil {
+		return fmt.Errorf("condition %s on %s: %s", doc.Operation, doc.Key, err.Error())
+	}
+	c.Operation, c.Key, c.Value = doc.Operation, doc.Key, values
+	return nil
+}
+
+// valuesOf converts decoded JSON or YAML values to Values of type typ. When
+// typ is empty it is taken from the first value.
+func valuesOf(raw []interface{}, typ string) (Values, error) {
+	if typ == "" {
+		typ = TypeString
+		if len(raw) > 0 {
+			typ = typeOf(raw[0])
+		}
+	}
+
+	switch typ {
+	case TypeString:
+		x := []string{}
+		for _, r := range raw {
+			s, ok := r.(string)
+			if !ok {
+				return Values{}, fmt.Errorf("%v is not a string", r)
+			}
+			x = append(x, s)
+		}
+		return StringValues(x...), nil
+	case TypeInt64:
+		x := []int64{}
+		for _, r := range raw {
+			i, err := toInt64(r)
+			if err != nil {
+				return Values{}, err
+			}
+			x = append(x, i)
+		}
+		return Int64Values(x...), nil
+	case TypeFloat64:
+		x := []float64{}
+		for _, r := range raw {
+			f, err := toFloat64(r)
+			if err != nil {
+				return Values{}, err
+			}
+			x = append(x, f)
+		}
+		return Float64Values(x...), nil
+	case TypeBool:
+		x := []bool{}
+		for _, r := range raw {
+			b, ok := r.(bool)
+			if !ok {
+				return Values{}, fmt.Errorf("%v is not a bool", r)
+			}
+			x = append(x, b)
+		}
+		return BoolValues(x...), nil
+	case TypeDate:
+		x := []time.Time{}
+		for _, r := range raw {
+			switch d := r.(type) {
+			case time.Time:
+				x = append(x, d)
+			case string:
+				t, err := time.Parse(time.RFC3339Nano, d)
+				if err != nil {
+					return Values{}, err
+				}
+				x = append(x, t)
+			default:
+				return Values{}, fmt.Errorf("%v is not a date", r)
+			}
+		}
+		return DateValues(x...), nil
+	case TypeNull:
+		for _, r := range raw {
+			if r != nil {
+				return Values{}, fmt.Errorf("%v is not null", r)
+			}
+		}
+		return NullValues(len(raw)), nil
+	}
+	return Values{}, fmt.Errorf("%s is not a value type", typ)
+}
+
+func typeOf(r interface{}) string {
+	switch x := r.(type) {
+	case bool:
+		return TypeBool
+	case nil:
+		return TypeNull
+	case time.Time:
+		return TypeDate
+	case int, int64, uint64:
+		return TypeInt64
+	case float64:
+		if x == float64(int64(x)) {
+			return TypeInt64
+		}
+		return TypeFloat64
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return TypeInt64
+		}
+		return TypeFloat64
+	}
+	return TypeString
+}
+
+func toInt64(r interface{}) (int64, error) {
+	switch x := r.(type) {
+	case int:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case uint64:
+		return int64(x), nil
+	case float64:
+		if x == float64(int64(x)) {
+			return int64(x), nil
+		}
+	case json.Number:
+		return x.Int64()
+	}
+	return 0, fmt.Errorf("%v is not an int64", r)
+}
+
+func toFloat64(r interface{}) (float64, error) {
+	switch x := r.(type) {
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case json.Number:
+		return x.Float64()
+	}
+	return 0, fmt.Errorf("%v is not a float64", r)
+}
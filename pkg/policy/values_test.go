@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func conditions() []Condition {
+	date := time.Date(2020, 1, 1, 12, 30, 0, 500, time.UTC)
+	return []Condition{
+		{Operation: "StringEquals", Key: "aws:PrincipalTag/team", Value: StringValues("blue", "10")},
+		{Operation: "NumericLessThan", Key: "s3:max-keys", Value: Int64Values(10, -2)},
+		{Operation: "NumericGreaterThan", Key: "s3:min-keys", Value: Float64Values(10.5, 3)},
+		{Operation: "Bool", Key: "aws:SecureTransport", Value: BoolValues(true, false)},
+		{Operation: "DateLessThan", Key: "aws:CurrentTime", Value: DateValues(date)},
+		{Operation: "Null", Key: "aws:TokenIssueTime", Value: NullValues(1)},
+		{Operation: "StringLike", Key: "s3:prefix", Value: StringValues()},
+	}
+}
+
+func TestCondition_JsonRoundTrip(t *testing.T) {
+	for _, c := range conditions() {
+		b, err := json.Marshal(c)
+		assert.Nil(t, err)
+
+		rt := Condition{}
+		assert.Nil(t, json.Unmarshal(b, &rt), string(b))
+		assert.EqualValues(t, c, rt, string(b))
+		assert.EqualValues(t, c.Value.Type(), rt.Value.Type())
+		assert.EqualValues(t, c.Value.Len(), rt.Value.Len())
+	}
+
+	b, err := json.Marshal(conditions()[2])
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"operator": "NumericGreaterThan", "key": "s3:min-keys", "values": [10.5, 3], "value-type": "float64"}`, string(b))
+
+	rt := Condition{}
+	assert.NotNil(t, json.Unmarshal([]byte(`{"operator": "Bool", "key": "k", "values": ["yes"], "value-type": "bool"}`), &rt))
+}
+
+func TestCondition_YamlRoundTrip(t *testing.T) {
+	for _, c := range conditions() {
+		b, err := yaml.Marshal(c)
+		assert.Nil(t, err)
+
+		rt := Condition{}
+		assert.Nil(t, yaml.Unmarshal(b, &rt), string(b))
+		assert.EqualValues(t, c, rt, string(b))
+	}
+}
+
+func TestValues_Accessors(t *testing.T) {
+	v := Int64Values(1, 2)
+	assert.EqualValues(t, TypeInt64, v.Type())
+	assert.EqualValues(t, []int64{1, 2}, v.Int64s())
+	assert.EqualValues(t, []float64{1, 2}, v.Float64s())
+	assert.Nil(t, v.Strings())
+	assert.Nil(t, v.Bools())
+	assert.EqualValues(t, []string{"1", "2"}, v.Text())
+
+	zero := Values{}
+	assert.EqualValues(t, TypeString, zero.Type())
+	assert.EqualValues(t, 0, zero.Len())
+
+	p := []*Policy{{Id: "p:0", Condition: conditions()}}
+	b, err := json.Marshal(p)
+	assert.Nil(t, err)
+	rt := []*Policy{}
+	assert.Nil(t, json.Unmarshal(b, &rt))
+	assert.EqualValues(t, p[0].Condition, rt[0].Condition)
+}
+
+func TestParseDate(t *testing.T) {
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{"1577836800", "2020-01-01", "2020-01-01T00:00", "2020-01-01T01:00+01:00", "2020-01-01T00:00:00Z"} {
+		d, err := ParseDate(s)
+		assert.Nil(t, err, s)
+		assert.EqualValues(t, date, d, s)
+	}
+	_, err := ParseDate("yesterday")
+	assert.NotNil(t, err)
+}
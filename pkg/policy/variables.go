@@ -0,0 +1,238 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	FieldSubjects     = "subjects"
+	FieldNotSubjects  = "not-subjects"
+	FieldResources    = "resources"
+	FieldNotResources = "not-resources"
+	FieldActions      = "actions"
+	FieldNotActions   = "not-actions"
+	FieldConditions   = "conditions"
+)
+
+// Variable is a policy variable, e.g. ${aws:username}, referenced by a policy.
+type Variable struct {
+	Name    string `json:"name" yaml:"name"`                           // e.g. aws:username or aws:PrincipalTag/team
+	Default string `json:"default,omitempty" yaml:"default,omitempty"` // value used when the variable is not set
+	Field   string `json:"field" yaml:"field"`                         // policy field the variable appears in
+}
+
+// UnresolvedError is returned by Resolve when a variable has neither a value
+// nor a default.
+type UnresolvedError struct {
+	Names []string
+}
+
+func (u *UnresolvedError) Error() string {
+	return fmt.Sprintf("unresolved policy variables: %s", strings.Join(u.Names, ", "))
+}
+
+// CollectVariables returns the variables referenced by p, once per field.
+// A VersionWithoutVariables policy references none.
+func CollectVariables(p *Policy) []Variable {
+	if p.Version == VersionWithoutVariables {
+		return nil
+	}
+	vars := []Variable{}
+	seen := map[Variable]bool{}
+	add := func(field string, globs ...string) {
+		for _, glob := range globs {
+			for _, t := range Tokenize(glob) {
+				if t.Kind != TokenVariable {
+					continue
+				}
+				v := Variable{Name: t.Value, Default: t.Default, Field: field}
+				if !seen[v] {
+					seen[v] = true
+					vars = append(vars, v)
+				}
+			}
+		}
+	}
+
+	add(FieldSubjects, globs(p.Patterns.Subjects)...)
+	add(FieldNotSubjects, globs(p.Patterns.NotSubjects)...)
+	add(FieldResources, globs(p.Patterns.Resources)...)
+	add(FieldNotResources, globs(p.Patterns.NotResources)...)
+	add(FieldActions, globs(p.Patterns.Actions)...)
+	add(FieldNotActions, globs(p.Patterns.NotActions)...)
+	for _, c := range p.Condition {
+		add(FieldConditions, c.Value.Strings()...)
+	}
+
+	if len(vars) == 0 {
+		return nil
+	}
+	return vars
+}
+
+// Resolve returns a copy of p with every policy variable replaced by its
+// value from vars, or its default. Substituted values are literal: a "*" in
+// a value does not act as a wildcard. If a variable cannot be resolved the
+// copy is still returned, with the variable left in place, together with an
+// *UnresolvedError. A VersionWithoutVariables policy is returned as is.
+func (p *Policy) Resolve(vars map[string]string) (*Policy, error) {
+	r := *p
+	if p.Version == VersionWithoutVariables {
+		return &r, nil
+	}
+	unresolved := map[string]bool{}
+
+	resolve := func(globs []Pattern) ([]string, []Pattern) {
+		if len(globs) == 0 {
+			return nil, nil
+		}
+		resolved := []string{}
+		for _, g := range globs {
+			resolved = append(resolved, resolveGlob(g.Glob, vars, unresolved))
+		}
+		return NewPatterns(resolved, strings.HasPrefix(globs[0].Regex, "(?i)"))
+	}
+
+	if len(p.Patterns.Subjects) > 0 {
+		r.Subjects, r.Patterns.Subjects = resolve(p.Patterns.Subjects)
+	}
+	if len(p.Patterns.NotSubjects) > 0 {
+		r.NotSubjects, r.Patterns.NotSubjects = resolve(p.Patterns.NotSubjects)
+	}
+	if len(p.Patterns.Resources) > 0 {
+		r.Resources, r.Patterns.Resources = resolve(p.Patterns.Resources)
+	}
+	if len(p.Patterns.NotResources) > 0 {
+		r.NotResources, r.Patterns.NotResources = resolve(p.Patterns.NotResources)
+	}
+	if len(p.Patterns.Actions) > 0 {
+		r.Actions, r.Patterns.Actions = resolve(p.Patterns.Actions)
+	}
+	if len(p.Patterns.NotActions) > 0 {
+		r.NotActions, r.Patterns.NotActions = resolve(p.Patterns.NotActions)
+	}
+
+	if p.Condition != nil {
+		r.Condition = []Condition{}
+		for _, c := range p.Condition {
+			if c.Value.Type() == TypeString {
+				resolved := []string{}
+				for _, v := range c.Value.Strings() {
+					resolved = append(resolved, resolveValue(v, vars, unresolved))
+				}
+				c.Value = StringValues(resolved...)
+			}
+			r.Condition = append(r.Condition, c)
+		}
+	}
+
+	r.Variables = CollectVariables(&r)
+
+	if len(unresolved) > 0 {
+		names := []string{}
+		for name := range unresolved {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return &r, &UnresolvedError{Names: names}
+	}
+	return &r, nil
+}
+
+// EscapeGlob returns a glob matching exactly s.
+func EscapeGlob(s string) string {
+	b := strings.Builder{}
+	for _, r := range s {
+		switch r {
+		case '*', '?', '$':
+			b.WriteString("${" + string(r) + "}")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func lookupVariable(t Token, vars map[string]string) (string, bool) {
+	if v, ok := vars[t.Value]; ok {
+		return v, true
+	}
+	// context keys are case-insensitive; of the keys differing only in
+	// case, take the first in sort order so the result does not depend on
+	// the order of the map
+	keys := []string{}
+	for k := range vars {
+		if strings.EqualFold(k, t.Value) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return vars[keys[0]], true
+	}
+	if t.Default != "" {
+		return t.Default, true
+	}
+	return "", false
+}
+
+func resolveGlob(glob string, vars map[string]string, unresolved map[string]bool) string {
+	b := strings.Builder{}
+	for _, t := range Tokenize(glob) {
+		switch t.Kind {
+		case TokenLiteral:
+			b.WriteString(EscapeGlob(t.Value))
+		case TokenAnyString:
+			b.WriteString("*")
+		case TokenAnyChar:
+			b.WriteString("?")
+		case TokenVariable:
+			v, ok := lookupVariable(t, vars)
+			if !ok {
+				unresolved[t.Value] = true
+				b.WriteString(VariableText(t))
+				continue
+			}
+			b.WriteString(EscapeGlob(v))
+		}
+	}
+	return b.String()
+}
+
+// resolveValue substitutes variables in a condition value. Condition values
+// are plain strings, so wildcards and escapes are written out as text.
+func resolveValue(value string, vars map[string]string, unresolved map[string]bool) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	b := strings.Builder{}
+	for _, t := range Tokenize(value) {
+		switch t.Kind {
+		case TokenLiteral:
+			b.WriteString(t.Value)
+		case TokenAnyString:
+			b.WriteString("*")
+		case TokenAnyChar:
+			b.WriteString("?")
+		case TokenVariable:
+			v, ok := lookupVariable(t, vars)
+			if !ok {
+				unresolved[t.Value] = true
+				b.WriteString(VariableText(t))
+				continue
+			}
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
+
+func globs(patterns []Pattern) []string {
+	x := []string{}
+	for _, p := range patterns {
+		x = append(x, p.Glob)
+	}
+	return x
+}
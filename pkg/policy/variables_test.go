@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize_Variables(t *testing.T) {
+	tokens := Tokenize("arn:aws:s3:::home/${aws:username}/${aws:PrincipalTag/team, 'none'}/${*}${?}${$}*")
+	assert.EqualValues(t, []Token{
+		{Kind: TokenLiteral, Value: "arn:aws:s3:::home/"},
+		{Kind: TokenVariable, Value: "aws:username"},
+		{Kind: TokenLiteral, Value: "/"},
+		{Kind: TokenVariable, Value: "aws:PrincipalTag/team", Default: "none"},
+		{Kind: TokenLiteral, Value: "/*?$"},
+		{Kind: TokenAnyString},
+	}, tokens)
+
+	assert.EqualValues(t, `^arn:aws:s3:::file\*$`, GlobToRegex("arn:aws:s3:::file${*}"))
+	assert.EqualValues(t, `^home/\$\{aws:username\}/.*$`, GlobToRegex("home/${aws:username}/*"))
+	assert.False(t, MatchGlob("file${*}", "file1"))
+	assert.True(t, MatchGlob("file${*}", "file*"))
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	p := &Policy{
+		Condition: []Condition{
+			{
+				Operation: "StringEquals",
+				Key:       "aws:PrincipalTag/team",
+				Value:     StringValues("${aws:ResourceTag/team}"),
+			},
+		},
+	}
+	p.Resources, p.Patterns.Resources = NewPatterns([]string{
+		"arn:aws:s3:::home/${aws:username}/*",
+		"arn:aws:s3:::shared/${aws:PrincipalTag/team, 'public'}/*",
+	}, false)
+	p.Actions, p.Patterns.Actions = NewPatterns([]string{"s3:Get*"}, true)
+	p.Variables = CollectVariables(p)
+
+	assert.EqualValues(t, []Variable{
+		{Name: "aws:username", Field: FieldResources},
+		{Name: "aws:PrincipalTag/team", Default: "public", Field: FieldResources},
+		{Name: "aws:ResourceTag/team", Field: FieldConditions},
+	}, p.Variables)
+
+	r, err := p.Resolve(map[string]string{
+		"aws:username":         "bob*",
+		"aws:resourcetag/team": "blue",
+	})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"arn:aws:s3:::home/bob*/<.*>", "arn:aws:s3:::shared/public/<.*>"}, r.Resources)
+	assert.EqualValues(t, "arn:aws:s3:::home/bob${*}/*", r.Patterns.Resources[0].Glob)
+	assert.True(t, MatchGlob(r.Patterns.Resources[0].Glob, "arn:aws:s3:::home/bob*/notes.txt"))
+	assert.False(t, MatchGlob(r.Patterns.Resources[0].Glob, "arn:aws:s3:::home/bobby/notes.txt"))
+	assert.EqualValues(t, []string{"blue"}, r.Condition[0].Value.Strings())
+	assert.EqualValues(t, "(?i)^s3:Get.*$", r.Patterns.Actions[0].Regex)
+	assert.Nil(t, r.Variables)
+
+	// the original is left untouched
+	assert.EqualValues(t, []string{"${aws:ResourceTag/team}"}, p.Condition[0].Value.Strings())
+
+	r, err = p.Resolve(map[string]string{})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, []string{"aws:ResourceTag/team", "aws:username"}, err.(*UnresolvedError).Names)
+	assert.EqualValues(t, "arn:aws:s3:::home/${aws:username}/<.*>", r.Resources[0])
+}
+
+func TestPolicy_ResolveCaseInsensitive(t *testing.T) {
+	p := &Policy{}
+	p.Resources, p.Patterns.Resources = NewPatterns([]string{"arn:aws:s3:::home/${aws:username}/*"}, false)
+
+	for i := 0; i < 20; i++ {
+		r, err := p.Resolve(map[string]string{"AWS:UserName": "alice", "aws:USERNAME": "bob"})
+		assert.Nil(t, err)
+		assert.EqualValues(t, "arn:aws:s3:::home/alice/<.*>", r.Resources[0])
+	}
+}
+
+func TestPolicy_VersionWithoutVariables(t *testing.T) {
+	p := &Policy{
+		Version:   VersionWithoutVariables,
+		Condition: []Condition{{Operation: "StringEquals", Key: "s3:prefix", Value: StringValues("${aws:username}")}},
+	}
+	p.Resources, p.Patterns.Resources = NewVersionPatterns(p.Version, []string{"arn:aws:s3:::home/${aws:username}/*"}, false)
+
+	assert.Nil(t, CollectVariables(p))
+	assert.True(t, p.MatchesResource("arn:aws:s3:::home/${aws:username}/notes.txt"))
+	r, err := p.Resolve(map[string]string{"aws:username": "alice"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, p.Patterns.Resources, r.Patterns.Resources)
+	assert.EqualValues(t, []string{"${aws:username}"}, r.Condition[0].Value.Strings())
+}
@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aumahesh/policyparser/pkg/parser"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+//go:generate sh -c "go run ../../cmd schema > envelope.schema.json"
+
+// Draft is the JSON Schema dialect of the generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema, as it is marshaled to JSON.
+type Schema map[string]interface{}
+
+// Schemer is implemented by types whose JSON encoding differs from their Go
+// structure, e.g. because they implement json.Marshaler; JSONSchema returns
+// the schema of that encoding.
+type Schemer interface {
+	JSONSchema() map[string]interface{}
+}
+
+var schemerType = reflect.TypeOf((*Schemer)(nil)).Elem()
+
+// Generate returns the schema of the JSON encoding of values of type t, as
+// encoding/json marshals them. Struct types are defined once under $defs,
+// by their Go name, and referenced from where they are used.
+func Generate(t reflect.Type) Schema {
+	g := &generator{defs: map[string]interface{}{}}
+	s := g.schema(t)
+	s["$schema"] = Draft
+	if len(g.defs) > 0 {
+		s["$defs"] = g.defs
+	}
+	return s
+}
+
+// Envelope returns the schema of a policy.Envelope, the output format of the
+// parser. Its format-version only admits the major version of
+// policy.FormatVersion, so consumers reject outputs they cannot read.
+func Envelope() Schema {
+	s := Generate(reflect.TypeOf(policy.Envelope{}))
+	s["$id"] = "https://github.com/aumahesh/policyparser/schema/envelope/v" + Major(policy.FormatVersion)
+	s["title"] = "Parsed policies"
+
+	def := s["$defs"].(map[string]interface{})["Envelope"].(Schema)
+	properties := def["properties"].(map[string]interface{})
+	properties["format-version"] = Schema{
+		"type":    "string",
+		"pattern": "^" + Major(policy.FormatVersion) + `\.[0-9]+$`,
+	}
+	properties["provider"] = Schema{
+		"type": "string",
+		"enum": []interface{}{parser.Aws, parser.Azure, parser.Gcp},
+	}
+	return s
+}
+
+// Major returns the major version of a format version, e.g. 1 for 1.2.
+func Major(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
+}
+
+type generator struct {
+	defs map[string]interface{}
+}
+
+func (g *generator) schema(t reflect.Type) Schema {
+	if t.Implements(schemerType) {
+		return Schema(reflect.Zero(t).Interface().(Schemer).JSONSchema())
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.nullable(g.schema(t.Elem()))
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			return Schema{"type": []interface{}{"string", "null"}}
+		}
+		return Schema{"type": []interface{}{"array", "null"}, "items": g.schema(t.Elem())}
+	case reflect.Array:
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": []interface{}{"object", "null"}, "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.reference(t)
+	}
+	// interfaces hold any value
+	return Schema{}
+}
+
+// nullable admits null besides the values of s, which pointers encode nil as.
+func (g *generator) nullable(s Schema) Schema {
+	if _, ok := s["$ref"]; ok {
+		return Schema{"anyOf": []interface{}{s, Schema{"type": "null"}}}
+	}
+	switch x := s["type"].(type) {
+	case string:
+		s["type"] = []interface{}{x, "null"}
+	case []interface{}:
+		for _, name := range x {
+			if name == "null" {
+				return s
+			}
+		}
+		s["type"] = append(x, "null")
+	}
+	return s
+}
+
+func (g *generator) reference(t reflect.Type) Schema {
+	name := t.Name()
+	if name == "" {
+		return g.object(t)
+	}
+	if _, ok := g.defs[name]; !ok {
+		g.defs[name] = nil // recursive types refer to themselves
+		g.defs[name] = g.object(t)
+	}
+	return Schema{"$ref": "#/$defs/" + name}
+}
+
+func (g *generator) object(t reflect.Type) Schema {
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts := f.Name, ""
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			name, opts = tag, ""
+			if i := strings.Index(tag, ","); i >= 0 {
+				name, opts = tag[:i], tag[i:]
+			}
+			if name == "" {
+				name = f.Name
+			}
+		}
+		properties[name] = g.schema(f.Type)
+		if !strings.Contains(opts, ",omitempty") {
+			required = append(required, name)
+		}
+	}
+	return Schema{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
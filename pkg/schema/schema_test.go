@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/decoder"
+	"github.com/aumahesh/policyparser/pkg/parser"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+const document = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Sid": "Read",
+		"Effect": "Allow",
+		"Principal": {"AWS": "arn:aws:iam::123456789012:role/${aws:username}"},
+		"Action": ["s3:GetObject", "s3:ListBucket"],
+		"NotResource": "arn:aws:s3:::secret/*",
+		"Condition": {
+			"NumericLessThan": {"s3:max-keys": "10"},
+			"DateGreaterThan": {"aws:CurrentTime": "2020-01-01T00:00:00Z"},
+			"Bool": {"aws:SecureTransport": "true"}
+		}
+	}]
+}`
+
+func parsed(t *testing.T) []byte {
+	p, err := parser.NewParser(parser.Aws, document, decoder.FromEscaped(false), false)
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Nil(t, p.Parse())
+	policies, err := p.GetPolicy()
+	assert.Nil(t, err)
+	policies[0].Origin = &policy.Origin{Type: policy.OriginManaged, AttachedTo: []policy.Attachment{{Type: "role", Name: "reader"}}}
+
+	data, err := json.Marshal(policy.NewEnvelope(parser.Aws, policies))
+	assert.Nil(t, err)
+	return data
+}
+
+func TestEnvelope_UpToDate(t *testing.T) {
+	published, err := ioutil.ReadFile("envelope.schema.json")
+	assert.Nil(t, err)
+	generated, err := json.MarshalIndent(Envelope(), "", "  ")
+	assert.Nil(t, err)
+	assert.EqualValues(t, string(generated)+"\n", string(published), "run go generate ./pkg/schema")
+}
+
+func TestValidate(t *testing.T) {
+	data := parsed(t)
+	assert.Empty(t, Validate(data), string(data))
+
+	// the published schema, as consumers read it, accepts it too
+	published, err := ioutil.ReadFile("envelope.schema.json")
+	assert.Nil(t, err)
+	s := Schema{}
+	assert.Nil(t, json.Unmarshal(published, &s))
+	assert.Empty(t, s.Validate(data))
+
+	// and it reads back
+	e := &policy.Envelope{}
+	assert.Nil(t, json.Unmarshal(data, e))
+	assert.EqualValues(t, policy.FormatVersion, e.FormatVersion)
+	assert.Len(t, e.Policies, 1)
+}
+
+func TestValidate_Errors(t *testing.T) {
+	data := string(parsed(t))
+	tests := []struct {
+		old, new string
+		errors   []string
+	}{
+		{`"provider":"aws"`, `"provider":"oracle"`, []string{`/provider: oracle is not one of [aws azure gcp]`}},
+		{`"allowed":true`, `"allowed":"true"`, []string{`/policies/0/allowed: expected boolean, got string`}},
+		{`"sid":"Read",`, `"sid":"Read","effect":"Allow",`, []string{`/policies/0/effect: unknown property`}},
+		{`"kind":"resource",`, ``, []string{`/policies/0: missing property kind`}},
+		{`"value-type":"int64"`, `"value-type":"integer"`, []string{`/policies/0/conditions/0/value-type: integer is not one of [string int64 float64 bool date null]`}},
+		{`"glob":`, `"pattern":`, []string{
+			`/policies/0/patterns/subjects/0: missing property glob`,
+			`/policies/0/patterns/subjects/0/pattern: unknown property`,
+		}},
+		{`"format-version":"1.0"`, `"format-version":"1.x"`, []string{`/format-version: "1.x" does not match ^1\.[0-9]+$`}},
+		{`"format-version":"1.0"`, `"format-version":"2.0"`, []string{`/format-version: unsupported format version 2.0, want 1.x`}},
+		{`"format-version":"1.0",`, ``, []string{`/: missing property format-version`}},
+	}
+	for _, test := range tests {
+		assert.Contains(t, data, test.old)
+		errs := Validate([]byte(strings.Replace(data, test.old, test.new, 1)))
+		messages := []string{}
+		for _, e := range errs {
+			messages = append(messages, e.Error())
+		}
+		assert.EqualValues(t, test.errors, messages, test.new)
+	}
+
+	assert.NotEmpty(t, Validate([]byte(`{"format-version": "1.0"`)))
+	assert.NotEmpty(t, Validate([]byte(`{} {}`)))
+}
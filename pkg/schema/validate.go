@@ -0,0 +1,255 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Error is a value that does not match its schema, at Path, a JSON pointer
+// into the validated document.
+type Error struct {
+	Path    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// Validate checks that data is parsed policies in a format version this
+// package can read, and returns every mismatch with the Envelope schema.
+func Validate(data []byte) []error {
+	var head struct {
+		FormatVersion *string `json:"format-version"`
+	}
+	if err := json.Unmarshal(data, &head); err == nil && head.FormatVersion != nil {
+		if Major(*head.FormatVersion) != Major(policy.FormatVersion) {
+			return []error{&Error{
+				Path:    "/format-version",
+				Message: fmt.Sprintf("unsupported format version %s, want %s.x", *head.FormatVersion, Major(policy.FormatVersion)),
+			}}
+		}
+	}
+	return Envelope().Validate(data)
+}
+
+// Validate checks the JSON document data against s. It supports the keywords
+// Generate emits: $ref into $defs, anyOf, type, enum, pattern, properties,
+// required, additionalProperties and items.
+func (s Schema) Validate(data []byte) []error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return []error{err}
+	}
+	if d.More() {
+		return []error{fmt.Errorf("unexpected data after the document")}
+	}
+	c := &checker{root: s}
+	c.check("", map[string]interface{}(s), v)
+	return c.errs
+}
+
+type checker struct {
+	root Schema
+	errs []error
+	// at is the path checked against a schema of anyOf, and mismatch is set
+	// if its value is not of the type of that schema
+	at       string
+	mismatch bool
+}
+
+func (c *checker) fail(path string, format string, args ...interface{}) {
+	c.errs = append(c.errs, &Error{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) check(path string, s map[string]interface{}, v interface{}) {
+	if ref, ok := s["$ref"].(string); ok {
+		def, ok := c.resolve(ref)
+		if !ok {
+			c.fail(path, "unknown reference %s", ref)
+			return
+		}
+		c.check(path, def, v)
+	}
+
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		// report the errors of the one schema of the type of v, if any,
+		// rather than that none matches
+		matched := false
+		var candidates []*checker
+		for _, x := range anyOf {
+			sub := &checker{root: c.root, at: path}
+			sub.check(path, object(x), v)
+			if len(sub.errs) == 0 {
+				matched = true
+				break
+			}
+			if !sub.mismatch {
+				candidates = append(candidates, sub)
+			}
+		}
+		if !matched && len(candidates) == 1 {
+			c.errs = append(c.errs, candidates[0].errs...)
+		} else if !matched {
+			c.fail(path, "matches none of the allowed schemas")
+		}
+	}
+
+	if t, ok := s["type"]; ok && !hasType(t, v) {
+		c.fail(path, "expected %s, got %s", typeNames(t), typeOf(v))
+		c.mismatch = c.mismatch || path == c.at
+		return
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, x := range enum {
+			if fmt.Sprint(x) == fmt.Sprint(v) && typeOf(x) == typeOf(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.fail(path, "%v is not one of %v", v, enum)
+		}
+	}
+
+	if pattern, ok := s["pattern"].(string); ok {
+		if x, ok := v.(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				c.fail(path, "invalid pattern %s: %s", pattern, err.Error())
+			} else if !re.MatchString(x) {
+				c.fail(path, "%q does not match %s", x, pattern)
+			}
+		}
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		c.object(path, s, x)
+	case []interface{}:
+		if items, ok := s["items"]; ok {
+			for i, item := range x {
+				c.check(fmt.Sprintf("%s/%d", path, i), object(items), item)
+			}
+		}
+	}
+}
+
+func (c *checker) object(path string, s map[string]interface{}, v map[string]interface{}) {
+	properties := object(s["properties"])
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				c.fail(path, "missing property %s", name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		at := path + "/" + escape(name)
+		if p, ok := properties[name]; ok {
+			c.check(at, object(p), v[name])
+			continue
+		}
+		switch extra := s["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				c.fail(at, "unknown property")
+			}
+		case nil:
+		default:
+			c.check(at, object(extra), v[name])
+		}
+	}
+}
+
+func (c *checker) resolve(ref string) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(ref, "#/$defs/") {
+		return nil, false
+	}
+	def, ok := object(c.root["$defs"])[strings.TrimPrefix(ref, "#/$defs/")]
+	return object(def), ok && def != nil
+}
+
+// object returns the schema x, generated or decoded from JSON.
+func object(x interface{}) map[string]interface{} {
+	switch s := x.(type) {
+	case Schema:
+		return s
+	case map[string]interface{}:
+		return s
+	}
+	return nil
+}
+
+func hasType(t interface{}, v interface{}) bool {
+	names, ok := t.([]interface{})
+	if !ok {
+		names = []interface{}{t}
+	}
+	actual := typeOf(v)
+	for _, name := range names {
+		if name == actual || name == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func typeNames(t interface{}) string {
+	if names, ok := t.([]interface{}); ok {
+		x := make([]string, len(names))
+		for i, name := range names {
+			x[i] = fmt.Sprint(name)
+		}
+		return strings.Join(x, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// typeOf returns the JSON Schema type of a value decoded with UseNumber.
+func typeOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// escape escapes a property name for a JSON pointer.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metrics counts requests by path and status and sums their durations, and
+// serves them in the Prometheus text format.
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]int64
+	durations map[string]*summary
+}
+
+type requestKey struct {
+	path   string
+	status int
+}
+
+type summary struct {
+	count int64
+	sum   float64
+}
+
+func newMetrics() *metrics {
+	return &metrics{requests: map[requestKey]int64{}, durations: map[string]*summary{}}
+}
+
+// paths are the paths metrics are kept for; others count as "other", so
+// that scanning the server cannot grow the metrics without bound.
+var paths = map[string]bool{
+	"/parse":    true,
+	"/validate": true,
+	"/convert":  true,
+	"/evaluate": true,
+	"/decide":   true,
+	"/status":   true,
+	"/healthz":  true,
+	"/metrics":  true,
+}
+
+func (m *metrics) observe(path string, status int, d time.Duration) {
+	if !paths[path] {
+		path = "other"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{path: path, status: status}]++
+	s, ok := m.durations[path]
+	if !ok {
+		s = &summary{}
+		m.durations[path] = s
+	}
+	s.count++
+	s.sum += d.Seconds()
+}
+
+func (m *metrics) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	keys := []requestKey{}
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].status < keys[j].status
+	})
+	fmt.Fprintln(w, "# HELP policyparser_http_requests_total Requests served, by path and status code.")
+	fmt.Fprintln(w, "# TYPE policyparser_http_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "policyparser_http_requests_total{path=%q,code=\"%d\"} %d\n", k.path, k.status, m.requests[k])
+	}
+
+	names := []string{}
+	for path := range m.durations {
+		names = append(names, path)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "# HELP policyparser_http_request_duration_seconds Time spent serving requests, by path.")
+	fmt.Fprintln(w, "# TYPE policyparser_http_request_duration_seconds summary")
+	for _, path := range names {
+		s := m.durations[path]
+		fmt.Fprintf(w, "policyparser_http_request_duration_seconds_sum{path=%q} %s\n", path, strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "policyparser_http_request_duration_seconds_count{path=%q} %d\n", path, s.count)
+	}
+}
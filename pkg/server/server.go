@@ -0,0 +1,432 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/aumahesh/policyparser/internal/aws"
+	"github.com/aumahesh/policyparser/pkg/decoder"
+	"github.com/aumahesh/policyparser/pkg/evaluator"
+	"github.com/aumahesh/policyparser/pkg/parser"
+	"github.com/aumahesh/policyparser/pkg/pdp"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// DefaultMaxBytes is the default limit on the size of a request body.
+const DefaultMaxBytes = 1 << 20
+
+// Options configure a Server. Requests may override the provider, the
+// decoders and strict mode.
+type Options struct {
+	// MaxBytes bounds the size of request bodies, and of the documents
+	// decompressed from them.
+	MaxBytes int64
+	Provider string
+	Decoders []string
+	Strict   bool
+	// PDP, if set, decides requests on /decide against the policies it
+	// loads.
+	PDP *pdp.PDP
+}
+
+// Server serves the parser over HTTP with JSON bodies:
+//
+//	POST /parse     policies parsed from a document
+//	POST /validate  whether a document is valid
+//	POST /convert   a document converted to normalized json or yaml, or aws
+//	POST /evaluate  the decision on a request against documents
+//	POST /decide    the decision of the PDP on a request
+//	GET  /status    the policies of the PDP and the error of its last reload
+//	GET  /healthz   liveness
+//	GET  /metrics   request counts and durations, in the Prometheus format
+type Server struct {
+	opts    Options
+	mux     *http.ServeMux
+	metrics *metrics
+}
+
+// New returns a Server, filling in the defaults of opts.
+func New(opts Options) *Server {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.Provider == "" {
+		opts.Provider = parser.Aws
+	}
+	if len(opts.Decoders) == 0 {
+		opts.Decoders = []string{decoder.Auto}
+	}
+
+	s := &Server{opts: opts, mux: http.NewServeMux(), metrics: newMetrics()}
+	s.mux.HandleFunc("/parse", s.post(s.parse))
+	s.mux.HandleFunc("/validate", s.post(s.validate))
+	s.mux.HandleFunc("/convert", s.post(s.convert))
+	s.mux.HandleFunc("/evaluate", s.post(s.evaluate))
+	if opts.PDP != nil {
+		s.mux.HandleFunc("/decide", s.post(s.decide))
+		s.mux.HandleFunc("/status", s.status)
+	}
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	s.mux.HandleFunc("/metrics", s.metrics.serve)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+	s.mux.ServeHTTP(rec, r)
+	s.metrics.observe(r.URL.Path, rec.status, time.Since(start))
+}
+
+// Document is a policy document of a request, given either as JSON or as
+// a string holding its text.
+type Document struct {
+	// Kind overrides the kind of the policies of the document.
+	Kind   string          `json:"kind,omitempty"`
+	Policy json.RawMessage `json:"policy"`
+}
+
+// ParseRequest is the body of /parse, /validate and /convert.
+type ParseRequest struct {
+	Document
+	Provider string   `json:"provider,omitempty"`
+	Decoders []string `json:"decoders,omitempty"`
+	Strict   *bool    `json:"strict,omitempty"`
+	// Quota is the quota documents are validated against, see
+	// parser.Limited.
+	Quota string `json:"quota,omitempty"`
+	// To is the format of /convert: json, yaml or aws.
+	To string `json:"to,omitempty"`
+}
+
+// EvaluateRequest is the body of /evaluate.
+type EvaluateRequest struct {
+	Provider string            `json:"provider,omitempty"`
+	Decoders []string          `json:"decoders,omitempty"`
+	Strict   *bool             `json:"strict,omitempty"`
+	Policies []Document        `json:"policies"`
+	Request  evaluator.Request `json:"request"`
+	// Logic is aws to combine policies by kind the way AWS does, or single
+	// to take them as one set. It defaults to aws for the aws provider.
+	Logic string `json:"logic,omitempty"`
+}
+
+// ParseResponse is the response of /parse: the policies in their envelope,
+// as the parser command writes them, and the warnings of the parse.
+type ParseResponse struct {
+	*policy.Envelope
+	Warnings []string `json:"warnings"`
+}
+
+// ValidateResponse is the response of /validate.
+type ValidateResponse struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"`
+}
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// httpError is an error with the status it is served with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return &httpError{status: http.StatusBadRequest, err: err}
+}
+
+// post wraps a handler of POST requests with a JSON body into body.
+func (s *Server) post(handle func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJson(w, http.StatusMethodNotAllowed, errorResponse{Error: r.Method + " is not allowed"})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBytes)
+
+		v, err := handle(r)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var he *httpError
+			if errors.As(err, &he) {
+				status = he.status
+			}
+			writeJson(w, status, errorResponse{Error: err.Error()})
+			return
+		}
+		if text, ok := v.(rawBody); ok {
+			w.Header().Set("Content-Type", text.contentType)
+			w.Write(text.body)
+			return
+		}
+		writeJson(w, http.StatusOK, v)
+	}
+}
+
+// rawBody is a response written as is.
+type rawBody struct {
+	contentType string
+	body        []byte
+}
+
+func decode(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &httpError{status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit)}
+		}
+		return badRequest(fmt.Errorf("invalid request: %s", err.Error()))
+	}
+	return nil
+}
+
+// provider returns the provider of a request, the one of the server if the
+// request does not name one.
+func (s *Server) provider(name string) string {
+	if name == "" {
+		return s.opts.Provider
+	}
+	return name
+}
+
+// newParser parses doc with the provider, decoders and mode of the
+// request, or the defaults of the server.
+func (s *Server) newParser(doc Document, provider string, decoders []string, strict *bool, quota string) (parser.Parser, error) {
+	if len(doc.Policy) == 0 {
+		return nil, badRequest(fmt.Errorf("policy is missing"))
+	}
+	text := string(doc.Policy)
+	if doc.Policy[0] == '"' {
+		if err := json.Unmarshal(doc.Policy, &text); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	provider = s.provider(provider)
+	if len(decoders) == 0 {
+		decoders = s.opts.Decoders
+	}
+	mode := s.opts.Strict
+	if strict != nil {
+		mode = *strict
+	}
+
+	dec, err := decoder.NewPipeline(decoders...)
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	p, err := parser.NewParser(provider, text, dec.Limit(s.opts.MaxBytes), mode)
+	if errors.Is(err, decoder.ErrTooLarge) {
+		return nil, &httpError{status: http.StatusRequestEntityTooLarge, err: err}
+	}
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	if l, ok := p.(parser.Limited); ok && quota != "" {
+		if err = l.SetQuota(quota); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	if err = p.Parse(); err != nil {
+		return nil, badRequest(err)
+	}
+	return p, nil
+}
+
+func (s *Server) parse(r *http.Request) (interface{}, error) {
+	req := &ParseRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	p, err := s.newParser(req.Document, req.Provider, req.Decoders, req.Strict, req.Quota)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := policiesOf(p, req.Kind)
+	if err != nil {
+		return nil, err
+	}
+	return &ParseResponse{
+		Envelope: policy.NewEnvelope(s.provider(req.Provider), policies),
+		Warnings: messages(p.Warnings()),
+	}, nil
+}
+
+func (s *Server) validate(r *http.Request) (interface{}, error) {
+	req := &ParseRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	p, err := s.newParser(req.Document, req.Provider, req.Decoders, req.Strict, req.Quota)
+	if err != nil {
+		return nil, err
+	}
+	resp := &ValidateResponse{Valid: true, Errors: []string{}, Warnings: messages(p.Warnings())}
+	if err = p.Validate(); err != nil {
+		resp.Valid = false
+		resp.Errors = messages(parser.Errors(err))
+	}
+	return resp, nil
+}
+
+func (s *Server) convert(r *http.Request) (interface{}, error) {
+	req := &ParseRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	p, err := s.newParser(req.Document, req.Provider, req.Decoders, req.Strict, req.Quota)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := policiesOf(p, req.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.To {
+	case "", "json":
+		return policy.NewEnvelope(s.provider(req.Provider), policies), nil
+	case "yaml":
+		text, err := yaml.Marshal(policy.NewEnvelope(s.provider(req.Provider), policies))
+		if err != nil {
+			return nil, err
+		}
+		return rawBody{contentType: "application/yaml", body: text}, nil
+	case "aws":
+		text, err := aws.Render(policies)
+		if err != nil {
+			return nil, err
+		}
+		return rawBody{contentType: "application/json", body: text}, nil
+	}
+	return nil, badRequest(fmt.Errorf("%s is not a supported format", req.To))
+}
+
+func (s *Server) evaluate(r *http.Request) (interface{}, error) {
+	req := &EvaluateRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	if len(req.Policies) == 0 || req.Request.Action == "" {
+		return nil, badRequest(fmt.Errorf("need an action and at least one policy"))
+	}
+
+	policies := []*policy.Policy{}
+	for i, doc := range req.Policies {
+		p, err := s.newParser(doc, req.Provider, req.Decoders, req.Strict, "")
+		if err != nil {
+			return nil, fmt.Errorf("policy #%d: %w", i, err)
+		}
+		x, err := policiesOf(p, doc.Kind)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, x...)
+	}
+
+	logic := req.Logic
+	if logic == "" {
+		logic = "single"
+		if req.Provider == parser.Aws || (req.Provider == "" && s.opts.Provider == parser.Aws) {
+			logic = parser.Aws
+		}
+	}
+	switch logic {
+	case parser.Aws:
+		return evaluator.EvaluateAws(policies, req.Request), nil
+	case "single":
+		return evaluator.Evaluate(policies, req.Request), nil
+	}
+	return nil, badRequest(fmt.Errorf("%s is not a supported logic", logic))
+}
+
+// StatusResponse is the response of /status.
+type StatusResponse struct {
+	Files    []string  `json:"files"`
+	Policies int       `json:"policies"`
+	LoadedAt time.Time `json:"loaded-at"`
+	// Error is the error of the last reload, which left the policies
+	// loaded before it in place.
+	Error string `json:"error,omitempty"`
+	// Warnings are those of the lenient parse of the loaded policies.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func (s *Server) decide(r *http.Request) (interface{}, error) {
+	req := &evaluator.Request{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	if req.Action == "" {
+		return nil, badRequest(fmt.Errorf("need an action"))
+	}
+	return s.opts.PDP.Decide(*req), nil
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	snapshot := s.opts.PDP.Snapshot()
+	resp := &StatusResponse{Files: snapshot.Files, Policies: len(snapshot.Policies), LoadedAt: snapshot.LoadedAt, Warnings: snapshot.Warnings}
+	if err := s.opts.PDP.Err(); err != nil {
+		resp.Error = err.Error()
+	}
+	writeJson(w, http.StatusOK, resp)
+}
+
+func policiesOf(p parser.Parser, kind string) ([]*policy.Policy, error) {
+	policies, err := p.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if kind != "" {
+		for _, x := range policies {
+			x.Kind = kind
+		}
+	}
+	return policies, nil
+}
+
+func messages(errs []error) []string {
+	x := []string{}
+	for _, e := range errs {
+		x = append(x, e.Error())
+	}
+	return x
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// recorder records the status of a response for the metrics.
+type recorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
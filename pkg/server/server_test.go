@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/evaluator"
+	"github.com/aumahesh/policyparser/pkg/pdp"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+const document = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Sid": "Read",
+		"Effect": "Allow",
+		"Action": ["s3:GetObject", "s3:ListBucket"],
+		"Resource": "arn:aws:s3:::data/*"
+	}]
+}`
+
+func post(t *testing.T, ts *httptest.Server, path, body string) (int, []byte) {
+	resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, b
+}
+
+func TestServer_Parse(t *testing.T) {
+	ts := httptest.NewServer(New(Options{}))
+	defer ts.Close()
+
+	status, body := post(t, ts, "/parse", `{"policy": `+document+`}`)
+	assert.EqualValues(t, http.StatusOK, status, string(body))
+	resp := &ParseResponse{}
+	assert.Nil(t, json.Unmarshal(body, resp))
+	assert.EqualValues(t, policy.FormatVersion, resp.FormatVersion)
+	assert.EqualValues(t, "aws", resp.Provider)
+	assert.Len(t, resp.Policies, 1)
+	assert.EqualValues(t, "Read", resp.Policies[0].Sid)
+
+	// the document may also be given as a string
+	text, _ := json.Marshal(document)
+	status, _ = post(t, ts, "/parse", `{"provider": "aws", "kind": "scp", "policy": `+string(text)+`}`)
+	assert.EqualValues(t, http.StatusOK, status)
+
+	status, body = post(t, ts, "/parse", `{"provider": "oracle", "policy": {}}`)
+	assert.EqualValues(t, http.StatusBadRequest, status)
+	assert.Contains(t, string(body), "oracle is not a supported cloud provider")
+
+	status, _ = post(t, ts, "/parse", `{"policy": `+document+`, "unknown": 1}`)
+	assert.EqualValues(t, http.StatusBadRequest, status)
+
+	status, body = post(t, ts, "/parse", `{"policy": `+document+`, "quota": "sccp"}`)
+	assert.EqualValues(t, http.StatusBadRequest, status)
+	assert.Contains(t, string(body), "sccp is not a known quota")
+
+	resp2, err := http.Get(ts.URL + "/parse")
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusMethodNotAllowed, resp2.StatusCode)
+}
+
+func TestServer_Validate(t *testing.T) {
+	ts := httptest.NewServer(New(Options{}))
+	defer ts.Close()
+
+	status, body := post(t, ts, "/validate", `{"policy": `+document+`}`)
+	assert.EqualValues(t, http.StatusOK, status)
+	resp := &ValidateResponse{}
+	assert.Nil(t, json.Unmarshal(body, resp))
+	assert.True(t, resp.Valid)
+
+	status, body = post(t, ts, "/validate", `{"policy": {"Statement": [{"Action": "s3:*", "Resource": "*"}]}}`)
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.Nil(t, json.Unmarshal(body, resp))
+	assert.False(t, resp.Valid, string(body))
+	assert.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0], "Effect is required")
+}
+
+func TestServer_Convert(t *testing.T) {
+	ts := httptest.NewServer(New(Options{}))
+	defer ts.Close()
+
+	status, body := post(t, ts, "/convert", `{"to": "aws", "policy": `+document+`}`)
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.EqualValues(t, `{"Version":"2012-10-17","Statement":[{"Sid":"Read","Effect":"Allow","Action":["s3:GetObject","s3:ListBucket"],"Resource":"arn:aws:s3:::data/*"}]}`, string(body))
+
+	status, body = post(t, ts, "/convert", `{"to": "json", "policy": `+document+`}`)
+	assert.EqualValues(t, http.StatusOK, status)
+	envelope := &policy.Envelope{}
+	assert.Nil(t, json.Unmarshal(body, envelope))
+	assert.EqualValues(t, policy.FormatVersion, envelope.FormatVersion)
+	assert.EqualValues(t, "aws", envelope.Provider)
+	assert.Len(t, envelope.Policies, 1)
+
+	status, body = post(t, ts, "/convert", `{"to": "yaml", "policy": `+document+`}`)
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "format-version:")
+	assert.Contains(t, string(body), "sid: Read")
+
+	status, _ = post(t, ts, "/convert", `{"to": "xml", "policy": `+document+`}`)
+	assert.EqualValues(t, http.StatusBadRequest, status)
+}
+
+func TestServer_Evaluate(t *testing.T) {
+	ts := httptest.NewServer(New(Options{}))
+	defer ts.Close()
+
+	body := `{
+		"policies": [
+			{"policy": ` + document + `},
+			{"kind": "scp", "policy": {"Statement": [{"Effect": "Allow", "Action": "ec2:*", "Resource": "*"}]}}
+		],
+		"request": {"principal": "arn:aws:iam::111111111111:user/alice", "action": "s3:GetObject", "resource": "arn:aws:s3:::data/x"}
+	}`
+	status, resp := post(t, ts, "/evaluate", body)
+	assert.EqualValues(t, http.StatusOK, status, string(resp))
+	d := &evaluator.Decision{}
+	assert.Nil(t, json.Unmarshal(resp, d))
+	assert.EqualValues(t, evaluator.ImplicitDeny, d.Effect)
+	assert.EqualValues(t, "no service control policy allows the request", d.Reason)
+
+	// taken as a single set the identity policy allows
+	status, resp = post(t, ts, "/evaluate", strings.Replace(body, `"policies"`, `"logic": "single", "policies"`, 1))
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.Nil(t, json.Unmarshal(resp, d))
+	assert.EqualValues(t, evaluator.Allow, d.Effect)
+}
+
+func TestServer_Limits(t *testing.T) {
+	ts := httptest.NewServer(New(Options{MaxBytes: 64}))
+	defer ts.Close()
+
+	status, body := post(t, ts, "/parse", `{"policy": `+document+`}`)
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, status)
+	assert.Contains(t, string(body), "exceeds 64 bytes")
+
+	// a small body must not decompress into a large document
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write([]byte(`{"Statement": [` + strings.Repeat(" ", 1<<20) + `]}`))
+	w.Close()
+	encoded := base64.StdEncoding.EncodeToString(b.Bytes())
+	assert.True(t, len(encoded) < 4096)
+
+	ts = httptest.NewServer(New(Options{MaxBytes: 4096}))
+	defer ts.Close()
+	status, body = post(t, ts, "/parse", `{"policy": "`+encoded+`"}`)
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, status)
+	assert.Contains(t, string(body), "decoded input is too large")
+
+	// whatever the decoders of the request, e.g. none for an envelope whose
+	// embedded document is encoded
+	for _, decoders := range []string{`["none"]`} {
+		status, body = post(t, ts, "/parse", `{"decoders": `+decoders+`, "policy": {"Policy": "`+encoded+`"}}`)
+		assert.EqualValues(t, http.StatusRequestEntityTooLarge, status, decoders)
+		assert.Contains(t, string(body), "decoded input is too large", decoders)
+	}
+
+	// nor into an envelope embedding one
+	status, body = post(t, ts, "/parse", `{"policy": {"PolicyName": "p", "RoleName": "r", "PolicyDocument": "`+encoded+`"}}`)
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, status)
+	assert.Contains(t, string(body), "decoded input is too large")
+}
+
+func TestServer_HealthAndMetrics(t *testing.T) {
+	ts := httptest.NewServer(New(Options{}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+
+	post(t, ts, "/parse", `{"policy": `+document+`}`)
+	post(t, ts, "/parse", `{}`)
+	http.Get(ts.URL + "/unknown")
+
+	resp, err = http.Get(ts.URL + "/metrics")
+	assert.Nil(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	metrics := string(b)
+	assert.Contains(t, metrics, `policyparser_http_requests_total{path="/parse",code="200"} 1`)
+	assert.Contains(t, metrics, `policyparser_http_requests_total{path="/parse",code="400"} 1`)
+	assert.Contains(t, metrics, `policyparser_http_requests_total{path="other",code="404"} 1`)
+	assert.Contains(t, metrics, `policyparser_http_request_duration_seconds_count{path="/parse"} 2`)
+}
+
+func TestServer_Decide(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "read.json"), []byte(document), 0644))
+	p, err := pdp.New(pdp.Options{Dir: dir})
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	ts := httptest.NewServer(New(Options{PDP: p}))
+	defer ts.Close()
+
+	status, resp := post(t, ts, "/decide", `{"principal": "arn:aws:iam::111111111111:user/alice", "action": "s3:GetObject", "resource": "arn:aws:s3:::data/x"}`)
+	assert.EqualValues(t, http.StatusOK, status, string(resp))
+	d := &evaluator.Decision{}
+	assert.Nil(t, json.Unmarshal(resp, d))
+	assert.True(t, d.Allowed)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644))
+	assert.NotNil(t, p.Reload())
+	r, err := http.Get(ts.URL + "/status")
+	assert.Nil(t, err)
+	st := &StatusResponse{}
+	assert.Nil(t, json.NewDecoder(r.Body).Decode(st))
+	assert.EqualValues(t, 1, st.Policies)
+	assert.Contains(t, st.Error, "broken.json")
+}
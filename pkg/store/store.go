@@ -0,0 +1,243 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// schema of the database. Every parsed file is an import; its statements
+// are grouped into documents by origin, and the fields of each statement
+// are normalized into one row per value, with negated set for the Not*
+// fields. Conditions have a row each, even without values, and their values
+// a row each in condition_values. Actions compare case-insensitively, as AWS
+// does.
+const schema = `
+CREATE TABLE IF NOT EXISTS imports (
+	id        INTEGER PRIMARY KEY,
+	source    TEXT NOT NULL,
+	cloud     TEXT NOT NULL,
+	parsed_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS documents (
+	id          INTEGER PRIMARY KEY,
+	import_id   INTEGER NOT NULL REFERENCES imports(id) ON DELETE CASCADE,
+	name        TEXT NOT NULL,
+	kind        TEXT NOT NULL,
+	origin_type TEXT,
+	arn         TEXT,
+	account     TEXT,
+	version_id  TEXT
+);
+CREATE TABLE IF NOT EXISTS attachments (
+	document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	type        TEXT NOT NULL,
+	name        TEXT NOT NULL,
+	arn         TEXT
+);
+CREATE TABLE IF NOT EXISTS policies (
+	id           INTEGER PRIMARY KEY,
+	document_id  INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+	statement_id TEXT NOT NULL,
+	sid          TEXT,
+	version      TEXT,
+	allowed      INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS subjects (
+	policy_id INTEGER NOT NULL REFERENCES policies(id) ON DELETE CASCADE,
+	value     TEXT NOT NULL,
+	negated   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS actions (
+	policy_id INTEGER NOT NULL REFERENCES policies(id) ON DELETE CASCADE,
+	value     TEXT NOT NULL COLLATE NOCASE,
+	negated   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS resources (
+	policy_id INTEGER NOT NULL REFERENCES policies(id) ON DELETE CASCADE,
+	value     TEXT NOT NULL,
+	negated   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS conditions (
+	id         INTEGER PRIMARY KEY,
+	policy_id  INTEGER NOT NULL REFERENCES policies(id) ON DELETE CASCADE,
+	operator   TEXT NOT NULL,
+	key        TEXT NOT NULL,
+	value_type TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS condition_values (
+	condition_id INTEGER NOT NULL REFERENCES conditions(id) ON DELETE CASCADE,
+	value        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS documents_import ON documents(import_id);
+CREATE INDEX IF NOT EXISTS policies_document ON policies(document_id);
+CREATE INDEX IF NOT EXISTS subjects_value ON subjects(value);
+CREATE INDEX IF NOT EXISTS actions_value ON actions(value);
+CREATE INDEX IF NOT EXISTS resources_value ON resources(value);
+CREATE INDEX IF NOT EXISTS conditions_key ON conditions(key);
+CREATE INDEX IF NOT EXISTS condition_values_condition ON condition_values(condition_id);
+`
+
+// Store keeps parsed policies in a SQLite database.
+type Store struct {
+	db *sql.DB
+}
+
+// Import is one parsed file.
+type Import struct {
+	Source   string
+	Cloud    string
+	ParsedAt time.Time
+}
+
+// Open opens the database at path, creating it and its tables if needed.
+// The path may carry driver parameters, e.g. file:policies.db?cache=shared.
+// SQLite is linked through cgo: in a binary built with CGO_ENABLED=0 Open
+// fails.
+func Open(path string) (*Store, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("sqlite3", path+separator+"_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return &Store{db: db}, nil
+}
+
+// DB returns the database, for queries.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save writes the statements of one import in a single transaction and
+// returns the id of the import.
+func (s *Store) Save(i Import, policies []*policy.Policy) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	id, err := save(tx, i, policies)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func save(tx *sql.Tx, i Import, policies []*policy.Policy) (int64, error) {
+	importId, err := insert(tx, `INSERT INTO imports (source, cloud, parsed_at) VALUES (?, ?, ?)`,
+		i.Source, i.Cloud, i.ParsedAt.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, err
+	}
+
+	documents := map[string]int64{}
+	for _, p := range policies {
+		key := fmt.Sprintf("%p|%s|%s", p.Origin, p.Kind, p.DocumentName())
+		documentId, ok := documents[key]
+		if !ok {
+			if documentId, err = saveDocument(tx, importId, p); err != nil {
+				return 0, err
+			}
+			documents[key] = documentId
+		}
+		if err = savePolicy(tx, documentId, p); err != nil {
+			return 0, fmt.Errorf("%s: %s", p.Id, err.Error())
+		}
+	}
+	return importId, nil
+}
+
+func saveDocument(tx *sql.Tx, importId int64, p *policy.Policy) (int64, error) {
+	o := p.Origin
+	if o == nil {
+		o = &policy.Origin{}
+	}
+	id, err := insert(tx, `INSERT INTO documents (import_id, name, kind, origin_type, arn, account, version_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		importId, p.DocumentName(), p.Kind, null(o.Type), null(o.Arn), null(o.Account), null(o.VersionId))
+	if err != nil {
+		return 0, err
+	}
+	for _, a := range o.AttachedTo {
+		if _, err = tx.Exec(`INSERT INTO attachments (document_id, type, name, arn) VALUES (?, ?, ?, ?)`,
+			id, a.Type, a.Name, null(a.Arn)); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func savePolicy(tx *sql.Tx, documentId int64, p *policy.Policy) error {
+	id, err := insert(tx, `INSERT INTO policies (document_id, statement_id, sid, version, allowed) VALUES (?, ?, ?, ?, ?)`,
+		documentId, p.Id, null(p.Sid), null(p.Version), p.Allowed)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range []struct {
+		table   string
+		field   string
+		negated bool
+	}{
+		{"subjects", policy.FieldSubjects, false},
+		{"subjects", policy.FieldNotSubjects, true},
+		{"actions", policy.FieldActions, false},
+		{"actions", policy.FieldNotActions, true},
+		{"resources", policy.FieldResources, false},
+		{"resources", policy.FieldNotResources, true},
+	} {
+		for _, value := range p.WrittenGlobs(f.field) {
+			if _, err = tx.Exec(`INSERT INTO `+f.table+` (policy_id, value, negated) VALUES (?, ?, ?)`,
+				id, value, f.negated); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range p.Condition {
+		conditionId, err := insert(tx, `INSERT INTO conditions (policy_id, operator, key, value_type) VALUES (?, ?, ?, ?)`,
+			id, c.Operation, c.Key, c.Value.Type())
+		if err != nil {
+			return err
+		}
+		for _, value := range c.Value.Text() {
+			if _, err = tx.Exec(`INSERT INTO condition_values (condition_id, value) VALUES (?, ?)`,
+				conditionId, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func insert(tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	r, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return r.LastInsertId()
+}
+
+// null stores empty strings as NULL.
+func null(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
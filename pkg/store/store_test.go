@@ -0,0 +1,103 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func TestStore_Save(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "policies.db"))
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+	defer s.Close()
+
+	origin := &policy.Origin{
+		Type:       policy.OriginManaged,
+		Name:       "ReadData",
+		Arn:        "arn:aws:iam::111111111111:policy/ReadData",
+		Account:    "111111111111",
+		AttachedTo: []policy.Attachment{{Type: "role", Name: "analyst", Arn: "arn:aws:iam::111111111111:role/analyst"}},
+	}
+	read := &policy.Policy{Id: "ReadData:0", Sid: "Read", Version: "2012-10-17", Allowed: true, Kind: policy.KindIdentity, Origin: origin}
+	read.Actions, read.Patterns.Actions = policy.NewPatterns([]string{"s3:GetObject", "s3:ListBucket"}, true)
+	read.Resources, read.Patterns.Resources = policy.NewPatterns([]string{"arn:aws:s3:::data/*"}, false)
+	read.Condition = []policy.Condition{
+		{Operation: "IpAddress", Key: "aws:SourceIp", Value: policy.StringValues("10.0.0.0/8", "192.168.0.0/16")},
+		{Operation: "NumericLessThan", Key: "s3:max-keys", Value: policy.Int64Values(10)},
+		{Operation: "StringLike", Key: "s3:prefix", Value: policy.StringValues()},
+	}
+	deny := &policy.Policy{Id: "ReadData:1", Kind: policy.KindIdentity, Origin: origin}
+	deny.NotActions, deny.Patterns.NotActions = policy.NewPatterns([]string{"s3:*"}, true)
+	deny.Resources, deny.Patterns.Resources = policy.NewPatterns([]string{"*"}, false)
+	trust := &policy.Policy{Id: "trust:0", Allowed: true, Kind: policy.KindTrust}
+	trust.Subjects, trust.Patterns.Subjects = policy.NewPatterns([]string{"arn:aws:iam::222222222222:root"}, false)
+	trust.Actions, trust.Patterns.Actions = policy.NewPatterns([]string{"sts:AssumeRole"}, true)
+
+	parsedAt := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	id, err := s.Save(Import{Source: "estate.json", Cloud: "aws", ParsedAt: parsedAt}, []*policy.Policy{read, deny, trust})
+	assert.Nil(t, err)
+	if err != nil {
+		t.FailNow()
+	}
+
+	count := func(query string, args ...interface{}) int {
+		var n int
+		assert.Nil(t, s.DB().QueryRow(query, args...).Scan(&n), query)
+		return n
+	}
+	assert.EqualValues(t, 2, count(`SELECT COUNT(*) FROM documents WHERE import_id = ?`, id))
+	assert.EqualValues(t, 3, count(`SELECT COUNT(*) FROM policies`))
+	assert.EqualValues(t, 1, count(`SELECT COUNT(*) FROM attachments WHERE arn = 'arn:aws:iam::111111111111:role/analyst'`))
+	assert.EqualValues(t, 1, count(`SELECT COUNT(*) FROM actions WHERE negated AND value = 's3:*'`))
+	assert.EqualValues(t, 3, count(`SELECT COUNT(*) FROM conditions`))
+	assert.EqualValues(t, 3, count(`SELECT COUNT(*) FROM condition_values`))
+	assert.EqualValues(t, 1, count(`SELECT COUNT(*) FROM conditions c
+		WHERE NOT EXISTS (SELECT 1 FROM condition_values v WHERE v.condition_id = c.id)`))
+	assert.EqualValues(t, 2, count(`SELECT COUNT(*) FROM condition_values v
+		JOIN conditions c ON c.id = v.condition_id WHERE c.key = 'aws:SourceIp'`))
+
+	var parsed, name, kind string
+	assert.Nil(t, s.DB().QueryRow(`SELECT i.parsed_at, d.name, d.kind FROM policies p
+		JOIN documents d ON d.id = p.document_id JOIN imports i ON i.id = d.import_id
+		JOIN subjects s ON s.policy_id = p.id WHERE s.value = ?`, "arn:aws:iam::222222222222:root").Scan(&parsed, &name, &kind))
+	assert.EqualValues(t, "2020-06-01T12:00:00Z", parsed)
+	assert.EqualValues(t, "trust", name)
+	assert.EqualValues(t, policy.KindTrust, kind)
+
+	// who may read from data, across documents
+	rows, err := s.DB().Query(`SELECT DISTINCT a.arn FROM actions x
+		JOIN policies p ON p.id = x.policy_id JOIN attachments a ON a.document_id = p.document_id
+		WHERE p.allowed AND NOT x.negated AND x.value = 's3:getobject'`)
+	assert.Nil(t, err)
+	arns := []string{}
+	for rows.Next() {
+		var arn string
+		assert.Nil(t, rows.Scan(&arn))
+		arns = append(arns, arn)
+	}
+	assert.EqualValues(t, []string{"arn:aws:iam::111111111111:role/analyst"}, arns)
+}
+
+func TestStore_OpenParameters(t *testing.T) {
+	for _, path := range []string{
+		filepath.Join(t.TempDir(), "policies.db"),
+		"file:" + filepath.Join(t.TempDir(), "policies.db") + "?cache=shared",
+	} {
+		s, err := Open(path)
+		assert.Nil(t, err, path)
+		if err != nil {
+			continue
+		}
+		var on int
+		assert.Nil(t, s.DB().QueryRow(`PRAGMA foreign_keys`).Scan(&on))
+		assert.EqualValues(t, 1, on, path)
+		s.Close()
+	}
+}
@@ -0,0 +1,375 @@
+package subsume
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// fillers replace the wildcards of a glob to build concrete values from it.
+var fillers = []struct {
+	anyString string
+	anyChar   string
+}{
+	{"counterexample", "x"},
+	{"", "x"},
+}
+
+// sampleValue is given to the policy variables that have no default.
+const sampleValue = "counterexample"
+
+// Counterexample is a request allowed by the candidate policies but not by
+// the baseline.
+type Counterexample struct {
+	Principal string `json:"principal,omitempty" yaml:"principal,omitempty"`
+	Action    string `json:"action" yaml:"action"`
+	Resource  string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	GrantedBy string `json:"granted-by" yaml:"granted-by"`                   // candidate statement allowing the request
+	DeniedBy  string `json:"denied-by,omitempty" yaml:"denied-by,omitempty"` // baseline statement denying it, if any
+	Reason    string `json:"reason" yaml:"reason"`
+	// Variables holds the values of the policy variables of the statements
+	// involved that the request was made with.
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+}
+
+// Result of checking whether a candidate set of policies is subsumed by a
+// baseline.
+type Result struct {
+	Subsumed        bool             `json:"subsumed" yaml:"subsumed"`
+	Counterexamples []Counterexample `json:"counterexamples,omitempty" yaml:"counterexamples,omitempty"`
+	// Unproven lists candidate statements that could neither be shown to be
+	// covered by the baseline nor refuted with a counterexample, e.g. because
+	// only the union of several baseline statements covers them.
+	Unproven []string `json:"unproven,omitempty" yaml:"unproven,omitempty"`
+}
+
+// Check reports whether every request allowed by candidate is also allowed
+// by baseline, i.e. candidate ⊆ baseline. A statement of the baseline only
+// counts as allowing a request if its conditions are among the conditions
+// of the candidate statement, so that it applies whenever the candidate one
+// does. Policy variables are given their default, or else a sample value,
+// so that counterexamples are concrete requests.
+func Check(baseline, candidate []*policy.Policy) *Result {
+	r := &Result{Counterexamples: []Counterexample{}}
+
+	vars := bindings(baseline, candidate)
+	original := map[*policy.Policy]*policy.Policy{}
+	baseline, candidate = resolveAll(baseline, vars, original), resolveAll(candidate, vars, original)
+
+	pool := newValuePool(baseline, candidate)
+
+	for _, s := range candidate {
+		if !s.Allowed {
+			continue
+		}
+
+		found := map[string]bool{}
+		for _, w := range pool.requests(s) {
+			if deniedBy(candidate, w, s.Condition) != nil {
+				continue
+			}
+
+			ce := Counterexample{
+				Principal: w.principal,
+				Action:    w.action,
+				Resource:  w.resource,
+				GrantedBy: s.Id,
+			}
+			if allowedBy(baseline, w, s.Condition) == nil {
+				ce.Reason = "no baseline statement allows the request"
+				ce.Variables = bound(vars, original[s])
+			} else if d := deniedBy(baseline, w, nil); d != nil &&
+				deniedBy(candidate, w, append(append([]policy.Condition{}, s.Condition...), d.Condition...)) == nil {
+				ce.DeniedBy = d.Id
+				ce.Reason = fmt.Sprintf("the baseline denies the request in statement %s", d.Id)
+				ce.Variables = bound(vars, original[s], original[d])
+			} else {
+				continue
+			}
+
+			// one counterexample per statement and reason is enough
+			if !found[ce.Reason] {
+				found[ce.Reason] = true
+				r.Counterexamples = append(r.Counterexamples, ce)
+			}
+		}
+
+		if len(found) == 0 && !covered(baseline, s) {
+			r.Unproven = append(r.Unproven, s.Id)
+		}
+	}
+
+	r.Subsumed = len(r.Counterexamples) == 0 && len(r.Unproven) == 0
+	return r
+}
+
+// bindings returns a value for every policy variable of the sets: its
+// default if a statement gives one, else sampleValue. Names differing only
+// in case are the same variable and share the value of the first seen.
+func bindings(sets ...[]*policy.Policy) map[string]string {
+	vars := map[string]string{}
+	names := map[string]string{}
+	for _, set := range sets {
+		for _, p := range set {
+			for _, v := range p.Variables {
+				name, ok := names[strings.ToLower(v.Name)]
+				if !ok {
+					name = v.Name
+					names[strings.ToLower(v.Name)] = name
+					vars[name] = sampleValue
+				}
+				if v.Default != "" && vars[name] == sampleValue {
+					vars[name] = v.Default
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// resolveAll resolves every policy with vars, recording in original the
+// policy each copy was resolved from.
+func resolveAll(policies []*policy.Policy, vars map[string]string, original map[*policy.Policy]*policy.Policy) []*policy.Policy {
+	x := []*policy.Policy{}
+	for _, p := range policies {
+		r, _ := p.Resolve(vars)
+		original[r] = p
+		x = append(x, r)
+	}
+	return x
+}
+
+// bound returns the values in vars of the variables the policies reference.
+func bound(vars map[string]string, policies ...*policy.Policy) map[string]string {
+	x := map[string]string{}
+	for _, p := range policies {
+		for _, v := range p.Variables {
+			for name, value := range vars {
+				if strings.EqualFold(name, v.Name) {
+					x[name] = value
+				}
+			}
+		}
+	}
+	if len(x) == 0 {
+		return nil
+	}
+	return x
+}
+
+// request is a concrete request.
+type request struct {
+	principal string
+	action    string
+	resource  string
+}
+
+func allowedBy(policies []*policy.Policy, w request, conditions []policy.Condition) *policy.Policy {
+	for _, p := range policies {
+		if p.Allowed && p.Matches(w.principal, w.action, w.resource) && policy.ConditionsIn(p.Condition, conditions) {
+			return p
+		}
+	}
+	return nil
+}
+
+// deniedBy returns a Deny statement matching w. With conditions set, only
+// statements whose conditions are among them count, i.e. statements that
+// certainly apply when the conditions hold.
+func deniedBy(policies []*policy.Policy, w request, conditions []policy.Condition) *policy.Policy {
+	for _, p := range policies {
+		if p.Allowed || !p.Matches(w.principal, w.action, w.resource) {
+			continue
+		}
+		if conditions != nil && !policy.ConditionsIn(p.Condition, conditions) {
+			continue
+		}
+		return p
+	}
+	return nil
+}
+
+// covered reports whether a single allow statement of the baseline covers
+// every action, resource and principal of s, and no deny of the baseline
+// overlaps it.
+func covered(baseline []*policy.Policy, s *policy.Policy) bool {
+	for _, field := range []string{policy.FieldNotActions, policy.FieldNotResources, policy.FieldNotSubjects} {
+		if len(s.Globs(field)) > 0 {
+			// the complement of a glob is not a glob; leave it to the
+			// counterexample search
+			return false
+		}
+	}
+
+	for _, p := range baseline {
+		if !p.Allowed && overlaps(p, s) {
+			return false
+		}
+	}
+	for _, p := range baseline {
+		if p.Allowed && contains(p, s) && policy.ConditionsIn(p.Condition, s.Condition) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(outer, inner *policy.Policy) bool {
+	return fieldContains(outer, inner, policy.FieldActions, policy.FieldNotActions) &&
+		fieldContains(outer, inner, policy.FieldResources, policy.FieldNotResources) &&
+		fieldContains(outer, inner, policy.FieldSubjects, policy.FieldNotSubjects)
+}
+
+func fieldContains(outer, inner *policy.Policy, field, notField string) bool {
+	in := inner.ComparableGlobs(field)
+	out := outer.ComparableGlobs(field)
+	notOut := outer.ComparableGlobs(notField)
+
+	if len(out) == 0 && len(notOut) == 0 {
+		// unrestricted, except for actions which must always be named
+		return field != policy.FieldActions
+	}
+	if len(in) == 0 {
+		// inner is unrestricted in this field
+		return len(out) == 1 && out[0] == "*"
+	}
+	for _, i := range in {
+		ok := false
+		if len(out) > 0 {
+			for _, o := range out {
+				if policy.GlobContains(o, i) {
+					ok = true
+					break
+				}
+			}
+		} else {
+			ok = true
+			for _, n := range notOut {
+				if policy.GlobsOverlap(n, i) {
+					ok = false
+					break
+				}
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func overlaps(a, b *policy.Policy) bool {
+	return fieldOverlaps(a, b, policy.FieldActions) &&
+		fieldOverlaps(a, b, policy.FieldResources) &&
+		fieldOverlaps(a, b, policy.FieldSubjects)
+}
+
+func fieldOverlaps(a, b *policy.Policy, field string) bool {
+	x := a.ComparableGlobs(field)
+	y := b.ComparableGlobs(field)
+	if len(x) == 0 || len(y) == 0 {
+		// NotX fields and unrestricted fields: assume an overlap
+		return true
+	}
+	for _, i := range x {
+		for _, j := range y {
+			if policy.GlobsOverlap(i, j) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// valuePool holds concrete values built from every glob of both policy sets,
+// per dimension.
+type valuePool struct {
+	actions    []string
+	resources  []string
+	principals []string
+}
+
+func newValuePool(sets ...[]*policy.Policy) *valuePool {
+	vp := &valuePool{}
+	seen := map[string]bool{}
+	add := func(dimension string, x *[]string, globs []string) {
+		for _, g := range globs {
+			for _, v := range instances(g) {
+				if !seen[dimension+"|"+v] {
+					seen[dimension+"|"+v] = true
+					*x = append(*x, v)
+				}
+			}
+		}
+	}
+	for _, set := range sets {
+		for _, p := range set {
+			add("a", &vp.actions, p.Globs(policy.FieldActions))
+			add("a", &vp.actions, p.Globs(policy.FieldNotActions))
+			add("r", &vp.resources, p.Globs(policy.FieldResources))
+			add("r", &vp.resources, p.Globs(policy.FieldNotResources))
+			add("p", &vp.principals, p.Globs(policy.FieldSubjects))
+			add("p", &vp.principals, p.Globs(policy.FieldNotSubjects))
+		}
+	}
+	add("a", &vp.actions, []string{"counterexample:*"})
+	add("r", &vp.resources, []string{"arn:aws:counterexample:::*"})
+	add("p", &vp.principals, []string{"arn:aws:iam::000000000000:*"})
+	return vp
+}
+
+// requests returns the concrete requests of the pool that s applies to.
+func (vp *valuePool) requests(s *policy.Policy) []request {
+	actions := filter(vp.actions, s.MatchesAction)
+	resources := filter(vp.resources, s.MatchesResource)
+	principals := filter(vp.principals, s.MatchesPrincipal)
+	if len(s.Globs(policy.FieldResources)) == 0 && len(s.Globs(policy.FieldNotResources)) == 0 {
+		resources = []string{""}
+	}
+	if len(s.Globs(policy.FieldSubjects)) == 0 && len(s.Globs(policy.FieldNotSubjects)) == 0 {
+		principals = []string{""}
+	}
+
+	x := []request{}
+	for _, a := range actions {
+		for _, r := range resources {
+			for _, p := range principals {
+				x = append(x, request{principal: p, action: a, resource: r})
+			}
+		}
+	}
+	return x
+}
+
+func filter(values []string, keep func(string) bool) []string {
+	x := []string{}
+	for _, v := range values {
+		if keep(v) {
+			x = append(x, v)
+		}
+	}
+	return x
+}
+
+// instances returns concrete values matched by glob.
+func instances(glob string) []string {
+	x := []string{}
+	for _, f := range fillers {
+		b := strings.Builder{}
+		for _, t := range policy.Tokenize(glob) {
+			switch t.Kind {
+			case policy.TokenAnyString:
+				b.WriteString(f.anyString)
+			case policy.TokenAnyChar:
+				b.WriteString(f.anyChar)
+			case policy.TokenVariable:
+				b.WriteString(policy.VariableText(t))
+			default:
+				b.WriteString(t.Value)
+			}
+		}
+		x = append(x, b.String())
+	}
+	return x
+}
@@ -0,0 +1,126 @@
+package subsume
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func TestCheck_Subsumed(t *testing.T) {
+	baseline := []*policy.Policy{
+		policy.NewStatement("a:0", "", true, nil, []string{"s3:*"}, []string{"arn:aws:s3:::data/*"}),
+		policy.NewStatement("a:1", "", false, nil, []string{"s3:DeleteObject"}, []string{"*"}),
+	}
+	candidate := []*policy.Policy{
+		policy.NewStatement("b:0", "", true, nil, []string{"s3:Get*", "S3:PutObject"}, []string{"arn:aws:s3:::data/reports/*"}),
+	}
+
+	r := Check(baseline, candidate)
+	assert.True(t, r.Subsumed)
+	assert.Len(t, r.Counterexamples, 0)
+	assert.Len(t, r.Unproven, 0)
+}
+
+func TestCheck_Broader(t *testing.T) {
+	baseline := []*policy.Policy{
+		policy.NewStatement("a:0", "", true, nil, []string{"s3:Get*"}, []string{"arn:aws:s3:::data/*"}),
+	}
+	candidate := []*policy.Policy{
+		policy.NewStatement("b:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::*"}),
+	}
+
+	r := Check(baseline, candidate)
+	assert.False(t, r.Subsumed)
+	assert.Len(t, r.Counterexamples, 1)
+	if len(r.Counterexamples) != 1 {
+		t.FailNow()
+	}
+	ce := r.Counterexamples[0]
+	assert.EqualValues(t, "b:0", ce.GrantedBy)
+	assert.EqualValues(t, "s3:GetObject", ce.Action)
+	assert.False(t, policy.MatchGlob("arn:aws:s3:::data/*", ce.Resource))
+	assert.True(t, policy.MatchGlob("arn:aws:s3:::*", ce.Resource))
+}
+
+func TestCheck_DeniedByBaseline(t *testing.T) {
+	baseline := []*policy.Policy{
+		policy.NewStatement("a:0", "", true, nil, []string{"*"}, []string{"*"}),
+		policy.NewStatement("a:1", "", false, nil, []string{"iam:*"}, []string{"*"}),
+	}
+	candidate := []*policy.Policy{
+		policy.NewStatement("b:0", "", true, nil, []string{"iam:PassRole", "ec2:RunInstances"}, []string{"*"}),
+	}
+
+	r := Check(baseline, candidate)
+	assert.False(t, r.Subsumed)
+	assert.Len(t, r.Counterexamples, 1)
+	if len(r.Counterexamples) != 1 {
+		t.FailNow()
+	}
+	assert.EqualValues(t, "iam:PassRole", r.Counterexamples[0].Action)
+	assert.EqualValues(t, "a:1", r.Counterexamples[0].DeniedBy)
+
+	// the same deny in the candidate closes the gap
+	candidate = append(candidate, policy.NewStatement("b:1", "", false, nil, []string{"iam:*"}, []string{"*"}))
+	r = Check(baseline, candidate)
+	assert.Len(t, r.Counterexamples, 0)
+}
+
+func TestCheck_Conditions(t *testing.T) {
+	mfa := policy.Condition{Operation: "Bool", Key: "aws:MultiFactorAuthPresent", Value: policy.BoolValues(true)}
+
+	conditional := policy.NewStatement("a:0", "", true, nil, []string{"ec2:*"}, []string{"*"})
+	conditional.Condition = []policy.Condition{mfa}
+
+	unconditional := policy.NewStatement("b:0", "", true, nil, []string{"ec2:StopInstances"}, []string{"*"})
+
+	r := Check([]*policy.Policy{conditional}, []*policy.Policy{unconditional})
+	assert.False(t, r.Subsumed)
+	assert.Len(t, r.Counterexamples, 1)
+
+	unconditional.Condition = []policy.Condition{mfa}
+	r = Check([]*policy.Policy{conditional}, []*policy.Policy{unconditional})
+	assert.True(t, r.Subsumed)
+}
+
+func TestCheck_VariableDefaults(t *testing.T) {
+	baseline := []*policy.Policy{
+		policy.NewStatement("a:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::shared/*"}),
+	}
+	candidate := []*policy.Policy{
+		policy.NewStatement("b:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::home/${aws:username, 'guest'}/*"}),
+	}
+
+	r := Check(baseline, candidate)
+	assert.False(t, r.Subsumed)
+	assert.Empty(t, r.Unproven)
+	assert.Len(t, r.Counterexamples, 1)
+	if len(r.Counterexamples) != 1 {
+		t.FailNow()
+	}
+	assert.EqualValues(t, "arn:aws:s3:::home/guest/counterexample", r.Counterexamples[0].Resource)
+	assert.EqualValues(t, map[string]string{"aws:username": "guest"}, r.Counterexamples[0].Variables)
+	resolved, err := candidate[0].Resolve(r.Counterexamples[0].Variables)
+	assert.Nil(t, err)
+	assert.True(t, resolved.MatchesResource(r.Counterexamples[0].Resource))
+
+	// without a default the variable is given a sample value, the same in
+	// the baseline and the candidate
+	baseline = []*policy.Policy{
+		policy.NewStatement("a:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::home/${aws:username}/public/*"}),
+	}
+	candidate = []*policy.Policy{
+		policy.NewStatement("b:0", "", true, nil, []string{"s3:GetObject"}, []string{"arn:aws:s3:::home/${aws:username}/*"}),
+	}
+	r = Check(baseline, candidate)
+	assert.False(t, r.Subsumed)
+	assert.Len(t, r.Counterexamples, 1)
+	if len(r.Counterexamples) != 1 {
+		t.FailNow()
+	}
+	assert.EqualValues(t, "arn:aws:s3:::home/counterexample/counterexample", r.Counterexamples[0].Resource)
+	assert.EqualValues(t, map[string]string{"aws:username": "counterexample"}, r.Counterexamples[0].Variables)
+	assert.True(t, Check(baseline, baseline).Subsumed)
+}
@@ -0,0 +1,163 @@
+package summary
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aumahesh/policyparser/pkg/catalog"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+// Access to an access level of a service.
+const (
+	Full    = "Full"
+	Limited = "Limited"
+)
+
+// Level is the access granted to one access level of a service.
+type Level struct {
+	Level   string `json:"level" yaml:"level"`
+	Access  string `json:"access" yaml:"access"`
+	Granted int    `json:"granted" yaml:"granted"`
+	Total   int    `json:"total" yaml:"total"`
+	// Actions are the actions granted when access is limited.
+	Actions []string `json:"actions,omitempty" yaml:"actions,omitempty"`
+}
+
+// Service summarizes the statements of one effect touching a service.
+type Service struct {
+	Prefix       string   `json:"prefix" yaml:"prefix"`
+	Name         string   `json:"name" yaml:"name"`
+	Effect       string   `json:"effect" yaml:"effect"`
+	Levels       []Level  `json:"levels" yaml:"levels"`
+	Resources    []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+	NotResources []string `json:"not-resources,omitempty" yaml:"not-resources,omitempty"`
+	// Conditions are rendered as "operator key [values]".
+	Conditions []string `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Statements []string `json:"statements" yaml:"statements"`
+}
+
+// Unrecognized is an action pattern matching nothing in the catalog.
+type Unrecognized struct {
+	Action    string `json:"action" yaml:"action"`
+	Effect    string `json:"effect" yaml:"effect"`
+	Statement string `json:"statement" yaml:"statement"`
+}
+
+// Summary of a policy per service, like the policy summary of the IAM
+// console.
+type Summary struct {
+	Services     []*Service     `json:"services" yaml:"services"`
+	Unrecognized []Unrecognized `json:"unrecognized,omitempty" yaml:"unrecognized,omitempty"`
+}
+
+// Summarize returns the access granted, and explicitly denied, by
+// policies per service and access level, using the actions of c. Access
+// to a level is full when every action of the level in the service is in
+// the scope of a statement, whatever its resources and conditions.
+func Summarize(policies []*policy.Policy, c *catalog.Catalog) *Summary {
+	s := &Summary{Services: []*Service{}}
+
+	type key struct{ effect, prefix string }
+	services := map[key]*Service{}
+	granted := map[key]map[*catalog.Action]bool{}
+
+	for _, p := range policies {
+		effect := effectOf(p)
+		e := c.ExpandPolicy(p)
+		for _, glob := range e.Unknown {
+			s.Unrecognized = append(s.Unrecognized, Unrecognized{Action: glob, Effect: effect, Statement: p.Id})
+		}
+
+		for _, a := range e.Actions {
+			k := key{effect, strings.ToLower(a.Service)}
+			svc, ok := services[k]
+			if !ok {
+				svc = &Service{Prefix: a.Service, Effect: effect, Statements: []string{}}
+				if cs := c.Service(a.Service); cs != nil {
+					svc.Name = cs.Name
+				}
+				services[k] = svc
+				granted[k] = map[*catalog.Action]bool{}
+				s.Services = append(s.Services, svc)
+			}
+			granted[k][a] = true
+			if !contains(svc.Statements, p.Id) {
+				svc.Statements = append(svc.Statements, p.Id)
+				svc.Resources = union(svc.Resources, p.WrittenGlobs(policy.FieldResources))
+				svc.NotResources = union(svc.NotResources, p.WrittenGlobs(policy.FieldNotResources))
+				svc.Conditions = union(svc.Conditions, p.ConditionStrings())
+			}
+		}
+	}
+
+	for k, svc := range services {
+		svc.Levels = levels(c.Service(k.prefix), granted[k])
+	}
+
+	sort.Slice(s.Services, func(i, j int) bool {
+		if s.Services[i].Effect != s.Services[j].Effect {
+			return s.Services[i].Effect == "Allow"
+		}
+		return s.Services[i].Prefix < s.Services[j].Prefix
+	})
+	return s
+}
+
+// levels returns the access to each level of svc given the granted
+// actions, in the order of catalog.AccessLevels.
+func levels(svc *catalog.Service, granted map[*catalog.Action]bool) []Level {
+	x := []Level{}
+	if svc == nil {
+		return x
+	}
+	for _, name := range catalog.AccessLevels {
+		l := Level{Level: name}
+		actions := []string{}
+		for _, a := range svc.Actions {
+			if a.AccessLevel != name {
+				continue
+			}
+			l.Total++
+			if granted[a] {
+				l.Granted++
+				actions = append(actions, a.Name)
+			}
+		}
+		if l.Granted == 0 {
+			continue
+		}
+		l.Access = Full
+		if l.Granted < l.Total {
+			l.Access = Limited
+			l.Actions = actions
+		}
+		x = append(x, l)
+	}
+	return x
+}
+
+func effectOf(p *policy.Policy) string {
+	if p.Allowed {
+		return "Allow"
+	}
+	return "Deny"
+}
+
+func union(x, y []string) []string {
+	for _, s := range y {
+		if !contains(x, s) {
+			x = append(x, s)
+		}
+	}
+	return x
+}
+
+func contains(x []string, s string) bool {
+	for _, v := range x {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
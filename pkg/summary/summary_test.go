@@ -0,0 +1,64 @@
+package summary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aumahesh/policyparser/pkg/catalog"
+	"github.com/aumahesh/policyparser/pkg/policy"
+)
+
+func TestSummarize(t *testing.T) {
+	read := policy.NewStatement("p:0", "", true, nil, []string{"s3:Get*", "s3:List*"}, []string{"arn:aws:s3:::data", "arn:aws:s3:::data/*"})
+	read.Condition = []policy.Condition{
+		{Operation: "Bool", Key: "aws:SecureTransport", Value: policy.BoolValues(true)},
+	}
+	put := policy.NewStatement("p:1", "", true, nil, []string{"s3:PutObject", "athena:StartQueryExecution"}, []string{"*"})
+	deny := policy.NewStatement("p:2", "", false, nil, []string{"s3:DeleteBucket"}, []string{"*"})
+
+	s := Summarize([]*policy.Policy{read, put, deny}, catalog.Default())
+	assert.Len(t, s.Services, 2)
+	if len(s.Services) != 2 {
+		t.FailNow()
+	}
+
+	allow := s.Services[0]
+	assert.EqualValues(t, "s3", allow.Prefix)
+	assert.EqualValues(t, "Allow", allow.Effect)
+	assert.EqualValues(t, []string{"p:0", "p:1"}, allow.Statements)
+	assert.EqualValues(t, []string{"arn:aws:s3:::data", "arn:aws:s3:::data/*", "*"}, allow.Resources)
+	assert.EqualValues(t, []string{"Bool aws:SecureTransport [true]"}, allow.Conditions)
+
+	levels := map[string]Level{}
+	for _, l := range allow.Levels {
+		levels[l.Level] = l
+	}
+	assert.EqualValues(t, Full, levels[catalog.List].Access)
+	assert.Len(t, levels[catalog.List].Actions, 0)
+	assert.EqualValues(t, Full, levels[catalog.Read].Access)
+	assert.EqualValues(t, Limited, levels[catalog.Write].Access)
+	assert.EqualValues(t, []string{"PutObject"}, levels[catalog.Write].Actions)
+	assert.EqualValues(t, 1, levels[catalog.Write].Granted)
+	_, ok := levels[catalog.Tagging]
+	assert.False(t, ok)
+
+	denied := s.Services[1]
+	assert.EqualValues(t, "Deny", denied.Effect)
+	assert.EqualValues(t, []string{"DeleteBucket"}, denied.Levels[0].Actions)
+
+	assert.EqualValues(t, []Unrecognized{{Action: "athena:StartQueryExecution", Effect: "Allow", Statement: "p:1"}}, s.Unrecognized)
+}
+
+func TestSummarize_FullService(t *testing.T) {
+	s := Summarize([]*policy.Policy{policy.NewStatement("p:0", "", true, nil, []string{"sqs:*"}, []string{"*"})}, catalog.Default())
+	assert.Len(t, s.Services, 1)
+	if len(s.Services) != 1 {
+		t.FailNow()
+	}
+	for _, l := range s.Services[0].Levels {
+		assert.EqualValues(t, Full, l.Access, l.Level)
+		assert.EqualValues(t, l.Total, l.Granted)
+	}
+	assert.Len(t, s.Services[0].Levels, len(catalog.AccessLevels))
+}
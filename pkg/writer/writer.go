@@ -0,0 +1,92 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Stdout is the path that names standard output.
+const Stdout = "-"
+
+// Writer writes outputs, e.g. parsed policies, to files or standard output.
+// Files are written to a temporary file next to them and renamed into
+// place, so a partially written output never appears under their name.
+type Writer struct {
+	// Overwrite replaces existing files; without it writing to an existing
+	// file fails with an error wrapping os.ErrExist.
+	Overwrite bool
+	// Pretty indents JSON outputs.
+	Pretty bool
+	// Stdout is where Stdout writes go, os.Stdout if nil.
+	Stdout io.Writer
+}
+
+// WriteJson writes v as JSON, without escaping HTML characters.
+func (w *Writer) WriteJson(path string, v interface{}) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if w.Pretty {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return w.Write(path, buf.Bytes())
+}
+
+// Write writes data to path, or to standard output for Stdout.
+func (w *Writer) Write(path string, data []byte) error {
+	if path == Stdout {
+		out := w.Stdout
+		if out == nil {
+			out = os.Stdout
+		}
+		_, err := out.Write(data)
+		return err
+	}
+
+	if !w.Overwrite {
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("%s: %w", path, os.ErrExist)
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	if w.Overwrite {
+		return os.Rename(tmp.Name(), path)
+	}
+	// a link fails if path was created since it was checked above
+	if err = os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s: %w", path, os.ErrExist)
+		}
+		return err
+	}
+	return nil
+}
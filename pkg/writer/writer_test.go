@@ -0,0 +1,59 @@
+package writer
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter_Write(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "parsed.json")
+	v := map[string]string{"id": "<p:0>"}
+
+	w := &Writer{}
+	assert.Nil(t, w.WriteJson(path, v))
+	text, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "{\"id\":\"<p:0>\"}\n", string(text))
+
+	// no clobber by default, and the file is left alone
+	err = w.Write(path, []byte("replaced"))
+	assert.True(t, errors.Is(err, os.ErrExist), "%v", err)
+	text, _ = ioutil.ReadFile(path)
+	assert.EqualValues(t, "{\"id\":\"<p:0>\"}\n", string(text))
+
+	w = &Writer{Overwrite: true, Pretty: true}
+	assert.Nil(t, w.WriteJson(path, v))
+	text, _ = ioutil.ReadFile(path)
+	assert.EqualValues(t, "{\n  \"id\": \"<p:0>\"\n}\n", string(text))
+
+	// shorter contents replace longer ones entirely
+	assert.Nil(t, w.Write(path, []byte("{}")))
+	text, _ = ioutil.ReadFile(path)
+	assert.EqualValues(t, "{}", string(text))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.EqualValues(t, os.FileMode(0644), info.Mode().Perm())
+
+	// no temporary files are left behind
+	entries, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	assert.NotNil(t, w.Write(filepath.Join(dir, "missing", "parsed.json"), []byte("{}")))
+}
+
+func TestWriter_Stdout(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := &Writer{Stdout: out}
+	assert.Nil(t, w.WriteJson(Stdout, []int{1, 2}))
+	assert.Nil(t, w.WriteJson(Stdout, []int{3}))
+	assert.EqualValues(t, "[1,2]\n[3]\n", out.String())
+}